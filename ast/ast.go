@@ -2,11 +2,17 @@ package ast
 
 import (
 	"bytes"
+
+	"github.com/cszczepaniak/monkey/token"
 )
 
+// Node is implemented by every node in the tree. Pos and End report the span
+// of source the node was parsed from; End is the position just past it.
 type Node interface {
 	TokenLiteral() string
 	String() string
+	Pos() token.Position
+	End() token.Position
 }
 
 type Program struct {
@@ -21,6 +27,18 @@ func (p *Program) TokenLiteral() string {
 	}
 	return ""
 }
+func (p *Program) Pos() token.Position {
+	if len(p.Statements) > 0 {
+		return p.Statements[0].Pos()
+	}
+	return token.Position{}
+}
+func (p *Program) End() token.Position {
+	if len(p.Statements) > 0 {
+		return p.Statements[len(p.Statements)-1].End()
+	}
+	return token.Position{}
+}
 func (p *Program) String() string {
 	var out bytes.Buffer
 	for _, s := range p.Statements {
@@ -0,0 +1,96 @@
+package ast
+
+import (
+	"strings"
+
+	"github.com/cszczepaniak/monkey/token"
+)
+
+// Comment is a // line comment or a /* */ block comment. Its token's literal
+// is the comment's text, including the comment markers.
+type Comment struct {
+	Token token.Token
+}
+
+func (c *Comment) TokenLiteral() string {
+	return c.Token.Literal
+}
+func (c *Comment) Pos() token.Position {
+	return c.Token.Pos
+}
+func (c *Comment) End() token.Position {
+	return c.Token.End
+}
+func (c *Comment) String() string {
+	return c.Token.Literal
+}
+
+// CommentGroup is a sequence of comments with nothing but whitespace, and no
+// blank lines, between them.
+type CommentGroup struct {
+	List []*Comment
+}
+
+func (g *CommentGroup) TokenLiteral() string {
+	return g.List[0].TokenLiteral()
+}
+func (g *CommentGroup) Pos() token.Position {
+	return g.List[0].Pos()
+}
+func (g *CommentGroup) End() token.Position {
+	return g.List[len(g.List)-1].End()
+}
+func (g *CommentGroup) String() string {
+	lines := make([]string, len(g.List))
+	for i, c := range g.List {
+		lines[i] = c.String()
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Text returns the text of the comments without the comment markers, one
+// line per line of comment, with leading and trailing blank lines removed.
+// The stars which conventionally start each line of a block comment after
+// the first are removed too. A group of nil comments has no text.
+func (g *CommentGroup) Text() string {
+	if g == nil {
+		return ``
+	}
+	var lines []string
+	for _, c := range g.List {
+		text := c.Token.Literal
+		if strings.HasPrefix(text, `//`) {
+			lines = append(lines, strings.TrimSpace(text[2:]))
+			continue
+		}
+		text = strings.TrimSuffix(strings.TrimPrefix(text, `/*`), `*/`)
+		lines = append(lines, blockLines(text)...)
+	}
+	for len(lines) > 0 && lines[0] == `` {
+		lines = lines[1:]
+	}
+	for len(lines) > 0 && lines[len(lines)-1] == `` {
+		lines = lines[:len(lines)-1]
+	}
+	return strings.Join(lines, "\n")
+}
+
+// blockLines splits the text of a block comment into trimmed lines. If every
+// line but the first that is not blank starts with a star, the stars are
+// removed, along with the second star of a comment opened by /**.
+func blockLines(text string) []string {
+	lines := strings.Split(text, "\n")
+	starred := len(lines) > 1
+	for i, line := range lines {
+		lines[i] = strings.TrimSpace(line)
+		if i > 0 && lines[i] != `` && !strings.HasPrefix(lines[i], `*`) {
+			starred = false
+		}
+	}
+	if starred {
+		for i := range lines {
+			lines[i] = strings.TrimSpace(strings.TrimPrefix(lines[i], `*`))
+		}
+	}
+	return lines
+}
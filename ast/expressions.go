@@ -2,6 +2,7 @@ package ast
 
 import (
 	"bytes"
+	"math/big"
 
 	"github.com/cszczepaniak/monkey/token"
 )
@@ -20,6 +21,12 @@ func (i *Identifier) expressionNode() {}
 func (i *Identifier) TokenLiteral() string {
 	return i.Token.Literal
 }
+func (i *Identifier) Pos() token.Position {
+	return i.Token.Pos
+}
+func (i *Identifier) End() token.Position {
+	return i.Token.End
+}
 
 func (i *Identifier) String() string {
 	return i.Value
@@ -34,11 +41,78 @@ func (il *IntegerLiteral) expressionNode() {}
 func (il *IntegerLiteral) TokenLiteral() string {
 	return il.Token.Literal
 }
+func (il *IntegerLiteral) Pos() token.Position {
+	return il.Token.Pos
+}
+func (il *IntegerLiteral) End() token.Position {
+	return il.Token.End
+}
 
 func (il *IntegerLiteral) String() string {
 	return il.Token.Literal
 }
 
+// BigIntegerLiteral is an integer literal too large for an int64.
+type BigIntegerLiteral struct {
+	Token token.Token
+	Value *big.Int
+}
+
+func (il *BigIntegerLiteral) expressionNode() {}
+func (il *BigIntegerLiteral) TokenLiteral() string {
+	return il.Token.Literal
+}
+func (il *BigIntegerLiteral) Pos() token.Position {
+	return il.Token.Pos
+}
+func (il *BigIntegerLiteral) End() token.Position {
+	return il.Token.End
+}
+
+func (il *BigIntegerLiteral) String() string {
+	return il.Token.Literal
+}
+
+type FloatLiteral struct {
+	Token token.Token
+	Value float64
+}
+
+func (fl *FloatLiteral) expressionNode() {}
+func (fl *FloatLiteral) TokenLiteral() string {
+	return fl.Token.Literal
+}
+func (fl *FloatLiteral) Pos() token.Position {
+	return fl.Token.Pos
+}
+func (fl *FloatLiteral) End() token.Position {
+	return fl.Token.End
+}
+
+func (fl *FloatLiteral) String() string {
+	return fl.Token.Literal
+}
+
+type StringLiteral struct {
+	Token token.Token
+	Value string
+}
+
+func (sl *StringLiteral) expressionNode() {}
+func (sl *StringLiteral) TokenLiteral() string {
+	return sl.Token.Literal
+}
+func (sl *StringLiteral) Pos() token.Position {
+	return sl.Token.Pos
+}
+func (sl *StringLiteral) End() token.Position {
+	return sl.Token.End
+}
+
+func (sl *StringLiteral) String() string {
+	return token.Quote(sl.Value)
+}
+
 type PrefixExpression struct {
 	Token    token.Token
 	Operator string
@@ -49,25 +123,76 @@ func (pe *PrefixExpression) expressionNode() {}
 func (pe *PrefixExpression) TokenLiteral() string {
 	return pe.Token.Literal
 }
+func (pe *PrefixExpression) Pos() token.Position {
+	return pe.Token.Pos
+}
+func (pe *PrefixExpression) End() token.Position {
+	if pe.Right != nil {
+		return pe.Right.End()
+	}
+	return pe.Token.End
+}
 func (pe *PrefixExpression) String() string {
 	return `(` + pe.Operator + pe.Right.String() + `)`
 }
 
 type InfixExpression struct {
 	Token    token.Token
+	Left     Expression
 	Operator string
 	Right    Expression
-	Left     Expression
 }
 
 func (ie *InfixExpression) expressionNode() {}
 func (ie *InfixExpression) TokenLiteral() string {
 	return ie.Token.Literal
 }
+func (ie *InfixExpression) Pos() token.Position {
+	if ie.Left != nil {
+		return ie.Left.Pos()
+	}
+	return ie.Token.Pos
+}
+func (ie *InfixExpression) End() token.Position {
+	if ie.Right != nil {
+		return ie.Right.End()
+	}
+	return ie.Token.End
+}
 func (ie *InfixExpression) String() string {
 	return `(` + ie.Left.String() + ` ` + ie.Operator + ` ` + ie.Right.String() + `)`
 }
 
+// AssignExpression stores Value in Target, which is an identifier or an index
+// expression. Operator is "=" or a compound operator such as "+=", which
+// combines the target's current value with Value.
+type AssignExpression struct {
+	Token    token.Token
+	Target   Expression
+	Operator string
+	Value    Expression
+}
+
+func (ae *AssignExpression) expressionNode() {}
+func (ae *AssignExpression) TokenLiteral() string {
+	return ae.Token.Literal
+}
+func (ae *AssignExpression) Pos() token.Position {
+	if ae.Target != nil {
+		return ae.Target.Pos()
+	}
+	return ae.Token.Pos
+}
+func (ae *AssignExpression) End() token.Position {
+	if ae.Value != nil {
+		return ae.Value.End()
+	}
+	return ae.Token.End
+}
+func (ae *AssignExpression) String() string {
+	return `(` + ae.Target.String() + ` ` + ae.Operator + ` ` + ae.Value.String() + `)`
+}
+
 type BooleanLiteral struct {
 	Token token.Token
 	Value bool
@@ -77,6 +202,12 @@ func (bl *BooleanLiteral) expressionNode() {}
 func (bl *BooleanLiteral) TokenLiteral() string {
 	return bl.Token.Literal
 }
+func (bl *BooleanLiteral) Pos() token.Position {
+	return bl.Token.Pos
+}
+func (bl *BooleanLiteral) End() token.Position {
+	return bl.Token.End
+}
 func (bl *BooleanLiteral) String() string {
 	return bl.Token.Literal
 }
@@ -92,6 +223,18 @@ func (ie *IfExpression) expressionNode() {}
 func (ie *IfExpression) TokenLiteral() string {
 	return ie.Token.Literal
 }
+func (ie *IfExpression) Pos() token.Position {
+	return ie.Token.Pos
+}
+func (ie *IfExpression) End() token.Position {
+	if ie.Alternative != nil {
+		return ie.Alternative.End()
+	}
+	if ie.Consequence != nil {
+		return ie.Consequence.End()
+	}
+	return ie.Token.End
+}
 func (ie *IfExpression) String() string {
 	var out bytes.Buffer
 
@@ -108,28 +251,177 @@ func (ie *IfExpression) String() string {
 	return out.String()
 }
 
+// WhileExpression runs Body for as long as Condition is truthy. Like every
+// loop, its value is null.
+type WhileExpression struct {
+	Token     token.Token
+	Condition Expression
+	Body      *BlockStatement
+}
+
+func (we *WhileExpression) expressionNode() {}
+func (we *WhileExpression) TokenLiteral() string {
+	return we.Token.Literal
+}
+func (we *WhileExpression) Pos() token.Position {
+	return we.Token.Pos
+}
+func (we *WhileExpression) End() token.Position {
+	if we.Body != nil {
+		return we.Body.End()
+	}
+	return we.Token.End
+}
+func (we *WhileExpression) String() string {
+	var out bytes.Buffer
+
+	out.WriteString(`while`)
+	out.WriteString(we.Condition.String())
+	out.WriteString(` `)
+	out.WriteString(we.Body.String())
+
+	return out.String()
+}
+
+// ForExpression runs Body once for each element of Iterable, which is an
+// array, a hash or a range, binding the element to Variable. Hashes yield
+// their keys.
+type ForExpression struct {
+	Token    token.Token
+	Variable *Identifier
+	Iterable Expression
+	Body     *BlockStatement
+}
+
+func (fe *ForExpression) expressionNode() {}
+func (fe *ForExpression) TokenLiteral() string {
+	return fe.Token.Literal
+}
+func (fe *ForExpression) Pos() token.Position {
+	return fe.Token.Pos
+}
+func (fe *ForExpression) End() token.Position {
+	if fe.Body != nil {
+		return fe.Body.End()
+	}
+	return fe.Token.End
+}
+func (fe *ForExpression) String() string {
+	var out bytes.Buffer
+
+	out.WriteString(`for (`)
+	out.WriteString(fe.Variable.String())
+	out.WriteString(` in `)
+	out.WriteString(fe.Iterable.String())
+	out.WriteString(`) `)
+	out.WriteString(fe.Body.String())
+
+	return out.String()
+}
+
+// TryExpression evaluates Body, running Catch with the error bound to
+// CatchVar if Body fails, and then Finally however Body or Catch ends. Catch
+// or Finally may be missing, but not both. Its value is that of Body, or of
+// Catch if it ran.
+type TryExpression struct {
+	Token    token.Token
+	Body     *BlockStatement
+	CatchVar *Identifier
+	Catch    *BlockStatement
+	Finally  *BlockStatement
+}
+
+func (te *TryExpression) expressionNode() {}
+func (te *TryExpression) TokenLiteral() string {
+	return te.Token.Literal
+}
+func (te *TryExpression) Pos() token.Position {
+	return te.Token.Pos
+}
+func (te *TryExpression) End() token.Position {
+	switch {
+	case te.Finally != nil:
+		return te.Finally.End()
+	case te.Catch != nil:
+		return te.Catch.End()
+	case te.Body != nil:
+		return te.Body.End()
+	}
+	return te.Token.End
+}
+func (te *TryExpression) String() string {
+	var out bytes.Buffer
+
+	out.WriteString(`try `)
+	out.WriteString(te.Body.String())
+	if te.Catch != nil {
+		out.WriteString(` catch (`)
+		out.WriteString(te.CatchVar.String())
+		out.WriteString(`) `)
+		out.WriteString(te.Catch.String())
+	}
+	if te.Finally != nil {
+		out.WriteString(` finally `)
+		out.WriteString(te.Finally.String())
+	}
+
+	return out.String()
+}
+
+// FunctionLiteral is a function definition. Defaults holds the default value
+// for each of Args, or nil for parameters which must be passed. Rest, if set,
+// collects any remaining arguments into an array.
 type FunctionLiteral struct {
-	Token token.Token
-	Args  []*Identifier
-	Body  *BlockStatement
+	Token    token.Token
+	Args     []*Identifier
+	Defaults []Expression
+	Rest     *Identifier
+	Body     *BlockStatement
 }
 
 func (fl *FunctionLiteral) expressionNode() {}
 func (fl *FunctionLiteral) TokenLiteral() string {
 	return fl.Token.Literal
 }
+func (fl *FunctionLiteral) Pos() token.Position {
+	return fl.Token.Pos
+}
+func (fl *FunctionLiteral) End() token.Position {
+	if fl.Body != nil {
+		return fl.Body.End()
+	}
+	return fl.Token.End
+}
 func (fl *FunctionLiteral) String() string {
 	var out bytes.Buffer
 	out.WriteString(`fn(`)
-	for i, arg := range fl.Args {
+	out.WriteString(ParamsString(fl.Args, fl.Defaults, fl.Rest))
+	out.WriteString(`) `)
+	out.WriteString(fl.Body.String())
+
+	return out.String()
+}
+
+// ParamsString formats a parameter list without the surrounding parentheses.
+func ParamsString(args []*Identifier, defaults []Expression, rest *Identifier) string {
+	var out bytes.Buffer
+	for i, arg := range args {
+		if i > 0 {
+			out.WriteString(`, `)
+		}
 		out.WriteString(arg.String())
-		if i < len(fl.Args)-1 {
+		if i < len(defaults) && defaults[i] != nil {
+			out.WriteString(` = `)
+			out.WriteString(defaults[i].String())
+		}
+	}
+	if rest != nil {
+		if len(args) > 0 {
 			out.WriteString(`, `)
 		}
+		out.WriteString(`...`)
+		out.WriteString(rest.String())
 	}
-	out.WriteString(`) `)
-	out.WriteString(fl.Body.String())
-
 	return out.String()
 }
 
@@ -137,12 +429,25 @@ type CallExpression struct {
 	Token    token.Token
 	Function Expression
 	Args     []Expression
+	Rparen   token.Token
 }
 
 func (ce *CallExpression) expressionNode() {}
 func (ce *CallExpression) TokenLiteral() string {
 	return ce.Token.Literal
 }
+func (ce *CallExpression) Pos() token.Position {
+	if ce.Function != nil {
+		return ce.Function.Pos()
+	}
+	return ce.Token.Pos
+}
+func (ce *CallExpression) End() token.Position {
+	if ce.Rparen.End.IsValid() {
+		return ce.Rparen.End
+	}
+	return ce.Token.End
+}
 func (ce *CallExpression) String() string {
 	var out bytes.Buffer
 
@@ -158,3 +463,124 @@ func (ce *CallExpression) String() string {
 
 	return out.String()
 }
+
+type ArrayLiteral struct {
+	Token    token.Token
+	Elements []Expression
+	Rbracket token.Token
+}
+
+func (al *ArrayLiteral) expressionNode() {}
+func (al *ArrayLiteral) TokenLiteral() string {
+	return al.Token.Literal
+}
+func (al *ArrayLiteral) Pos() token.Position {
+	return al.Token.Pos
+}
+func (al *ArrayLiteral) End() token.Position {
+	if al.Rbracket.End.IsValid() {
+		return al.Rbracket.End
+	}
+	return al.Token.End
+}
+func (al *ArrayLiteral) String() string {
+	var out bytes.Buffer
+
+	out.WriteString(`[`)
+	for i, el := range al.Elements {
+		out.WriteString(el.String())
+		if i < len(al.Elements)-1 {
+			out.WriteString(`, `)
+		}
+	}
+	out.WriteString(`]`)
+
+	return out.String()
+}
+
+type IndexExpression struct {
+	Token    token.Token
+	Left     Expression
+	Index    Expression
+	Rbracket token.Token
+}
+
+func (ie *IndexExpression) expressionNode() {}
+func (ie *IndexExpression) TokenLiteral() string {
+	return ie.Token.Literal
+}
+func (ie *IndexExpression) Pos() token.Position {
+	if ie.Left != nil {
+		return ie.Left.Pos()
+	}
+	return ie.Token.Pos
+}
+func (ie *IndexExpression) End() token.Position {
+	if ie.Rbracket.End.IsValid() {
+		return ie.Rbracket.End
+	}
+	return ie.Token.End
+}
+func (ie *IndexExpression) String() string {
+	return `(` + ie.Left.String() + `[` + ie.Index.String() + `])`
+}
+
+type HashLiteral struct {
+	Token  token.Token
+	Pairs  []HashPair
+	Rbrace token.Token
+}
+
+type HashPair struct {
+	Key   Expression
+	Value Expression
+}
+
+func (hl *HashLiteral) expressionNode() {}
+func (hl *HashLiteral) TokenLiteral() string {
+	return hl.Token.Literal
+}
+func (hl *HashLiteral) Pos() token.Position {
+	return hl.Token.Pos
+}
+func (hl *HashLiteral) End() token.Position {
+	if hl.Rbrace.End.IsValid() {
+		return hl.Rbrace.End
+	}
+	return hl.Token.End
+}
+func (hl *HashLiteral) String() string {
+	var out bytes.Buffer
+
+	out.WriteString(`{`)
+	for i, pair := range hl.Pairs {
+		out.WriteString(pair.Key.String())
+		out.WriteString(`: `)
+		out.WriteString(pair.Value.String())
+		if i < len(hl.Pairs)-1 {
+			out.WriteString(`, `)
+		}
+	}
+	out.WriteString(`}`)
+
+	return out.String()
+}
+
+// BadExpression stands in for an expression that could not be parsed.
+type BadExpression struct {
+	From, To token.Position
+}
+
+func (be *BadExpression) expressionNode() {}
+func (be *BadExpression) TokenLiteral() string {
+	return ``
+}
+func (be *BadExpression) Pos() token.Position {
+	return be.From
+}
+func (be *BadExpression) End() token.Position {
+	return be.To
+}
+func (be *BadExpression) String() string {
+	return `<bad expression>`
+}
@@ -0,0 +1,110 @@
+package ast
+
+import (
+	"fmt"
+	"io"
+	"math/big"
+	"reflect"
+	"strings"
+
+	"github.com/cszczepaniak/monkey/token"
+)
+
+var (
+	tokenType    = reflect.TypeOf(token.Token{})
+	positionType = reflect.TypeOf(token.Position{})
+	bigIntType   = reflect.TypeOf(&big.Int{})
+)
+
+// Fprint writes the tree rooted at node to w, one node per line and indented
+// by depth. Each line shows the node's type and position, and the values of
+// its fields that are not themselves nodes.
+func Fprint(w io.Writer, node Node) error {
+	p := printer{w: w}
+	p.print(``, reflect.ValueOf(node), 0)
+	return p.err
+}
+
+type printer struct {
+	w   io.Writer
+	err error
+}
+
+func (p *printer) printf(depth int, format string, args ...interface{}) {
+	if p.err != nil {
+		return
+	}
+	_, p.err = fmt.Fprintf(p.w, strings.Repeat(`  `, depth)+format+"\n", args...)
+}
+
+func (p *printer) print(label string, v reflect.Value, depth int) {
+	if label != `` {
+		label += `: `
+	}
+	for v.Kind() == reflect.Interface || v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			p.printf(depth, `%snil`, label)
+			return
+		}
+		if n, ok := v.Interface().(Node); ok && v.Kind() == reflect.Ptr {
+			if pos := n.Pos(); pos.IsValid() {
+				label += fmt.Sprintf(`%s `, pos)
+			}
+		}
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		p.printf(depth, `%s%v`, label, v.Interface())
+		return
+	}
+
+	t := v.Type()
+	var attrs []string
+	var children []int
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != `` || f.Type == tokenType || f.Type == positionType {
+			continue
+		}
+		switch f.Type.Kind() {
+		case reflect.String:
+			attrs = append(attrs, fmt.Sprintf(`%s=%q`, f.Name, v.Field(i).String()))
+		case reflect.Int, reflect.Int64, reflect.Bool, reflect.Float64:
+			attrs = append(attrs, fmt.Sprintf(`%s=%v`, f.Name, v.Field(i).Interface()))
+		case reflect.Ptr:
+			if f.Type == bigIntType {
+				attrs = append(attrs, fmt.Sprintf(`%s=%v`, f.Name, v.Field(i).Interface()))
+			} else {
+				children = append(children, i)
+			}
+		default:
+			children = append(children, i)
+		}
+	}
+	p.printf(depth, `%s%s%s`, label, t.Name(), strings.Join(append([]string{``}, attrs...), ` `))
+
+	// Missing children, such as an if expression's alternative, are left
+	// out.
+	for _, i := range children {
+		f, fv := t.Field(i), v.Field(i)
+		if fv.Kind() != reflect.Slice {
+			if !isNil(fv) {
+				p.print(f.Name, fv, depth+1)
+			}
+			continue
+		}
+		for j := 0; j < fv.Len(); j++ {
+			if el := fv.Index(j); !isNil(el) {
+				p.print(fmt.Sprintf(`%s[%d]`, f.Name, j), el, depth+1)
+			}
+		}
+	}
+}
+
+func isNil(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Interface, reflect.Ptr, reflect.Slice, reflect.Map:
+		return v.IsNil()
+	}
+	return false
+}
@@ -0,0 +1,37 @@
+package ast
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/cszczepaniak/monkey/token"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFprint(t *testing.T) {
+	pos := func(col int) token.Position { return token.Position{Line: 1, Column: col} }
+	program := &Program{
+		Statements: []Statement{
+			&LetStatement{
+				Token: token.Token{Type: token.LET, Literal: `let`, Pos: pos(1)},
+				Name:  &Identifier{Token: token.Token{Type: token.IDENT, Literal: `x`, Pos: pos(5)}, Value: `x`},
+				Value: &InfixExpression{
+					Token:    token.Token{Type: token.PLUS, Literal: `+`, Pos: pos(11)},
+					Left:     &IntegerLiteral{Token: token.Token{Type: token.INT, Literal: `1`, Pos: pos(9)}, Value: 1},
+					Operator: `+`,
+					Right:    &IfExpression{Token: token.Token{Type: token.IF, Literal: `if`, Pos: pos(13)}},
+				},
+			},
+		},
+	}
+
+	var buf bytes.Buffer
+	assert.NoError(t, Fprint(&buf, program))
+	assert.Equal(t, `1:1 Program
+  Statements[0]: 1:1 LetStatement
+    Name: 1:5 Identifier Value="x"
+    Value: 1:9 InfixExpression Operator="+"
+      Left: 1:9 IntegerLiteral Value=1
+      Right: 1:13 IfExpression
+`, buf.String())
+}
@@ -11,8 +11,12 @@ type Statement interface {
 	statementNode()
 }
 
+// LetStatement binds Name to Value. Doc holds the comments directly above
+// the statement, or is nil. A const declaration is a LetStatement whose Token
+// is CONST; its binding cannot be assigned to.
 type LetStatement struct {
 	Token token.Token
+	Doc   *CommentGroup
 	Name  *Identifier
 	Value Expression
 }
@@ -22,6 +26,23 @@ func (ls *LetStatement) TokenLiteral() string {
 	return ls.Token.Literal
 }
 
+// IsConst reports whether the statement declares a constant.
+func (ls *LetStatement) IsConst() bool {
+	return ls.Token.Type == token.CONST
+}
+func (ls *LetStatement) Pos() token.Position {
+	return ls.Token.Pos
+}
+func (ls *LetStatement) End() token.Position {
+	if ls.Value != nil {
+		return ls.Value.End()
+	}
+	if ls.Name != nil {
+		return ls.Name.End()
+	}
+	return ls.Token.End
+}
+
 func (ls *LetStatement) String() string {
 	var out bytes.Buffer
 
@@ -45,6 +66,15 @@ func (rs *ReturnStatement) statementNode() {}
 func (rs *ReturnStatement) TokenLiteral() string {
 	return rs.Token.Literal
 }
+func (rs *ReturnStatement) Pos() token.Position {
+	return rs.Token.Pos
+}
+func (rs *ReturnStatement) End() token.Position {
+	if rs.ReturnValue != nil {
+		return rs.ReturnValue.End()
+	}
+	return rs.Token.End
+}
 
 func (rs *ReturnStatement) String() string {
 	var out bytes.Buffer
@@ -58,6 +88,58 @@ func (rs *ReturnStatement) String() string {
 	return out.String()
 }
 
+// ThrowStatement raises Value as an error, which an enclosing try
+// expression may catch.
+type ThrowStatement struct {
+	Token token.Token
+	Value Expression
+}
+
+func (ts *ThrowStatement) statementNode() {}
+func (ts *ThrowStatement) TokenLiteral() string {
+	return ts.Token.Literal
+}
+func (ts *ThrowStatement) Pos() token.Position {
+	return ts.Token.Pos
+}
+func (ts *ThrowStatement) End() token.Position {
+	if ts.Value != nil {
+		return ts.Value.End()
+	}
+	return ts.Token.End
+}
+func (ts *ThrowStatement) String() string {
+	var out bytes.Buffer
+
+	out.WriteString(ts.TokenLiteral() + " ")
+	if ts.Value != nil {
+		out.WriteString(ts.Value.String())
+	}
+	out.WriteString(";")
+
+	return out.String()
+}
+
+// BranchStatement is a break or a continue, depending on its Token. It may
+// only appear inside the body of a loop.
+type BranchStatement struct {
+	Token token.Token
+}
+
+func (bs *BranchStatement) statementNode() {}
+func (bs *BranchStatement) TokenLiteral() string {
+	return bs.Token.Literal
+}
+func (bs *BranchStatement) Pos() token.Position {
+	return bs.Token.Pos
+}
+func (bs *BranchStatement) End() token.Position {
+	return bs.Token.End
+}
+func (bs *BranchStatement) String() string {
+	return bs.Token.Literal + `;`
+}
+
 type ExpressionStatement struct {
 	Token      token.Token
 	Expression Expression
@@ -67,6 +149,18 @@ func (es *ExpressionStatement) statementNode() {}
 func (es *ExpressionStatement) TokenLiteral() string {
 	return es.Token.Literal
 }
+func (es *ExpressionStatement) Pos() token.Position {
+	if es.Expression != nil {
+		return es.Expression.Pos()
+	}
+	return es.Token.Pos
+}
+func (es *ExpressionStatement) End() token.Position {
+	if es.Expression != nil {
+		return es.Expression.End()
+	}
+	return es.Token.End
+}
 
 func (es *ExpressionStatement) String() string {
 	if es.Expression != nil {
@@ -78,12 +172,25 @@ func (es *ExpressionStatement) String() string {
 type BlockStatement struct {
 	Token      token.Token
 	Statements []Statement
+	Rbrace     token.Token
 }
 
 func (bs *BlockStatement) statementNode() {}
 func (bs *BlockStatement) TokenLiteral() string {
 	return bs.Token.Literal
 }
+func (bs *BlockStatement) Pos() token.Position {
+	return bs.Token.Pos
+}
+func (bs *BlockStatement) End() token.Position {
+	if bs.Rbrace.End.IsValid() {
+		return bs.Rbrace.End
+	}
+	if len(bs.Statements) > 0 {
+		return bs.Statements[len(bs.Statements)-1].End()
+	}
+	return bs.Token.End
+}
 func (bs *BlockStatement) String() string {
 	var out bytes.Buffer
 
@@ -96,3 +203,23 @@ func (bs *BlockStatement) String() string {
 
 	return out.String()
 }
+
+// BadStatement stands in for a statement that could not be parsed. It spans
+// the source the parser skipped over before carrying on.
+type BadStatement struct {
+	From, To token.Position
+}
+
+func (bs *BadStatement) statementNode() {}
+func (bs *BadStatement) TokenLiteral() string {
+	return ``
+}
+func (bs *BadStatement) Pos() token.Position {
+	return bs.From
+}
+func (bs *BadStatement) End() token.Position {
+	return bs.To
+}
+func (bs *BadStatement) String() string {
+	return `<bad statement>`
+}
@@ -0,0 +1,105 @@
+package ast
+
+// Inspect traverses the tree rooted at node in depth-first order, calling f
+// for each node. If f returns false, the children of that node are skipped.
+// Missing children, such as an if expression without an alternative, are not
+// visited.
+func Inspect(node Node, f func(Node) bool) {
+	if node == nil || !f(node) {
+		return
+	}
+
+	switch n := node.(type) {
+	case *Program:
+		for _, s := range n.Statements {
+			Inspect(s, f)
+		}
+	case *LetStatement:
+		if n.Name != nil {
+			Inspect(n.Name, f)
+		}
+		Inspect(n.Value, f)
+	case *ReturnStatement:
+		Inspect(n.ReturnValue, f)
+	case *ThrowStatement:
+		Inspect(n.Value, f)
+	case *ExpressionStatement:
+		Inspect(n.Expression, f)
+	case *BlockStatement:
+		for _, s := range n.Statements {
+			Inspect(s, f)
+		}
+	case *PrefixExpression:
+		Inspect(n.Right, f)
+	case *InfixExpression:
+		Inspect(n.Left, f)
+		Inspect(n.Right, f)
+	case *AssignExpression:
+		Inspect(n.Target, f)
+		Inspect(n.Value, f)
+	case *IfExpression:
+		Inspect(n.Condition, f)
+		if n.Consequence != nil {
+			Inspect(n.Consequence, f)
+		}
+		if n.Alternative != nil {
+			Inspect(n.Alternative, f)
+		}
+	case *WhileExpression:
+		Inspect(n.Condition, f)
+		if n.Body != nil {
+			Inspect(n.Body, f)
+		}
+	case *ForExpression:
+		if n.Variable != nil {
+			Inspect(n.Variable, f)
+		}
+		Inspect(n.Iterable, f)
+		if n.Body != nil {
+			Inspect(n.Body, f)
+		}
+	case *TryExpression:
+		if n.Body != nil {
+			Inspect(n.Body, f)
+		}
+		if n.CatchVar != nil {
+			Inspect(n.CatchVar, f)
+		}
+		if n.Catch != nil {
+			Inspect(n.Catch, f)
+		}
+		if n.Finally != nil {
+			Inspect(n.Finally, f)
+		}
+	case *FunctionLiteral:
+		for i, a := range n.Args {
+			Inspect(a, f)
+			if i < len(n.Defaults) {
+				Inspect(n.Defaults[i], f)
+			}
+		}
+		if n.Rest != nil {
+			Inspect(n.Rest, f)
+		}
+		if n.Body != nil {
+			Inspect(n.Body, f)
+		}
+	case *CallExpression:
+		Inspect(n.Function, f)
+		for _, a := range n.Args {
+			Inspect(a, f)
+		}
+	case *ArrayLiteral:
+		for _, el := range n.Elements {
+			Inspect(el, f)
+		}
+	case *IndexExpression:
+		Inspect(n.Left, f)
+		Inspect(n.Index, f)
+	case *HashLiteral:
+		for _, p := range n.Pairs {
+			Inspect(p.Key, f)
+			Inspect(p.Value, f)
+		}
+	}
+}
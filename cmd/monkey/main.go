@@ -0,0 +1,133 @@
+// Command monkey runs Monkey programs.
+//
+// Usage:
+//
+//	monkey [-engine=eval|vm]         start the REPL
+//	monkey run file.mk [args...]     run a script
+//	monkey file.mk [args...]         run a script, as from a "#!" line
+//	monkey -e 'expr' [args...]       run expr and print its value
+//
+// The arguments after the script or expression are bound to args, an array
+// of strings. The exit status is 1 if the program fails with an error and 2
+// if it does not parse or the command line is invalid.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/user"
+	"strings"
+
+	"github.com/cszczepaniak/monkey"
+	"github.com/cszczepaniak/monkey/diagnostics"
+	"github.com/cszczepaniak/monkey/evaluator"
+	"github.com/cszczepaniak/monkey/object"
+	"github.com/cszczepaniak/monkey/repl"
+)
+
+const (
+	exitOK         = 0
+	exitError      = 1
+	exitParseError = 2
+	exitUsage      = 2
+)
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+func run(argv []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet(`monkey`, flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	engine := flags.String(`engine`, string(repl.EngineEval), `engine the REPL runs programs on: eval or vm`)
+	expr := flags.String(`e`, ``, `run `+"`expr`"+` and print its value`)
+	if err := flags.Parse(argv); err != nil {
+		return exitUsage
+	}
+	args := flags.Args()
+
+	exprSet := false
+	flags.Visit(func(f *flag.Flag) { exprSet = exprSet || f.Name == `e` })
+	if exprSet {
+		return runSource(`-e`, *expr, args, true, stdout, stderr)
+	}
+
+	if len(args) > 0 && args[0] == `run` {
+		args = args[1:]
+		if len(args) == 0 {
+			fmt.Fprintln(stderr, `usage: monkey run file.mk [args...]`)
+			return exitUsage
+		}
+	}
+	if len(args) == 0 {
+		return startREPL(stdin, stdout, repl.Engine(*engine))
+	}
+
+	src, err := os.ReadFile(args[0])
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitError
+	}
+	return runSource(args[0], stripShebang(string(src)), args[1:], false, stdout, stderr)
+}
+
+// stripShebang removes a "#!" line from the start of a script, which lets it
+// be executed directly, keeping the newline so that line numbers still match
+// the file.
+func stripShebang(src string) string {
+	if !strings.HasPrefix(src, `#!`) {
+		return src
+	}
+	if i := strings.IndexByte(src, '\n'); i >= 0 {
+		return src[i:]
+	}
+	return ``
+}
+
+func startREPL(stdin io.Reader, stdout io.Writer, engine repl.Engine) int {
+	if u, err := user.Current(); err == nil {
+		fmt.Fprintf(stdout, "Hello, %s! This is the Monkey programming language\n", u.Username)
+	}
+	fmt.Fprintf(stdout, "Feel free to type some commands...\n")
+	repl.StartEngine(stdin, stdout, engine)
+	return exitOK
+}
+
+// runSource runs src with args bound to the script arguments. If print is
+// set, the program's value is written to stdout unless it is null.
+func runSource(filename, src string, args []string, print bool, stdout, stderr io.Writer) int {
+	interp := monkey.NewWithConfig(evaluator.Config{Out: stdout})
+	if err := interp.Set(`args`, args); err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitError
+	}
+
+	res, err := interp.RunFile(filename, src)
+	printer := diagnostics.Printer{Filename: filename, Src: src}
+	if f, ok := stderr.(*os.File); ok {
+		printer.Color = repl.UseColor(f)
+	}
+	var parseErr *monkey.ParseError
+	var runErr *object.Error
+	switch {
+	case errors.As(err, &parseErr):
+		for _, d := range diagnostics.FromParseErrors(parseErr.ErrorList) {
+			printer.Fprint(stderr, d)
+		}
+		return exitParseError
+	case errors.As(err, &runErr):
+		printer.Fprint(stderr, diagnostics.FromRuntimeError(runErr, src, interp.Env().Names()))
+		return exitError
+	case err != nil:
+		fmt.Fprintf(stderr, "%s\n", err)
+		return exitError
+	}
+
+	if print && res != nil && res != object.Object(evaluator.NULL) {
+		fmt.Fprintln(stdout, res.Inspect())
+	}
+	return exitOK
+}
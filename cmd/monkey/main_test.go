@@ -0,0 +1,62 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRun(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, src string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(src), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	ok := write(`ok.mk`, "#!/usr/bin/env monkey\nlet x = len(args);\nx")
+	bad := write(`bad.mk`, "let x = 1;\nx + true")
+	badScript := write(`bad_script.mk`, "#!/usr/bin/env monkey\nx + true")
+	unparsable := write(`unparsable.mk`, `let = 1;`)
+	nested := write(`nested.mk`, "let f = fn(x) { x + true };\nlet g = fn() { f(1) + 1 };\n[g()]")
+
+	tests := []struct {
+		argv      []string
+		expCode   int
+		expStdout string
+		expStderr string
+	}{
+		{[]string{`run`, ok, `a`, `b`}, exitOK, ``, ``},
+		{[]string{ok}, exitOK, ``, ``},
+		{[]string{`run`, bad}, exitError, ``, bad + ":2:1: error: type mismatch: INTEGER + BOOLEAN\n 2 | x + true\n   | ^^^^^^^^\n"},
+		{[]string{badScript}, exitError, ``, badScript + ":2:1: error: identifier not found: x\n 2 | x + true\n   | ^\n"},
+		{[]string{`-e`, "#!/usr/bin/env monkey\n1"}, exitParseError, ``, "-e:1:1: error: illegal character"},
+		{[]string{`run`, nested}, exitError, ``, nested + ":1:17: error: type mismatch: INTEGER + BOOLEAN\n 1 | let f = fn(x) { x + true };\n   |                 ^^^^^^^^\n   = note: in f, called at " + nested + ":2:16\n   = note: in g, called at " + nested + ":3:2\n"},
+		{[]string{`run`, unparsable}, exitParseError, ``, unparsable + ":1:5: error: Expected next token to be IDENT, got = instead\n 1 | let = 1;\n   |     ^\n"},
+		{[]string{`-e`, `1 + 2`}, exitOK, "3\n", ``},
+		{[]string{`-e`, `args`, `x`, `-y`}, exitOK, "[\"x\", \"-y\"]\n", ``},
+		{[]string{`-e`, `puts`}, exitOK, "builtin function puts\n", ``},
+		{[]string{`-e`, `puts("hi")`}, exitOK, "hi\n", ``},
+		{[]string{`-e`, `if (false) { 1 }`}, exitOK, ``, ``},
+		{[]string{`-e`, `foo`}, exitError, ``, "-e:1:1: error: identifier not found: foo\n 1 | foo\n   | ^^^\n"},
+		{[]string{`-e`, `let total = 2; totl * 2`}, exitError, ``, "   = help: did you mean total?\n"},
+		{[]string{`run`}, exitUsage, ``, "usage: monkey run file.mk [args...]\n"},
+		{[]string{`run`, filepath.Join(dir, `missing.mk`)}, exitError, ``, `no such file or directory`},
+	}
+
+	for _, tc := range tests {
+		var stdout, stderr bytes.Buffer
+		code := run(tc.argv, strings.NewReader(``), &stdout, &stderr)
+		assert.Equal(t, tc.expCode, code, tc.argv)
+		assert.Equal(t, tc.expStdout, stdout.String(), tc.argv)
+		assert.Contains(t, stderr.String(), tc.expStderr, tc.argv)
+		if tc.expStderr == `` {
+			assert.Empty(t, stderr.String(), tc.argv)
+		}
+	}
+}
@@ -0,0 +1,265 @@
+// Package code defines the bytecode instruction set executed by the vm
+// package.
+package code
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+)
+
+type Instructions []byte
+
+func (ins Instructions) String() string {
+	var out bytes.Buffer
+
+	i := 0
+	for i < len(ins) {
+		def, err := Lookup(ins[i])
+		if err != nil {
+			fmt.Fprintf(&out, "ERROR: %s\n", err)
+			i++
+			continue
+		}
+		operands, read := ReadOperands(def, ins[i+1:])
+		fmt.Fprintf(&out, "%04d %s\n", i, fmtInstruction(def, operands))
+		i += 1 + read
+	}
+
+	return out.String()
+}
+
+func fmtInstruction(def *Definition, operands []int) string {
+	if len(operands) != len(def.OperandWidths) {
+		return fmt.Sprintf(`ERROR: operand len %d does not match defined %d`, len(operands), len(def.OperandWidths))
+	}
+	switch len(operands) {
+	case 0:
+		return def.Name
+	case 1:
+		return fmt.Sprintf(`%s %d`, def.Name, operands[0])
+	case 2:
+		return fmt.Sprintf(`%s %d %d`, def.Name, operands[0], operands[1])
+	default:
+		return fmt.Sprintf(`ERROR: unhandled operand count for %s`, def.Name)
+	}
+}
+
+type Opcode byte
+
+const (
+	OpConstant Opcode = iota
+	OpPop
+	OpDupPair
+	OpTrue
+	OpFalse
+	OpNull
+
+	OpAdd
+	OpSub
+	OpMul
+	OpDiv
+	OpMod
+	OpEqual
+	OpNotEqual
+	OpGreaterThan
+	OpLessThan
+	OpGreaterEqual
+	OpLessEqual
+	OpBitAnd
+	OpBitOr
+	OpBitXor
+	OpShiftLeft
+	OpShiftRight
+	OpMinus
+	OpBang
+	OpBitNot
+
+	OpJump
+	OpJumpNotTruthy
+	OpIter
+	OpIterNext
+	OpTry
+	OpEndTry
+	OpCaught
+	OpThrow
+
+	OpGetGlobal
+	OpSetGlobal
+	OpGetLocal
+	OpSetLocal
+	OpGetFree
+	OpCurrentClosure
+	OpAssignGlobal
+	OpAssignLocal
+	OpGetLocalCell
+	OpSetLocalCell
+	OpCaptureLocal
+	OpGetFreeCell
+	OpSetFreeCell
+
+	OpArray
+	OpHash
+	OpIndex
+	OpSetIndex
+
+	OpClosure
+	OpCall
+	OpReturnValue
+	OpJumpIfArg
+)
+
+// Definition describes an opcode: its name for disassembly and the width in
+// bytes of each of its operands.
+type Definition struct {
+	Name          string
+	OperandWidths []int
+}
+
+var definitions = map[Opcode]*Definition{
+	OpConstant: {`OpConstant`, []int{2}},
+	OpPop:      {`OpPop`, []int{}},
+	OpTrue:     {`OpTrue`, []int{}},
+	OpFalse:    {`OpFalse`, []int{}},
+	OpNull:     {`OpNull`, []int{}},
+	// OpDupPair duplicates the top two elements of the stack.
+	OpDupPair: {`OpDupPair`, []int{}},
+
+	OpAdd:          {`OpAdd`, []int{}},
+	OpSub:          {`OpSub`, []int{}},
+	OpMul:          {`OpMul`, []int{}},
+	OpDiv:          {`OpDiv`, []int{}},
+	OpMod:          {`OpMod`, []int{}},
+	OpEqual:        {`OpEqual`, []int{}},
+	OpNotEqual:     {`OpNotEqual`, []int{}},
+	OpGreaterThan:  {`OpGreaterThan`, []int{}},
+	OpLessThan:     {`OpLessThan`, []int{}},
+	OpGreaterEqual: {`OpGreaterEqual`, []int{}},
+	OpLessEqual:    {`OpLessEqual`, []int{}},
+	OpBitAnd:       {`OpBitAnd`, []int{}},
+	OpBitOr:        {`OpBitOr`, []int{}},
+	OpBitXor:       {`OpBitXor`, []int{}},
+	OpShiftLeft:    {`OpShiftLeft`, []int{}},
+	OpShiftRight:   {`OpShiftRight`, []int{}},
+	OpMinus:        {`OpMinus`, []int{}},
+	OpBang:         {`OpBang`, []int{}},
+	OpBitNot:       {`OpBitNot`, []int{}},
+
+	OpJump:          {`OpJump`, []int{2}},
+	OpJumpNotTruthy: {`OpJumpNotTruthy`, []int{2}},
+	// OpIter replaces the value on top of the stack with an iterator over
+	// it. OpIterNext pushes the iterator's next value, or pops the iterator
+	// and jumps to its operand once there are none left.
+	OpIter:     {`OpIter`, []int{}},
+	OpIterNext: {`OpIterNext`, []int{2}},
+	// OpTry installs a handler which catches an error raised before the
+	// matching OpEndTry removes it: it restores the stack to its height at
+	// the OpTry, pushes the error and jumps to its operand. OpCaught replaces
+	// such an error with the value a catch clause binds. OpThrow raises the
+	// value on top of the stack, or raises an error left by a handler again.
+	OpTry:    {`OpTry`, []int{2}},
+	OpEndTry: {`OpEndTry`, []int{}},
+	OpCaught: {`OpCaught`, []int{}},
+	OpThrow:  {`OpThrow`, []int{}},
+
+	OpGetGlobal:      {`OpGetGlobal`, []int{2}},
+	OpSetGlobal:      {`OpSetGlobal`, []int{2}},
+	OpGetLocal:       {`OpGetLocal`, []int{1}},
+	OpSetLocal:       {`OpSetLocal`, []int{1}},
+	OpGetFree:        {`OpGetFree`, []int{1}},
+	OpCurrentClosure: {`OpCurrentClosure`, []int{}},
+
+	// The assignment opcodes store into a slot which must already be set,
+	// so that assigning to an undefined variable is an error.
+	OpAssignGlobal: {`OpAssignGlobal`, []int{2}},
+	OpAssignLocal:  {`OpAssignLocal`, []int{1}},
+
+	// Boxed variables live in cells, which closures capture instead of the
+	// variable's value. OpCaptureLocal pushes the cell itself, creating it if
+	// the variable has not been set yet.
+	OpGetLocalCell: {`OpGetLocalCell`, []int{1}},
+	OpSetLocalCell: {`OpSetLocalCell`, []int{1}},
+	OpCaptureLocal: {`OpCaptureLocal`, []int{1}},
+	OpGetFreeCell:  {`OpGetFreeCell`, []int{1}},
+	OpSetFreeCell:  {`OpSetFreeCell`, []int{1}},
+
+	OpArray: {`OpArray`, []int{2}},
+	OpHash:  {`OpHash`, []int{2}},
+	OpIndex: {`OpIndex`, []int{}},
+	// OpSetIndex stores the top of the stack in the array or hash below the
+	// index beneath it, leaving the stored value.
+	OpSetIndex: {`OpSetIndex`, []int{}},
+
+	// OpClosure's operands are the constant index of the compiled function
+	// and the number of free variables on the stack.
+	OpClosure:     {`OpClosure`, []int{2, 1}},
+	OpCall:        {`OpCall`, []int{1}},
+	OpReturnValue: {`OpReturnValue`, []int{}},
+	// OpJumpIfArg jumps to its second operand if the current call was passed
+	// an argument for the parameter numbered by its first. It guards the code
+	// computing a parameter's default value.
+	OpJumpIfArg: {`OpJumpIfArg`, []int{1, 2}},
+}
+
+func Lookup(op byte) (*Definition, error) {
+	def, ok := definitions[Opcode(op)]
+	if !ok {
+		return nil, fmt.Errorf(`opcode %d undefined`, op)
+	}
+	return def, nil
+}
+
+// Make encodes an instruction. It returns an empty slice for an unknown
+// opcode.
+func Make(op Opcode, operands ...int) []byte {
+	def, ok := definitions[op]
+	if !ok {
+		return []byte{}
+	}
+
+	length := 1
+	for _, w := range def.OperandWidths {
+		length += w
+	}
+
+	ins := make([]byte, length)
+	ins[0] = byte(op)
+
+	offset := 1
+	for i, o := range operands {
+		switch def.OperandWidths[i] {
+		case 2:
+			binary.BigEndian.PutUint16(ins[offset:], uint16(o))
+		case 1:
+			ins[offset] = byte(o)
+		}
+		offset += def.OperandWidths[i]
+	}
+	return ins
+}
+
+// ReadOperands decodes the operands of an instruction, returning them and the
+// number of bytes read.
+func ReadOperands(def *Definition, ins Instructions) ([]int, int) {
+	operands := make([]int, len(def.OperandWidths))
+	offset := 0
+
+	for i, w := range def.OperandWidths {
+		switch w {
+		case 2:
+			operands[i] = int(ReadUint16(ins[offset:]))
+		case 1:
+			operands[i] = int(ReadUint8(ins[offset:]))
+		}
+		offset += w
+	}
+	return operands, offset
+}
+
+func ReadUint16(ins Instructions) uint16 {
+	return binary.BigEndian.Uint16(ins)
+}
+
+func ReadUint8(ins Instructions) uint8 {
+	return ins[0]
+}
@@ -0,0 +1,70 @@
+package code
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMake(t *testing.T) {
+	tests := []struct {
+		op       Opcode
+		operands []int
+		expected []byte
+	}{
+		{OpConstant, []int{65534}, []byte{byte(OpConstant), 255, 254}},
+		{OpAdd, []int{}, []byte{byte(OpAdd)}},
+		{OpGetLocal, []int{255}, []byte{byte(OpGetLocal), 255}},
+		{OpClosure, []int{65534, 255}, []byte{byte(OpClosure), 255, 254, 255}},
+		{OpJumpIfArg, []int{2, 258}, []byte{byte(OpJumpIfArg), 2, 1, 2}},
+	}
+
+	for _, tc := range tests {
+		assert.Equal(t, tc.expected, Make(tc.op, tc.operands...))
+	}
+}
+
+func TestInstructionsString(t *testing.T) {
+	instructions := []Instructions{
+		Make(OpAdd),
+		Make(OpGetLocal, 1),
+		Make(OpConstant, 2),
+		Make(OpConstant, 65535),
+		Make(OpClosure, 65535, 255),
+	}
+
+	expected := `0000 OpAdd
+0001 OpGetLocal 1
+0003 OpConstant 2
+0006 OpConstant 65535
+0009 OpClosure 65535 255
+`
+
+	var concatted Instructions
+	for _, ins := range instructions {
+		concatted = append(concatted, ins...)
+	}
+	assert.Equal(t, expected, concatted.String())
+}
+
+func TestReadOperands(t *testing.T) {
+	tests := []struct {
+		op        Opcode
+		operands  []int
+		bytesRead int
+	}{
+		{OpConstant, []int{65535}, 2},
+		{OpGetLocal, []int{255}, 1},
+		{OpClosure, []int{65535, 255}, 3},
+	}
+
+	for _, tc := range tests {
+		instruction := Make(tc.op, tc.operands...)
+		def, err := Lookup(byte(tc.op))
+		assert.NoError(t, err)
+
+		operandsRead, n := ReadOperands(def, instruction[1:])
+		assert.Equal(t, tc.bytesRead, n)
+		assert.Equal(t, tc.operands, operandsRead)
+	}
+}
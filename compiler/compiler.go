@@ -0,0 +1,998 @@
+// Package compiler translates a parsed program into bytecode for the vm
+// package.
+package compiler
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/cszczepaniak/monkey/ast"
+	"github.com/cszczepaniak/monkey/code"
+	"github.com/cszczepaniak/monkey/object"
+	"github.com/cszczepaniak/monkey/token"
+)
+
+// Bytecode is the output of the compiler. Positions and Ends map instruction
+// offsets in Instructions to the start and end of the source they were
+// compiled from, and GlobalNames names each global slot.
+type Bytecode struct {
+	Instructions code.Instructions
+	Constants    []object.Object
+	Positions    map[int]token.Position
+	Ends         map[int]token.Position
+	GlobalNames  []string
+}
+
+type emittedInstruction struct {
+	Opcode   code.Opcode
+	Position int
+}
+
+type compilationScope struct {
+	instructions    code.Instructions
+	positions, ends map[int]token.Position
+	lastInstruction emittedInstruction
+
+	// pending counts the values on the stack pushed by the expressions
+	// enclosing the one being compiled, which break and continue discard.
+	pending int
+	// loops holds the loops enclosing the code being compiled, innermost
+	// last.
+	loops []*loop
+	// tries holds the try expressions whose handlers are installed while
+	// the code being compiled runs, innermost last.
+	tries []*ast.TryExpression
+}
+
+func newCompilationScope() compilationScope {
+	return compilationScope{
+		positions: make(map[int]token.Position),
+		ends:      make(map[int]token.Position),
+	}
+}
+
+// loop is a loop being compiled.
+type loop struct {
+	// continueTarget is where continue jumps to, with continuePending
+	// values pending. Breaks leave breakPending values pending, and are
+	// patched to jump to the end of the loop once it is known.
+	continueTarget  int
+	continuePending int
+	breakPending    int
+	breaks          []int
+	// tries is the number of try expressions enclosing the loop, whose
+	// handlers break and continue leave installed.
+	tries int
+}
+
+type Compiler struct {
+	constants   []object.Object
+	symbolTable *SymbolTable
+
+	scopes     []compilationScope
+	scopeIndex int
+
+	// pos and end are the span of the node being compiled, recorded for
+	// each instruction emitted so that runtime errors can point at the
+	// source.
+	pos, end token.Position
+
+	// err records the first operand emit found too large for its field,
+	// which Compile reports once the node being compiled is done.
+	err error
+}
+
+func New() *Compiler {
+	return NewWithState(NewSymbolTable(), nil)
+}
+
+// NewWithState returns a compiler that continues from the symbol table and
+// constants of a previous compilation, as the REPL does between lines.
+func NewWithState(s *SymbolTable, constants []object.Object) *Compiler {
+	return &Compiler{
+		constants:   constants,
+		symbolTable: s,
+		scopes:      []compilationScope{newCompilationScope()},
+	}
+}
+
+func (c *Compiler) Bytecode() *Bytecode {
+	return &Bytecode{
+		Instructions: c.currentInstructions(),
+		Constants:    c.constants,
+		Positions:    c.scopes[c.scopeIndex].positions,
+		Ends:         c.scopes[c.scopeIndex].ends,
+		GlobalNames:  c.symbolTable.global().Names(),
+	}
+}
+
+// Constants returns the constants compiled so far, for use with
+// NewWithState.
+func (c *Compiler) Constants() []object.Object {
+	return c.constants
+}
+
+func (c *Compiler) Compile(node ast.Node) (err error) {
+	if node == nil {
+		return fmt.Errorf(`cannot compile missing node; the program did not parse`)
+	}
+	prevPos, prevEnd := c.pos, c.end
+	c.pos, c.end = node.Pos(), node.End()
+	defer func() {
+		c.pos, c.end = prevPos, prevEnd
+		if err == nil {
+			err = c.err
+		}
+	}()
+
+	switch n := node.(type) {
+	case *ast.Program:
+		for i, s := range n.Statements {
+			if err := c.Compile(s); err != nil {
+				return err
+			}
+			// A program's value is that of its last statement, even if it
+			// is a let statement.
+			if ls, ok := s.(*ast.LetStatement); ok && i == len(n.Statements)-1 {
+				c.loadName(ls.Name.Value)
+				c.emit(code.OpPop)
+			}
+		}
+	case *ast.ExpressionStatement:
+		if err := c.Compile(n.Expression); err != nil {
+			return err
+		}
+		c.emit(code.OpPop)
+	case *ast.BlockStatement:
+		return c.compileBlock(n)
+	case *ast.LetStatement:
+		if sym, ok := c.symbolTable.Defined(n.Name.Value); ok && sym.Constant {
+			return fmt.Errorf(`%s: cannot redeclare constant %s`, n.Pos(), n.Name.Value)
+		}
+		if fl, ok := n.Value.(*ast.FunctionLiteral); ok {
+			if err := c.compileFunction(fl, n.Name.Value); err != nil {
+				return err
+			}
+		} else if err := c.Compile(n.Value); err != nil {
+			return err
+		}
+		var sym Symbol
+		if n.IsConst() {
+			sym = c.symbolTable.DefineConst(n.Name.Value)
+		} else {
+			sym = c.symbolTable.Define(n.Name.Value)
+		}
+		c.storeSymbol(sym)
+	case *ast.ReturnStatement:
+		if err := c.Compile(n.ReturnValue); err != nil {
+			return err
+		}
+		if err := c.leaveTries(0, c.scopes[c.scopeIndex].pending+1); err != nil {
+			return err
+		}
+		c.emit(code.OpReturnValue)
+	case *ast.ThrowStatement:
+		if err := c.Compile(n.Value); err != nil {
+			return err
+		}
+		c.emit(code.OpThrow)
+	case *ast.TryExpression:
+		return c.compileTry(n)
+	case *ast.Identifier:
+		c.loadName(n.Value)
+	case *ast.IntegerLiteral:
+		c.emit(code.OpConstant, c.addConstant(&object.Integer{Value: n.Value}))
+	case *ast.BigIntegerLiteral:
+		c.emit(code.OpConstant, c.addConstant(&object.BigInteger{Value: n.Value}))
+	case *ast.FloatLiteral:
+		c.emit(code.OpConstant, c.addConstant(&object.Float{Value: n.Value}))
+	case *ast.StringLiteral:
+		c.emit(code.OpConstant, c.addConstant(&object.String{Value: n.Value}))
+	case *ast.BooleanLiteral:
+		if n.Value {
+			c.emit(code.OpTrue)
+		} else {
+			c.emit(code.OpFalse)
+		}
+	case *ast.PrefixExpression:
+		if err := c.Compile(n.Right); err != nil {
+			return err
+		}
+		switch n.Operator {
+		case `!`:
+			c.emit(code.OpBang)
+		case `-`:
+			c.emit(code.OpMinus)
+		case `~`:
+			c.emit(code.OpBitNot)
+		default:
+			return fmt.Errorf(`%s: unknown operator %s`, n.Pos(), n.Operator)
+		}
+	case *ast.InfixExpression:
+		return c.compileInfix(n)
+	case *ast.AssignExpression:
+		return c.compileAssign(n)
+	case *ast.IfExpression:
+		return c.compileIf(n)
+	case *ast.WhileExpression:
+		return c.compileWhile(n)
+	case *ast.ForExpression:
+		return c.compileFor(n)
+	case *ast.BranchStatement:
+		return c.compileBranch(n)
+	case *ast.ArrayLiteral:
+		if err := c.compileOperands(n.Elements...); err != nil {
+			return err
+		}
+		c.emit(code.OpArray, len(n.Elements))
+	case *ast.HashLiteral:
+		var operands []ast.Expression
+		for _, p := range n.Pairs {
+			operands = append(operands, p.Key, p.Value)
+		}
+		if err := c.compileOperands(operands...); err != nil {
+			return err
+		}
+		c.emit(code.OpHash, 2*len(n.Pairs))
+	case *ast.IndexExpression:
+		if err := c.compileOperands(n.Left, n.Index); err != nil {
+			return err
+		}
+		c.emit(code.OpIndex)
+	case *ast.FunctionLiteral:
+		return c.compileFunction(n, ``)
+	case *ast.CallExpression:
+		if err := c.compileOperands(append([]ast.Expression{n.Function}, n.Args...)...); err != nil {
+			return err
+		}
+		if len(n.Args) > 255 {
+			return fmt.Errorf(`%s: too many arguments: %d`, n.Pos(), len(n.Args))
+		}
+		c.emit(code.OpCall, len(n.Args))
+	case *ast.BadStatement, *ast.BadExpression:
+		return fmt.Errorf(`%s: cannot compile code that did not parse`, n.Pos())
+	default:
+		return fmt.Errorf(`cannot compile %T`, node)
+	}
+	return nil
+}
+
+var infixOpcodes = map[string]code.Opcode{
+	`+`:  code.OpAdd,
+	`-`:  code.OpSub,
+	`*`:  code.OpMul,
+	`/`:  code.OpDiv,
+	`%`:  code.OpMod,
+	`==`: code.OpEqual,
+	`!=`: code.OpNotEqual,
+	`>`:  code.OpGreaterThan,
+	`<`:  code.OpLessThan,
+	`>=`: code.OpGreaterEqual,
+	`<=`: code.OpLessEqual,
+	`&`:  code.OpBitAnd,
+	`|`:  code.OpBitOr,
+	`^`:  code.OpBitXor,
+	`<<`: code.OpShiftLeft,
+	`>>`: code.OpShiftRight,
+}
+
+func (c *Compiler) compileInfix(n *ast.InfixExpression) error {
+	if n.Operator == `&&` || n.Operator == `||` {
+		return c.compileLogical(n)
+	}
+	op, ok := infixOpcodes[n.Operator]
+	if !ok {
+		return fmt.Errorf(`%s: unknown operator %s`, n.Pos(), n.Operator)
+	}
+	if err := c.compileOperands(n.Left, n.Right); err != nil {
+		return err
+	}
+	c.emit(op)
+	return nil
+}
+
+// compileAssign compiles an assignment, leaving the assigned value on the
+// stack. A compound assignment reads the target before evaluating the value.
+func (c *Compiler) compileAssign(n *ast.AssignExpression) error {
+	var op code.Opcode
+	if n.Operator != `=` {
+		var ok bool
+		op, ok = infixOpcodes[strings.TrimSuffix(n.Operator, `=`)]
+		if !ok {
+			return fmt.Errorf(`%s: unknown operator %s`, n.Pos(), n.Operator)
+		}
+	}
+
+	scope := c.scopeIndex
+	defer func(pending int) { c.scopes[scope].pending = pending }(c.scopes[scope].pending)
+
+	switch target := n.Target.(type) {
+	case *ast.Identifier:
+		sym, err := c.resolveAssignable(target)
+		if err != nil {
+			return err
+		}
+		if op != 0 {
+			c.loadSymbol(sym)
+			c.scopes[scope].pending++
+		}
+		if err := c.Compile(n.Value); err != nil {
+			return err
+		}
+		if op != 0 {
+			c.emit(op)
+		}
+		switch sym.Scope {
+		case GlobalScope:
+			c.emit(code.OpAssignGlobal, sym.Index)
+		case LocalScope:
+			c.emit(code.OpAssignLocal, sym.Index)
+		case FreeScope:
+			c.emit(code.OpSetFreeCell, sym.Index)
+		}
+		c.loadSymbol(sym)
+	case *ast.IndexExpression:
+		if err := c.compileOperands(target.Left, target.Index); err != nil {
+			return err
+		}
+		c.scopes[scope].pending += 2
+		if op != 0 {
+			c.emit(code.OpDupPair)
+			c.emit(code.OpIndex)
+			c.scopes[scope].pending++
+		}
+		if err := c.Compile(n.Value); err != nil {
+			return err
+		}
+		if op != 0 {
+			c.emit(op)
+		}
+		c.emit(code.OpSetIndex)
+	default:
+		return fmt.Errorf(`%s: cannot assign to %s`, n.Pos(), n.Target)
+	}
+	return nil
+}
+
+// resolveAssignable returns the symbol an assignment to ident stores into.
+// As with loadName, names not bound anywhere yet are taken to be globals.
+func (c *Compiler) resolveAssignable(ident *ast.Identifier) (Symbol, error) {
+	sym, ok := c.symbolTable.Resolve(ident.Value)
+	if ok && sym.Scope == FunctionScope {
+		// Inside a function, its own name refers to the running closure
+		// rather than to the variable holding it, which is only reachable
+		// if it is global.
+		if c.symbolTable.Outer != c.symbolTable.global() {
+			return Symbol{}, fmt.Errorf(`%s: cannot assign to %s inside its own definition`, ident.Pos(), ident.Value)
+		}
+		sym, ok = c.symbolTable.Outer.Resolve(ident.Value)
+	}
+	if !ok {
+		sym = c.symbolTable.global().Define(ident.Value)
+	}
+	switch {
+	case sym.Constant:
+		return Symbol{}, fmt.Errorf(`%s: cannot assign to constant %s`, ident.Pos(), ident.Value)
+	case sym.Scope == FreeScope && !sym.Boxed:
+		return Symbol{}, fmt.Errorf(`%s: cannot assign to captured variable %s`, ident.Pos(), ident.Value)
+	}
+	return sym, nil
+}
+
+// compileLogical compiles && and || so that the right operand is only
+// evaluated if the left does not decide the result. Like the evaluator, it
+// leaves a boolean on the stack; a double OpBang converts the right operand.
+func (c *Compiler) compileLogical(n *ast.InfixExpression) error {
+	if err := c.Compile(n.Left); err != nil {
+		return err
+	}
+	jumpNotTruthy := c.emit(code.OpJumpNotTruthy, 9999)
+	if n.Operator == `||` {
+		c.emit(code.OpTrue)
+	} else if err := c.compileTruthiness(n.Right); err != nil {
+		return err
+	}
+	jump := c.emit(code.OpJump, 9999)
+
+	c.changeOperand(jumpNotTruthy, len(c.currentInstructions()))
+	if n.Operator == `&&` {
+		c.emit(code.OpFalse)
+	} else if err := c.compileTruthiness(n.Right); err != nil {
+		return err
+	}
+	c.changeOperand(jump, len(c.currentInstructions()))
+	return nil
+}
+
+func (c *Compiler) compileTruthiness(expr ast.Expression) error {
+	if err := c.Compile(expr); err != nil {
+		return err
+	}
+	c.emit(code.OpBang)
+	c.emit(code.OpBang)
+	return nil
+}
+
+func (c *Compiler) compileIf(n *ast.IfExpression) error {
+	if err := c.Compile(n.Condition); err != nil {
+		return err
+	}
+	jumpNotTruthy := c.emit(code.OpJumpNotTruthy, 9999)
+	if err := c.Compile(n.Consequence); err != nil {
+		return err
+	}
+	jump := c.emit(code.OpJump, 9999)
+
+	c.changeOperand(jumpNotTruthy, len(c.currentInstructions()))
+	if n.Alternative == nil {
+		c.emit(code.OpNull)
+	} else if err := c.Compile(n.Alternative); err != nil {
+		return err
+	}
+	c.changeOperand(jump, len(c.currentInstructions()))
+	return nil
+}
+
+// compileOperands compiles exprs in order, leaving their values on the stack
+// for the caller to consume. Each value is pending while the following
+// operands are compiled.
+func (c *Compiler) compileOperands(exprs ...ast.Expression) error {
+	scope := c.scopeIndex
+	defer func(pending int) { c.scopes[scope].pending = pending }(c.scopes[scope].pending)
+	for _, e := range exprs {
+		if err := c.Compile(e); err != nil {
+			return err
+		}
+		c.scopes[scope].pending++
+	}
+	return nil
+}
+
+func (c *Compiler) compileWhile(n *ast.WhileExpression) error {
+	start := len(c.currentInstructions())
+	if err := c.Compile(n.Condition); err != nil {
+		return err
+	}
+	exit := c.emit(code.OpJumpNotTruthy, 9999)
+
+	scope := &c.scopes[c.scopeIndex]
+	l := &loop{continueTarget: start, continuePending: scope.pending, breakPending: scope.pending, tries: len(scope.tries)}
+	if err := c.compileLoopBody(l, n.Body); err != nil {
+		return err
+	}
+	c.emit(code.OpJump, start)
+
+	c.changeOperand(exit, len(c.currentInstructions()))
+	c.endLoop(l)
+	return nil
+}
+
+// compileFor compiles a for loop. The iterator stays on the stack while the
+// loop runs, and OpIterNext pops it once it is exhausted.
+func (c *Compiler) compileFor(n *ast.ForExpression) error {
+	name := n.Variable.Value
+	if sym, ok := c.symbolTable.Defined(name); ok && sym.Constant {
+		return fmt.Errorf(`%s: cannot redeclare constant %s`, n.Pos(), name)
+	}
+	if err := c.Compile(n.Iterable); err != nil {
+		return err
+	}
+	c.emit(code.OpIter)
+
+	scope := &c.scopes[c.scopeIndex]
+	l := &loop{continuePending: scope.pending + 1, breakPending: scope.pending, tries: len(scope.tries)}
+	l.continueTarget = c.emit(code.OpIterNext, 9999)
+	c.storeSymbol(c.symbolTable.Define(name))
+
+	scope.pending++
+	err := c.compileLoopBody(l, n.Body)
+	c.scopes[c.scopeIndex].pending--
+	if err != nil {
+		return err
+	}
+	c.emit(code.OpJump, l.continueTarget)
+
+	c.changeOperand(l.continueTarget, len(c.currentInstructions()))
+	c.endLoop(l)
+	return nil
+}
+
+// compileLoopBody compiles the body of l, discarding its value.
+func (c *Compiler) compileLoopBody(l *loop, body *ast.BlockStatement) error {
+	scope := c.scopeIndex
+	c.scopes[scope].loops = append(c.scopes[scope].loops, l)
+	defer func() {
+		loops := c.scopes[scope].loops
+		c.scopes[scope].loops = loops[:len(loops)-1]
+	}()
+
+	if err := c.Compile(body); err != nil {
+		return err
+	}
+	c.emit(code.OpPop)
+	return nil
+}
+
+// endLoop points the breaks out of l at the current instruction, which
+// pushes the loop's value.
+func (c *Compiler) endLoop(l *loop) {
+	for _, pos := range l.breaks {
+		c.changeOperand(pos, len(c.currentInstructions()))
+	}
+	c.emit(code.OpNull)
+}
+
+// compileBranch compiles a break or continue, which pops the values pushed
+// since the start of the loop and leaves the try expressions inside it
+// before jumping.
+func (c *Compiler) compileBranch(n *ast.BranchStatement) error {
+	scope := &c.scopes[c.scopeIndex]
+	if len(scope.loops) == 0 {
+		return fmt.Errorf(`%s: %s outside loop`, n.Pos(), n.Token.Literal)
+	}
+	l := scope.loops[len(scope.loops)-1]
+
+	pending := l.continuePending
+	if n.Token.Type == token.BREAK {
+		pending = l.breakPending
+	}
+	for i := pending; i < scope.pending; i++ {
+		c.emit(code.OpPop)
+	}
+	if err := c.leaveTries(l.tries, pending); err != nil {
+		return err
+	}
+	if n.Token.Type == token.BREAK {
+		l.breaks = append(l.breaks, c.emit(code.OpJump, 9999))
+	} else {
+		c.emit(code.OpJump, l.continueTarget)
+	}
+	return nil
+}
+
+// compileTry compiles a try expression. While the body runs, a handler
+// jumps to the catch clause, if any. While the catch clause runs, or the body
+// if there is no catch clause, a handler jumps to a copy of the finally
+// clause which raises the error again.
+func (c *Compiler) compileTry(n *ast.TryExpression) error {
+	handler := c.emit(code.OpTry, 9999)
+	if err := c.compileGuarded(n, n.Body); err != nil {
+		return err
+	}
+	c.emit(code.OpEndTry)
+	if n.Catch == nil {
+		return c.compileFinally(n.Finally, handler)
+	}
+
+	skip := c.emit(code.OpJump, 9999)
+	c.changeOperand(handler, len(c.currentInstructions()))
+	name := n.CatchVar.Value
+	if sym, ok := c.symbolTable.Defined(name); ok && sym.Constant {
+		return fmt.Errorf(`%s: cannot redeclare constant %s`, n.CatchVar.Pos(), name)
+	}
+	c.emit(code.OpCaught)
+	c.storeSymbol(c.symbolTable.Define(name))
+
+	if n.Finally == nil {
+		if err := c.Compile(n.Catch); err != nil {
+			return err
+		}
+		c.changeOperand(skip, len(c.currentInstructions()))
+		return nil
+	}
+	handler = c.emit(code.OpTry, 9999)
+	if err := c.compileGuarded(n, n.Catch); err != nil {
+		return err
+	}
+	c.emit(code.OpEndTry)
+	c.changeOperand(skip, len(c.currentInstructions()))
+	return c.compileFinally(n.Finally, handler)
+}
+
+// compileGuarded compiles block, part of the try expression n, while n's
+// handler is installed.
+func (c *Compiler) compileGuarded(n *ast.TryExpression, block *ast.BlockStatement) error {
+	scope := c.scopeIndex
+	c.scopes[scope].tries = append(c.scopes[scope].tries, n)
+	defer func() {
+		tries := c.scopes[scope].tries
+		c.scopes[scope].tries = tries[:len(tries)-1]
+	}()
+	return c.Compile(block)
+}
+
+// compileFinally compiles a finally clause twice, discarding its value:
+// once to run after the value of the try expression, and once for the
+// handler, which runs it after the error and then raises the error again.
+func (c *Compiler) compileFinally(finally *ast.BlockStatement, handler int) error {
+	scope := c.scopeIndex
+	c.scopes[scope].pending++
+	defer func() { c.scopes[scope].pending-- }()
+
+	if err := c.Compile(finally); err != nil {
+		return err
+	}
+	c.emit(code.OpPop)
+	end := c.emit(code.OpJump, 9999)
+
+	c.changeOperand(handler, len(c.currentInstructions()))
+	if err := c.Compile(finally); err != nil {
+		return err
+	}
+	c.emit(code.OpPop)
+	c.emit(code.OpThrow)
+	c.changeOperand(end, len(c.currentInstructions()))
+	return nil
+}
+
+// leaveTries compiles leaving the try expressions being compiled, from the
+// innermost out to the nth: it removes their handlers and runs their finally
+// clauses. pending is the number of values on the stack meanwhile.
+func (c *Compiler) leaveTries(n, pending int) error {
+	scope := c.scopeIndex
+	tries := c.scopes[scope].tries
+	prevPending := c.scopes[scope].pending
+	defer func() {
+		c.scopes[scope].tries = tries
+		c.scopes[scope].pending = prevPending
+	}()
+
+	for i := len(tries) - 1; i >= n; i-- {
+		c.emit(code.OpEndTry)
+		if tries[i].Finally == nil {
+			continue
+		}
+		// The finally clause runs outside its try expression. Capping the
+		// slice keeps any try inside it from overwriting the ones outside.
+		c.scopes[scope].tries = tries[:i:i]
+		c.scopes[scope].pending = pending
+		if err := c.Compile(tries[i].Finally); err != nil {
+			return err
+		}
+		c.emit(code.OpPop)
+	}
+	return nil
+}
+
+// compileBlock compiles a block as an expression, leaving the value of its
+// last statement on the stack.
+func (c *Compiler) compileBlock(block *ast.BlockStatement) error {
+	if len(block.Statements) == 0 {
+		c.emit(code.OpNull)
+		return nil
+	}
+	for _, s := range block.Statements {
+		if err := c.Compile(s); err != nil {
+			return err
+		}
+	}
+	switch s := block.Statements[len(block.Statements)-1].(type) {
+	case *ast.ExpressionStatement:
+		c.removeLastPop()
+	case *ast.LetStatement:
+		c.loadName(s.Name.Value)
+	}
+	return nil
+}
+
+// compileFunction compiles a function literal. If name is not empty, the
+// function can refer to itself by it.
+func (c *Compiler) compileFunction(fl *ast.FunctionLiteral, name string) error {
+	c.enterScope()
+	c.symbolTable.locals, c.symbolTable.boxed = scanFunction(fl)
+	if name != `` {
+		c.symbolTable.DefineFunctionName(name)
+	}
+	err := c.compileFunctionBody(fl)
+	freeSymbols := c.symbolTable.FreeSymbols
+	numLocals := c.symbolTable.numDefinitions
+	localNames := c.symbolTable.Names()
+	scope := c.leaveScope()
+	if err != nil {
+		return err
+	}
+	if numLocals > 256 {
+		return fmt.Errorf(`%s: too many local bindings: %d`, fl.Pos(), numLocals)
+	}
+
+	for _, s := range freeSymbols {
+		c.captureSymbol(s)
+	}
+	required := 0
+	for i := range fl.Args {
+		if i >= len(fl.Defaults) || fl.Defaults[i] == nil {
+			required++
+		}
+	}
+	fn := &object.CompiledFunction{
+		Name:         name,
+		Instructions: scope.instructions,
+		NumLocals:    numLocals,
+		NumParams:    len(fl.Args),
+		NumRequired:  required,
+		Variadic:     fl.Rest != nil,
+		Positions:    scope.positions,
+		Ends:         scope.ends,
+		LocalNames:   localNames,
+		Literal:      fl,
+	}
+	c.emit(code.OpClosure, c.addConstant(fn), len(freeSymbols))
+	return nil
+}
+
+func (c *Compiler) compileFunctionBody(fl *ast.FunctionLiteral) error {
+	for _, a := range fl.Args {
+		c.symbolTable.Define(a.Value)
+	}
+	if fl.Rest != nil {
+		c.symbolTable.Define(fl.Rest.Value)
+	}
+
+	// Parameters the caller omitted are set to their defaults in order, so
+	// a default may refer to earlier parameters.
+	for i, def := range fl.Defaults {
+		if def == nil {
+			continue
+		}
+		jump := c.emit(code.OpJumpIfArg, i, 9999)
+		if err := c.Compile(def); err != nil {
+			return err
+		}
+		c.emit(code.OpSetLocal, i)
+		c.changeOperand(jump, i, len(c.currentInstructions()))
+	}
+
+	// Boxed parameters are moved into cells once they are all set.
+	for _, name := range c.symbolTable.Names() {
+		if sym, _ := c.symbolTable.Defined(name); sym.Boxed {
+			c.emit(code.OpGetLocal, sym.Index)
+			c.emit(code.OpSetLocalCell, sym.Index)
+		}
+	}
+
+	if err := c.compileBlock(fl.Body); err != nil {
+		return err
+	}
+	c.emit(code.OpReturnValue)
+	return nil
+}
+
+// loadName pushes the value bound to name. Names that are not bound anywhere
+// yet are assumed to be globals defined later, or builtins.
+func (c *Compiler) loadName(name string) {
+	sym, ok := c.symbolTable.Resolve(name)
+	if !ok {
+		sym = c.symbolTable.global().Define(name)
+	}
+	c.loadSymbol(sym)
+}
+
+func (c *Compiler) loadSymbol(s Symbol) {
+	switch {
+	case s.Scope == GlobalScope:
+		c.emit(code.OpGetGlobal, s.Index)
+	case s.Scope == LocalScope && s.Boxed:
+		c.emit(code.OpGetLocalCell, s.Index)
+	case s.Scope == LocalScope:
+		c.emit(code.OpGetLocal, s.Index)
+	case s.Scope == FreeScope && s.Boxed:
+		c.emit(code.OpGetFreeCell, s.Index)
+	case s.Scope == FreeScope:
+		c.emit(code.OpGetFree, s.Index)
+	case s.Scope == FunctionScope:
+		c.emit(code.OpCurrentClosure)
+	}
+}
+
+// storeSymbol pops the top of the stack into the variable s being defined.
+func (c *Compiler) storeSymbol(s Symbol) {
+	switch {
+	case s.Scope == GlobalScope:
+		c.emit(code.OpSetGlobal, s.Index)
+	case s.Boxed:
+		c.emit(code.OpSetLocalCell, s.Index)
+	default:
+		c.emit(code.OpSetLocal, s.Index)
+	}
+}
+
+// captureSymbol pushes the free variable s for a closure being created. A
+// boxed variable is captured as its cell, so the closure shares it.
+func (c *Compiler) captureSymbol(s Symbol) {
+	switch {
+	case s.Scope == LocalScope && s.Boxed:
+		c.emit(code.OpCaptureLocal, s.Index)
+	case s.Scope == LocalScope:
+		c.emit(code.OpGetLocal, s.Index)
+	case s.Scope == FreeScope:
+		c.emit(code.OpGetFree, s.Index)
+	case s.Scope == FunctionScope:
+		c.emit(code.OpCurrentClosure)
+	}
+}
+
+// scanFunction returns the names of the variables fl binds, and of those
+// among them which must be boxed because a closure may see them change. A
+// closure copies any other variable it captures: a parameter, or a variable
+// bound by a single let statement directly in fl's body, which is set once
+// before any closure capturing it is created and never assigned to.
+func scanFunction(fl *ast.FunctionLiteral) (locals, boxed map[string]bool) {
+	locals = make(map[string]bool)
+	bindings := make(map[string]int)
+	// setAt holds the offsets at which variables bound only once are set.
+	setAt := make(map[string]int)
+	// captured holds the offset of the first closure capturing each name.
+	captured := make(map[string]int)
+
+	bind := func(name string, declares, once bool, at int) {
+		if declares {
+			locals[name] = true
+		}
+		bindings[name]++
+		if once {
+			setAt[name] = at
+		}
+	}
+	for i, a := range fl.Args {
+		at := fl.Pos().Offset
+		if i < len(fl.Defaults) && fl.Defaults[i] != nil {
+			at = fl.Defaults[i].End().Offset
+		}
+		bind(a.Value, true, true, at)
+	}
+	if fl.Rest != nil {
+		bind(fl.Rest.Value, true, true, fl.Pos().Offset)
+	}
+
+	top := make(map[ast.Statement]bool)
+	for _, s := range fl.Body.Statements {
+		top[s] = true
+	}
+	ast.Inspect(fl.Body, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.LetStatement:
+			bind(n.Name.Value, true, top[n], n.End().Offset)
+		case *ast.AssignExpression:
+			if ident, ok := n.Target.(*ast.Identifier); ok {
+				bind(ident.Value, false, false, 0)
+			}
+		case *ast.ForExpression:
+			if n.Variable != nil {
+				bind(n.Variable.Value, true, false, 0)
+			}
+		case *ast.TryExpression:
+			if n.CatchVar != nil {
+				bind(n.CatchVar.Value, true, false, 0)
+			}
+		case *ast.FunctionLiteral:
+			at := n.Pos().Offset
+			ast.Inspect(n, func(m ast.Node) bool {
+				switch m := m.(type) {
+				case *ast.Identifier:
+					if first, ok := captured[m.Value]; !ok || at < first {
+						captured[m.Value] = at
+					}
+				case *ast.AssignExpression:
+					if ident, ok := m.Target.(*ast.Identifier); ok {
+						bind(ident.Value, false, false, 0)
+					}
+				}
+				return true
+			})
+			return false
+		}
+		return true
+	})
+
+	boxed = make(map[string]bool)
+	for name, at := range captured {
+		if !locals[name] {
+			continue
+		}
+		set, once := setAt[name]
+		if bindings[name] > 1 || !once || at < set {
+			boxed[name] = true
+		}
+	}
+	return locals, boxed
+}
+
+func (c *Compiler) addConstant(obj object.Object) int {
+	c.constants = append(c.constants, obj)
+	return len(c.constants) - 1
+}
+
+func (c *Compiler) emit(op code.Opcode, operands ...int) int {
+	c.checkOperands(op, operands)
+	ins := code.Make(op, operands...)
+	scope := &c.scopes[c.scopeIndex]
+	pos := len(scope.instructions)
+	scope.instructions = append(scope.instructions, ins...)
+	scope.positions[pos] = c.pos
+	scope.ends[pos] = c.end
+	scope.lastInstruction = emittedInstruction{Opcode: op, Position: pos}
+	return pos
+}
+
+func (c *Compiler) currentInstructions() code.Instructions {
+	return c.scopes[c.scopeIndex].instructions
+}
+
+func (c *Compiler) removeLastPop() {
+	scope := &c.scopes[c.scopeIndex]
+	if scope.lastInstruction.Opcode != code.OpPop {
+		return
+	}
+	delete(scope.positions, scope.lastInstruction.Position)
+	delete(scope.ends, scope.lastInstruction.Position)
+	scope.instructions = scope.instructions[:scope.lastInstruction.Position]
+}
+
+func (c *Compiler) changeOperand(pos int, operands ...int) {
+	ins := c.currentInstructions()
+	op := code.Opcode(ins[pos])
+	c.checkOperands(op, operands)
+	copy(ins[pos:], code.Make(op, operands...))
+}
+
+// checkOperands records an error if an operand does not fit in its field of
+// op's encoding, which would otherwise silently truncate it.
+func (c *Compiler) checkOperands(op code.Opcode, operands []int) {
+	def, err := code.Lookup(byte(op))
+	if err != nil || c.err != nil {
+		return
+	}
+	for i, w := range def.OperandWidths {
+		if operands[i] >= 1<<(8*w) {
+			c.err = fmt.Errorf(`%s: %s: %d`, c.pos, operandLimit(op, i), operands[i])
+			return
+		}
+	}
+}
+
+// operandLimit describes what a program has too many of when the ith operand
+// of op overflows.
+func operandLimit(op code.Opcode, i int) string {
+	switch op {
+	case code.OpConstant:
+		return `too many constants`
+	case code.OpGetGlobal, code.OpSetGlobal, code.OpAssignGlobal:
+		return `too many global bindings`
+	case code.OpJump, code.OpJumpNotTruthy, code.OpIterNext, code.OpTry:
+		return `too much code to jump over`
+	case code.OpJumpIfArg:
+		if i == 1 {
+			return `too much code to jump over`
+		}
+		return `too many parameters`
+	case code.OpArray:
+		return `too many array elements`
+	case code.OpHash:
+		return `too many hash elements`
+	case code.OpClosure:
+		if i == 0 {
+			return `too many constants`
+		}
+		return `too many free variables`
+	case code.OpGetFree, code.OpGetFreeCell, code.OpSetFreeCell:
+		return `too many free variables`
+	case code.OpCall:
+		return `too many arguments`
+	default:
+		return `too many local bindings`
+	}
+}
+
+func (c *Compiler) enterScope() {
+	c.scopes = append(c.scopes, newCompilationScope())
+	c.scopeIndex++
+	c.symbolTable = NewEnclosedSymbolTable(c.symbolTable)
+}
+
+func (c *Compiler) leaveScope() compilationScope {
+	scope := c.scopes[c.scopeIndex]
+	c.scopes = c.scopes[:c.scopeIndex]
+	c.scopeIndex--
+	c.symbolTable = c.symbolTable.Outer
+	return scope
+}
@@ -0,0 +1,371 @@
+package compiler
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/cszczepaniak/monkey/code"
+	"github.com/cszczepaniak/monkey/lexer"
+	"github.com/cszczepaniak/monkey/object"
+	"github.com/cszczepaniak/monkey/parser"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompile(t *testing.T) {
+	tests := []struct {
+		input           string
+		expInstructions []code.Instructions
+	}{{
+		`1 + 2`,
+		[]code.Instructions{
+			code.Make(code.OpConstant, 0),
+			code.Make(code.OpConstant, 1),
+			code.Make(code.OpAdd),
+			code.Make(code.OpPop),
+		},
+	}, {
+		`1 < 2`,
+		[]code.Instructions{
+			code.Make(code.OpConstant, 0),
+			code.Make(code.OpConstant, 1),
+			code.Make(code.OpLessThan),
+			code.Make(code.OpPop),
+		},
+	}, {
+		`if (true) { 10 }; 3333;`,
+		[]code.Instructions{
+			code.Make(code.OpTrue),
+			code.Make(code.OpJumpNotTruthy, 10),
+			code.Make(code.OpConstant, 0),
+			code.Make(code.OpJump, 11),
+			code.Make(code.OpNull),
+			code.Make(code.OpPop),
+			code.Make(code.OpConstant, 1),
+			code.Make(code.OpPop),
+		},
+	}, {
+		`true && false`,
+		[]code.Instructions{
+			code.Make(code.OpTrue),
+			code.Make(code.OpJumpNotTruthy, 10),
+			code.Make(code.OpFalse),
+			code.Make(code.OpBang),
+			code.Make(code.OpBang),
+			code.Make(code.OpJump, 11),
+			code.Make(code.OpFalse),
+			code.Make(code.OpPop),
+		},
+	}, {
+		`let one = 1; one`,
+		[]code.Instructions{
+			code.Make(code.OpConstant, 0),
+			code.Make(code.OpSetGlobal, 0),
+			code.Make(code.OpGetGlobal, 0),
+			code.Make(code.OpPop),
+		},
+	}, {
+		`let one = 1;`,
+		[]code.Instructions{
+			code.Make(code.OpConstant, 0),
+			code.Make(code.OpSetGlobal, 0),
+			code.Make(code.OpGetGlobal, 0),
+			code.Make(code.OpPop),
+		},
+	}, {
+		`len([1])`,
+		[]code.Instructions{
+			code.Make(code.OpGetGlobal, 0),
+			code.Make(code.OpConstant, 0),
+			code.Make(code.OpArray, 1),
+			code.Make(code.OpCall, 1),
+			code.Make(code.OpPop),
+		},
+	}, {
+		`{1: 2}[1]`,
+		[]code.Instructions{
+			code.Make(code.OpConstant, 0),
+			code.Make(code.OpConstant, 1),
+			code.Make(code.OpHash, 2),
+			code.Make(code.OpConstant, 2),
+			code.Make(code.OpIndex),
+			code.Make(code.OpPop),
+		},
+	}}
+
+	for _, tc := range tests {
+		bytecode := compileInput(t, tc.input)
+		assert.Equal(t, concat(tc.expInstructions).String(), bytecode.Instructions.String(), tc.input)
+	}
+}
+
+func TestCompileFunctions(t *testing.T) {
+	bytecode := compileInput(t, `fn(a, b = a) { let c = b; c }`)
+	assert.Equal(t, concat([]code.Instructions{
+		code.Make(code.OpClosure, 0, 0),
+		code.Make(code.OpPop),
+	}).String(), bytecode.Instructions.String())
+
+	require.Len(t, bytecode.Constants, 1)
+	fn := bytecode.Constants[0].(*object.CompiledFunction)
+	assert.Equal(t, concat([]code.Instructions{
+		code.Make(code.OpJumpIfArg, 1, 8),
+		code.Make(code.OpGetLocal, 0),
+		code.Make(code.OpSetLocal, 1),
+		code.Make(code.OpGetLocal, 1),
+		code.Make(code.OpSetLocal, 2),
+		code.Make(code.OpGetLocal, 2),
+		code.Make(code.OpReturnValue),
+	}).String(), fn.Instructions.String())
+	assert.Equal(t, 3, fn.NumLocals)
+	assert.Equal(t, 2, fn.NumParams)
+	assert.Equal(t, 1, fn.NumRequired)
+	assert.Equal(t, []string{`a`, `b`, `c`}, fn.LocalNames)
+}
+
+func TestCompileClosures(t *testing.T) {
+	bytecode := compileInput(t, `let f = fn(a) { fn() { f(a) } }`)
+	require.Len(t, bytecode.Constants, 2)
+
+	inner := bytecode.Constants[0].(*object.CompiledFunction)
+	assert.Equal(t, concat([]code.Instructions{
+		code.Make(code.OpGetFree, 0),
+		code.Make(code.OpGetFree, 1),
+		code.Make(code.OpCall, 1),
+		code.Make(code.OpReturnValue),
+	}).String(), inner.Instructions.String())
+
+	outer := bytecode.Constants[1].(*object.CompiledFunction)
+	assert.Equal(t, concat([]code.Instructions{
+		code.Make(code.OpCurrentClosure),
+		code.Make(code.OpGetLocal, 0),
+		code.Make(code.OpClosure, 0, 2),
+		code.Make(code.OpReturnValue),
+	}).String(), outer.Instructions.String())
+}
+
+func TestCompileAssignedClosures(t *testing.T) {
+	bytecode := compileInput(t, `fn(n) { fn() { n += 1 } }`)
+	require.Len(t, bytecode.Constants, 3)
+
+	inner := bytecode.Constants[1].(*object.CompiledFunction)
+	assert.Equal(t, concat([]code.Instructions{
+		code.Make(code.OpGetFreeCell, 0),
+		code.Make(code.OpConstant, 0),
+		code.Make(code.OpAdd),
+		code.Make(code.OpSetFreeCell, 0),
+		code.Make(code.OpGetFreeCell, 0),
+		code.Make(code.OpReturnValue),
+	}).String(), inner.Instructions.String())
+
+	outer := bytecode.Constants[2].(*object.CompiledFunction)
+	assert.Equal(t, concat([]code.Instructions{
+		code.Make(code.OpGetLocal, 0),
+		code.Make(code.OpSetLocalCell, 0),
+		code.Make(code.OpCaptureLocal, 0),
+		code.Make(code.OpClosure, 1, 1),
+		code.Make(code.OpReturnValue),
+	}).String(), outer.Instructions.String())
+}
+
+func TestCompileIndexAssignment(t *testing.T) {
+	bytecode := compileInput(t, `let a = [1]; a[0] *= 2`)
+	assert.Equal(t, concat([]code.Instructions{
+		code.Make(code.OpConstant, 0),
+		code.Make(code.OpArray, 1),
+		code.Make(code.OpSetGlobal, 0),
+		code.Make(code.OpGetGlobal, 0),
+		code.Make(code.OpConstant, 1),
+		code.Make(code.OpDupPair),
+		code.Make(code.OpIndex),
+		code.Make(code.OpConstant, 2),
+		code.Make(code.OpMul),
+		code.Make(code.OpSetIndex),
+		code.Make(code.OpPop),
+	}).String(), bytecode.Instructions.String())
+}
+
+func TestCompileLoops(t *testing.T) {
+	tests := []struct {
+		input           string
+		expInstructions []code.Instructions
+	}{{
+		`while (true) { break }`,
+		[]code.Instructions{
+			code.Make(code.OpTrue),
+			code.Make(code.OpJumpNotTruthy, 11),
+			code.Make(code.OpJump, 11),
+			code.Make(code.OpPop),
+			code.Make(code.OpJump, 0),
+			code.Make(code.OpNull),
+			code.Make(code.OpPop),
+		},
+	}, {
+		// The break pops the 1 and the iterator before leaving the loop.
+		`for (x in []) { 1 + if (x) { break } }`,
+		[]code.Instructions{
+			code.Make(code.OpArray, 0),
+			code.Make(code.OpIter),
+			code.Make(code.OpIterNext, 33),
+			code.Make(code.OpSetGlobal, 0),
+			code.Make(code.OpConstant, 0),
+			code.Make(code.OpGetGlobal, 0),
+			code.Make(code.OpJumpNotTruthy, 27),
+			code.Make(code.OpPop),
+			code.Make(code.OpPop),
+			code.Make(code.OpJump, 33),
+			code.Make(code.OpJump, 28),
+			code.Make(code.OpNull),
+			code.Make(code.OpAdd),
+			code.Make(code.OpPop),
+			code.Make(code.OpJump, 4),
+			code.Make(code.OpNull),
+			code.Make(code.OpPop),
+		},
+	}}
+
+	for _, tc := range tests {
+		bytecode := compileInput(t, tc.input)
+		assert.Equal(t, concat(tc.expInstructions).String(), bytecode.Instructions.String(), tc.input)
+	}
+}
+
+func TestCompileTry(t *testing.T) {
+	tests := []struct {
+		input           string
+		expInstructions []code.Instructions
+	}{{
+		`try { 1 } catch (e) { e }`,
+		[]code.Instructions{
+			code.Make(code.OpTry, 10),
+			code.Make(code.OpConstant, 0),
+			code.Make(code.OpEndTry),
+			code.Make(code.OpJump, 17),
+			code.Make(code.OpCaught),
+			code.Make(code.OpSetGlobal, 0),
+			code.Make(code.OpGetGlobal, 0),
+			code.Make(code.OpPop),
+		},
+	}, {
+		// The handler runs a copy of the finally clause and raises the
+		// error again.
+		`try { 1 } finally { 2 }`,
+		[]code.Instructions{
+			code.Make(code.OpTry, 14),
+			code.Make(code.OpConstant, 0),
+			code.Make(code.OpEndTry),
+			code.Make(code.OpConstant, 1),
+			code.Make(code.OpPop),
+			code.Make(code.OpJump, 19),
+			code.Make(code.OpConstant, 2),
+			code.Make(code.OpPop),
+			code.Make(code.OpThrow),
+			code.Make(code.OpPop),
+		},
+	}, {
+		// The break leaves the try expression, running its finally clause.
+		`while (true) { try { break } finally { 1 } }`,
+		[]code.Instructions{
+			code.Make(code.OpTrue),
+			code.Make(code.OpJumpNotTruthy, 32),
+			code.Make(code.OpTry, 23),
+			code.Make(code.OpEndTry),
+			code.Make(code.OpConstant, 0),
+			code.Make(code.OpPop),
+			code.Make(code.OpJump, 32),
+			code.Make(code.OpEndTry),
+			code.Make(code.OpConstant, 1),
+			code.Make(code.OpPop),
+			code.Make(code.OpJump, 28),
+			code.Make(code.OpConstant, 2),
+			code.Make(code.OpPop),
+			code.Make(code.OpThrow),
+			code.Make(code.OpPop),
+			code.Make(code.OpJump, 0),
+			code.Make(code.OpNull),
+			code.Make(code.OpPop),
+		},
+	}}
+
+	for _, tc := range tests {
+		bytecode := compileInput(t, tc.input)
+		assert.Equal(t, concat(tc.expInstructions).String(), bytecode.Instructions.String(), tc.input)
+	}
+}
+
+func TestPositions(t *testing.T) {
+	bytecode := compileInput(t, "let x = 1;\nx + true")
+	// OpConstant, OpSetGlobal, OpGetGlobal, OpTrue, OpAdd
+	assert.Equal(t, `2:1`, bytecode.Positions[10].String())
+}
+
+func TestOperandLimits(t *testing.T) {
+	names := func(n int) []string {
+		var out []string
+		for i := 0; i < n; i++ {
+			out = append(out, fmt.Sprintf(`a%d`, i))
+		}
+		return out
+	}
+	lets := func(names []string) string {
+		var b strings.Builder
+		for _, name := range names {
+			fmt.Fprintf(&b, "let %s = true;\n", name)
+		}
+		return b.String()
+	}
+
+	tests := []struct {
+		input  string
+		expErr string
+	}{{
+		input:  "let x = 0;\n" + strings.Repeat("x += 1;\n", 65536),
+		expErr: `65537:6: too many constants: 65536`,
+	}, {
+		input:  lets(names(65537)),
+		expErr: `65537:1: too many global bindings: 65536`,
+	}, {
+		input:  "let x = true;\nif (false) {\n" + strings.Repeat("x;\n", 17000) + "}",
+		expErr: `2:1: too much code to jump over: 68010`,
+	}, {
+		input:  "let f = fn() {\n" + lets(names(257)) + "}",
+		expErr: `258:1: too many local bindings: 256`,
+	}, {
+		input:  "let f = fn() {\n" + lets(names(256)) + "fn() { [" + strings.Join(names(256), `, `) + "] }\n}",
+		expErr: `258:1: too many free variables: 256`,
+	}, {
+		input:  `[` + strings.Repeat(`true, `, 65535) + `true]`,
+		expErr: `1:1: too many array elements: 65536`,
+	}, {
+		input:  `{` + strings.Repeat(`true: true, `, 32767) + `true: true}`,
+		expErr: `1:1: too many hash elements: 65536`,
+	}}
+
+	for _, tc := range tests {
+		p := parser.New(lexer.New(tc.input))
+		program := p.ParseProgram()
+		require.Empty(t, p.Errors())
+
+		err := New().Compile(program)
+		assert.EqualError(t, err, tc.expErr)
+	}
+}
+
+func compileInput(t *testing.T, input string) *Bytecode {
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	require.Empty(t, p.Errors(), input)
+
+	c := New()
+	require.NoError(t, c.Compile(program), input)
+	return c.Bytecode()
+}
+
+func concat(ins []code.Instructions) code.Instructions {
+	var out code.Instructions
+	for _, i := range ins {
+		out = append(out, i...)
+	}
+	return out
+}
@@ -0,0 +1,141 @@
+package compiler
+
+type SymbolScope string
+
+const (
+	GlobalScope   SymbolScope = "GLOBAL"
+	LocalScope    SymbolScope = "LOCAL"
+	FreeScope     SymbolScope = "FREE"
+	FunctionScope SymbolScope = "FUNCTION"
+)
+
+// Symbol is a named slot. A Constant symbol cannot be assigned to. A Boxed
+// symbol's slot holds a cell containing its value, so that closures share the
+// variable rather than copying its value.
+type Symbol struct {
+	Name     string
+	Scope    SymbolScope
+	Index    int
+	Constant bool
+	Boxed    bool
+}
+
+// SymbolTable maps names to the slots that hold them. Each function body gets
+// its own table, enclosed by the table of the scope the function is defined
+// in.
+type SymbolTable struct {
+	Outer *SymbolTable
+
+	store          map[string]Symbol
+	names          []string
+	numDefinitions int
+
+	// locals names every variable the function binds, so that nested
+	// functions can capture those defined after them. boxed names the locals
+	// which are to be boxed when they are defined.
+	locals map[string]bool
+	boxed  map[string]bool
+
+	FreeSymbols []Symbol
+}
+
+func NewSymbolTable() *SymbolTable {
+	return &SymbolTable{store: make(map[string]Symbol)}
+}
+
+func NewEnclosedSymbolTable(outer *SymbolTable) *SymbolTable {
+	s := NewSymbolTable()
+	s.Outer = outer
+	return s
+}
+
+// Define returns the slot for name in this table, allocating one if name was
+// not defined here before. Redefining a name reuses its slot.
+func (s *SymbolTable) Define(name string) Symbol {
+	return s.define(name, false)
+}
+
+// DefineConst is like Define, but makes the symbol constant.
+func (s *SymbolTable) DefineConst(name string) Symbol {
+	return s.define(name, true)
+}
+
+func (s *SymbolTable) define(name string, constant bool) Symbol {
+	sym, ok := s.store[name]
+	if !ok || sym.Scope != GlobalScope && sym.Scope != LocalScope {
+		sym = Symbol{Name: name, Index: s.numDefinitions, Scope: LocalScope}
+		if s.Outer == nil {
+			sym.Scope = GlobalScope
+		}
+		sym.Boxed = sym.Scope == LocalScope && s.boxed[name]
+		s.names = append(s.names, name)
+		s.numDefinitions++
+	}
+	sym.Constant = constant
+	s.store[name] = sym
+	return sym
+}
+
+// Defined returns the symbol for name if it was defined in this table itself,
+// ignoring the tables enclosing it.
+func (s *SymbolTable) Defined(name string) (Symbol, bool) {
+	sym, ok := s.store[name]
+	return sym, ok && (sym.Scope == GlobalScope || sym.Scope == LocalScope)
+}
+
+// DefineFunctionName makes name refer to the function currently being
+// compiled, so that it can call itself.
+func (s *SymbolTable) DefineFunctionName(name string) Symbol {
+	sym := Symbol{Name: name, Index: 0, Scope: FunctionScope}
+	s.store[name] = sym
+	return sym
+}
+
+func (s *SymbolTable) defineFree(original Symbol) Symbol {
+	s.FreeSymbols = append(s.FreeSymbols, original)
+	sym := Symbol{
+		Name:     original.Name,
+		Index:    len(s.FreeSymbols) - 1,
+		Scope:    FreeScope,
+		Constant: original.Constant,
+		Boxed:    original.Boxed,
+	}
+	s.store[original.Name] = sym
+	return sym
+}
+
+// Resolve looks name up in this table and the tables enclosing it. Locals of
+// enclosing functions become free symbols of this one.
+func (s *SymbolTable) Resolve(name string) (Symbol, bool) {
+	sym, ok := s.store[name]
+	if ok || s.Outer == nil {
+		return sym, ok
+	}
+	sym, ok = s.Outer.resolveEnclosing(name)
+	if !ok || sym.Scope == GlobalScope {
+		return sym, ok
+	}
+	return s.defineFree(sym), true
+}
+
+// resolveEnclosing resolves name for a function nested in this table's. A
+// variable this function binds but has not defined yet is defined now, so
+// the nested function captures it rather than a global of the same name.
+func (s *SymbolTable) resolveEnclosing(name string) (Symbol, bool) {
+	if _, ok := s.store[name]; !ok && s.locals[name] {
+		return s.Define(name), true
+	}
+	return s.Resolve(name)
+}
+
+// Names returns the names of the slots defined in this table, by index.
+func (s *SymbolTable) Names() []string {
+	return s.names
+}
+
+func (s *SymbolTable) global() *SymbolTable {
+	for s.Outer != nil {
+		s = s.Outer
+	}
+	return s
+}
@@ -0,0 +1,69 @@
+package compiler
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResolveFree(t *testing.T) {
+	global := NewSymbolTable()
+	global.Define(`a`)
+	outer := NewEnclosedSymbolTable(global)
+	outer.Define(`b`)
+	inner := NewEnclosedSymbolTable(outer)
+	inner.Define(`c`)
+
+	tests := []struct {
+		name string
+		exp  Symbol
+	}{
+		{`a`, Symbol{Name: `a`, Scope: GlobalScope, Index: 0}},
+		{`b`, Symbol{Name: `b`, Scope: FreeScope, Index: 0}},
+		{`c`, Symbol{Name: `c`, Scope: LocalScope, Index: 0}},
+	}
+	for _, tc := range tests {
+		sym, ok := inner.Resolve(tc.name)
+		assert.True(t, ok, tc.name)
+		assert.Equal(t, tc.exp, sym)
+	}
+	assert.Equal(t, []Symbol{{Name: `b`, Scope: LocalScope, Index: 0}}, inner.FreeSymbols)
+
+	_, ok := inner.Resolve(`d`)
+	assert.False(t, ok)
+}
+
+func TestRedefineReusesSlot(t *testing.T) {
+	s := NewSymbolTable()
+	assert.Equal(t, 0, s.Define(`a`).Index)
+	assert.Equal(t, 1, s.Define(`b`).Index)
+	assert.Equal(t, 0, s.Define(`a`).Index)
+	assert.Equal(t, []string{`a`, `b`}, s.Names())
+}
+
+func TestDefineConst(t *testing.T) {
+	s := NewSymbolTable()
+	assert.True(t, s.DefineConst(`a`).Constant)
+	assert.False(t, s.Define(`b`).Constant)
+
+	inner := NewEnclosedSymbolTable(s)
+	sym, ok := inner.Resolve(`a`)
+	require.True(t, ok)
+	assert.True(t, sym.Constant)
+	_, ok = inner.Defined(`a`)
+	assert.False(t, ok)
+}
+
+func TestResolveLocalDefinedLater(t *testing.T) {
+	global := NewSymbolTable()
+	global.Define(`a`)
+	outer := NewEnclosedSymbolTable(global)
+	outer.locals = map[string]bool{`a`: true}
+	inner := NewEnclosedSymbolTable(outer)
+
+	sym, ok := inner.Resolve(`a`)
+	require.True(t, ok)
+	assert.Equal(t, Symbol{Name: `a`, Scope: FreeScope, Index: 0}, sym)
+	assert.Equal(t, []Symbol{{Name: `a`, Scope: LocalScope, Index: 0}}, inner.FreeSymbols)
+}
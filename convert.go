@@ -0,0 +1,339 @@
+package monkey
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"reflect"
+	"sort"
+
+	"github.com/cszczepaniak/monkey/evaluator"
+	"github.com/cszczepaniak/monkey/object"
+)
+
+var (
+	errorType  = reflect.TypeOf((*error)(nil)).Elem()
+	bigIntType = reflect.TypeOf((*big.Int)(nil))
+)
+
+// ToObject converts a Go value to a Monkey object. Objects are returned
+// as-is, nil becomes null, integers become INTEGER, floats become FLOAT, bools
+// become BOOLEAN, strings become STRING, slices and arrays become ARRAY, maps
+// become HASH and errors become ERROR. Pointers are followed.
+func ToObject(v interface{}) (object.Object, error) {
+	return toObject(reflect.ValueOf(v))
+}
+
+func toObject(v reflect.Value) (object.Object, error) {
+	if !v.IsValid() {
+		return evaluator.NULL, nil
+	}
+	if (v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface) && v.IsNil() {
+		return evaluator.NULL, nil
+	}
+	switch x := v.Interface().(type) {
+	case object.Object:
+		return x, nil
+	case error:
+		return hostError(x), nil
+	case *big.Int:
+		if x.IsInt64() {
+			return &object.Integer{Value: x.Int64()}, nil
+		}
+		return &object.BigInteger{Value: new(big.Int).Set(x)}, nil
+	}
+
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		return toObject(v.Elem())
+	case reflect.Bool:
+		if v.Bool() {
+			return evaluator.TRUE, nil
+		}
+		return evaluator.FALSE, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return &object.Integer{Value: v.Int()}, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if v.Uint() > math.MaxInt64 {
+			return nil, fmt.Errorf(`%d overflows INTEGER`, v.Uint())
+		}
+		return &object.Integer{Value: int64(v.Uint())}, nil
+	case reflect.Float32, reflect.Float64:
+		return &object.Float{Value: v.Float()}, nil
+	case reflect.String:
+		return &object.String{Value: v.String()}, nil
+	case reflect.Slice, reflect.Array:
+		elements := make([]object.Object, v.Len())
+		for i := range elements {
+			el, err := toObject(v.Index(i))
+			if err != nil {
+				return nil, err
+			}
+			elements[i] = el
+		}
+		return &object.Array{Elements: elements}, nil
+	case reflect.Map:
+		return mapToHash(v)
+	default:
+		return nil, fmt.Errorf(`unsupported Go type %s`, v.Type())
+	}
+}
+
+// mapToHash converts a Go map to a hash. Go maps are unordered, so the keys
+// are sorted to give the hash a deterministic order.
+func mapToHash(v reflect.Value) (object.Object, error) {
+	pairs := make([]object.HashPair, 0, v.Len())
+	iter := v.MapRange()
+	for iter.Next() {
+		k, err := toObject(iter.Key())
+		if err != nil {
+			return nil, err
+		}
+		key, ok := k.(object.Hashable)
+		if !ok {
+			return nil, fmt.Errorf(`unusable as hash key: %s`, k.Type())
+		}
+		val, err := toObject(iter.Value())
+		if err != nil {
+			return nil, err
+		}
+		pairs = append(pairs, object.HashPair{Key: key, Value: val})
+	}
+	sort.Slice(pairs, func(i, j int) bool {
+		return pairs[i].Key.Inspect() < pairs[j].Key.Inspect()
+	})
+
+	hash := object.NewHash()
+	for _, pair := range pairs {
+		hash.Set(pair.Key, pair.Value)
+	}
+	return hash, nil
+}
+
+// FromObject converts a Monkey object to its natural Go representation:
+// INTEGER to int64 (or *big.Int if it does not fit), FLOAT to float64,
+// BOOLEAN to bool, STRING to string, null to nil, ARRAY to []interface{} and
+// HASH to map[interface{}]interface{}. Other objects are returned unchanged.
+func FromObject(obj object.Object) interface{} {
+	switch o := obj.(type) {
+	case *object.Integer:
+		return o.Value
+	case *object.BigInteger:
+		return new(big.Int).Set(o.Value)
+	case *object.Float:
+		return o.Value
+	case *object.Boolean:
+		return o.Value
+	case *object.String:
+		return o.Value
+	case *object.Null:
+		return nil
+	case *object.Array:
+		elements := make([]interface{}, len(o.Elements))
+		for i, el := range o.Elements {
+			elements[i] = FromObject(el)
+		}
+		return elements
+	case *object.Hash:
+		m := make(map[interface{}]interface{}, o.Len())
+		for _, pair := range o.Pairs() {
+			m[FromObject(pair.Key)] = FromObject(pair.Value)
+		}
+		return m
+	default:
+		return obj
+	}
+}
+
+// fromObject converts obj to a value of Go type t.
+func fromObject(obj object.Object, t reflect.Type) (reflect.Value, error) {
+	if t.Kind() == reflect.Interface {
+		if t.NumMethod() == 0 {
+			if v := FromObject(obj); v != nil {
+				return reflect.ValueOf(v), nil
+			}
+			return reflect.Zero(t), nil
+		}
+		if reflect.TypeOf(obj).Implements(t) {
+			return reflect.ValueOf(obj), nil
+		}
+		return reflect.Value{}, conversionError(obj, t)
+	}
+	if t == bigIntType {
+		if i := bigInt(obj); i != nil {
+			return reflect.ValueOf(i), nil
+		}
+		return reflect.Value{}, conversionError(obj, t)
+	}
+	if obj.Type() == object.NULL {
+		switch t.Kind() {
+		case reflect.Ptr, reflect.Slice, reflect.Map:
+			return reflect.Zero(t), nil
+		}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		if b, ok := obj.(*object.Boolean); ok {
+			return reflect.ValueOf(b.Value).Convert(t), nil
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if i, ok := obj.(*object.Integer); ok {
+			v := reflect.New(t).Elem()
+			if v.OverflowInt(i.Value) {
+				return reflect.Value{}, fmt.Errorf(`%d overflows %s`, i.Value, t)
+			}
+			v.SetInt(i.Value)
+			return v, nil
+		}
+		if bi, ok := obj.(*object.BigInteger); ok {
+			return reflect.Value{}, fmt.Errorf(`%s overflows %s`, bi.Value, t)
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if i, ok := obj.(*object.Integer); ok {
+			v := reflect.New(t).Elem()
+			if i.Value < 0 || v.OverflowUint(uint64(i.Value)) {
+				return reflect.Value{}, fmt.Errorf(`%d overflows %s`, i.Value, t)
+			}
+			v.SetUint(uint64(i.Value))
+			return v, nil
+		}
+		if bi, ok := obj.(*object.BigInteger); ok {
+			return reflect.Value{}, fmt.Errorf(`%s overflows %s`, bi.Value, t)
+		}
+	case reflect.Float32, reflect.Float64:
+		switch n := obj.(type) {
+		case *object.Float:
+			return reflect.ValueOf(n.Value).Convert(t), nil
+		case *object.Integer:
+			return reflect.ValueOf(float64(n.Value)).Convert(t), nil
+		}
+	case reflect.String:
+		if s, ok := obj.(*object.String); ok {
+			return reflect.ValueOf(s.Value).Convert(t), nil
+		}
+	case reflect.Slice:
+		if arr, ok := obj.(*object.Array); ok {
+			v := reflect.MakeSlice(t, len(arr.Elements), len(arr.Elements))
+			for i, el := range arr.Elements {
+				ev, err := fromObject(el, t.Elem())
+				if err != nil {
+					return reflect.Value{}, err
+				}
+				v.Index(i).Set(ev)
+			}
+			return v, nil
+		}
+	case reflect.Map:
+		if hash, ok := obj.(*object.Hash); ok {
+			v := reflect.MakeMapWithSize(t, hash.Len())
+			for _, pair := range hash.Pairs() {
+				kv, err := fromObject(pair.Key, t.Key())
+				if err != nil {
+					return reflect.Value{}, err
+				}
+				vv, err := fromObject(pair.Value, t.Elem())
+				if err != nil {
+					return reflect.Value{}, err
+				}
+				v.SetMapIndex(kv, vv)
+			}
+			return v, nil
+		}
+	}
+	return reflect.Value{}, conversionError(obj, t)
+}
+
+func bigInt(obj object.Object) *big.Int {
+	switch i := obj.(type) {
+	case *object.Integer:
+		return big.NewInt(i.Value)
+	case *object.BigInteger:
+		return new(big.Int).Set(i.Value)
+	default:
+		return nil
+	}
+}
+
+func conversionError(obj object.Object, t reflect.Type) error {
+	return fmt.Errorf(`cannot use %s as %s`, obj.Type(), t)
+}
+
+// newBuiltin wraps a Go function in a builtin which converts its arguments
+// and results.
+func newBuiltin(name string, fn reflect.Value) (*object.Builtin, error) {
+	t := fn.Type()
+	switch {
+	case t.NumOut() > 2,
+		t.NumOut() == 2 && t.Out(1) != errorType:
+		return nil, fmt.Errorf(`unsupported return signature %s`, t)
+	}
+
+	call := func(args ...object.Object) object.Object {
+		if err := checkArity(t, len(args)); err != nil {
+			return err
+		}
+		in := make([]reflect.Value, len(args))
+		for i, arg := range args {
+			pt := paramType(t, i)
+			v, err := fromObject(arg, pt)
+			if err != nil {
+				return &object.Error{Message: fmt.Sprintf("argument %d to `%s`: %s", i+1, name, err), Kind: object.KindArgument}
+			}
+			in[i] = v
+		}
+		return fromResults(fn.Call(in))
+	}
+	return &object.Builtin{Name: name, Fn: call}, nil
+}
+
+func checkArity(t reflect.Type, got int) *object.Error {
+	if t.IsVariadic() {
+		if want := t.NumIn() - 1; got < want {
+			return &object.Error{Message: fmt.Sprintf(`wrong number of arguments: want at least %d, got %d`, want, got), Kind: object.KindArgument}
+		}
+		return nil
+	}
+	if want := t.NumIn(); got != want {
+		return &object.Error{Message: fmt.Sprintf(`wrong number of arguments: want %d, got %d`, want, got), Kind: object.KindArgument}
+	}
+	return nil
+}
+
+func paramType(t reflect.Type, i int) reflect.Type {
+	if t.IsVariadic() && i >= t.NumIn()-1 {
+		return t.In(t.NumIn() - 1).Elem()
+	}
+	return t.In(i)
+}
+
+func fromResults(out []reflect.Value) object.Object {
+	if len(out) > 0 && out[len(out)-1].Type() == errorType {
+		if err := out[len(out)-1]; !err.IsNil() {
+			return hostError(err.Interface().(error))
+		}
+		out = out[:len(out)-1]
+	}
+	if len(out) == 0 {
+		return evaluator.NULL
+	}
+	obj, err := toObject(out[0])
+	if err != nil {
+		return &object.Error{Message: err.Error(), Kind: object.KindRuntime}
+	}
+	return obj
+}
+
+// hostError converts an error returned by a host function. An *object.Error
+// keeps its kind, so a host function can say why it failed; any other error
+// is of kind object.KindRuntime. The error is copied, since the evaluator
+// records where it happened in it.
+func hostError(err error) *object.Error {
+	var objErr *object.Error
+	if errors.As(err, &objErr) {
+		copied := *objErr
+		return &copied
+	}
+	return &object.Error{Message: err.Error(), Kind: object.KindRuntime}
+}
@@ -0,0 +1,141 @@
+// Package diagnostics renders errors and warnings in Monkey programs for
+// people to read, quoting the line of source each one points at.
+package diagnostics
+
+import (
+	"strings"
+
+	"github.com/cszczepaniak/monkey/ast"
+	"github.com/cszczepaniak/monkey/evaluator"
+	"github.com/cszczepaniak/monkey/lexer"
+	"github.com/cszczepaniak/monkey/object"
+	"github.com/cszczepaniak/monkey/parser"
+	"github.com/cszczepaniak/monkey/token"
+)
+
+// Severity is how serious a diagnostic is.
+type Severity int
+
+const (
+	Error Severity = iota
+	Warning
+)
+
+func (s Severity) String() string {
+	if s == Warning {
+		return `warning`
+	}
+	return `error`
+}
+
+// Diagnostic is a problem found at a span of a program's source.
+type Diagnostic struct {
+	Severity Severity
+	Message  string
+
+	// Pos is where the span starts, and End where it ends. If End is
+	// invalid, the span is the token at Pos.
+	Pos, End token.Position
+
+	// Notes are further lines of explanation, such as the calls which led
+	// to a runtime error.
+	Notes []string
+
+	// Suggestions are names the program may have meant instead of the one
+	// the diagnostic is about, closest first.
+	Suggestions []string
+}
+
+// FromParseError returns a diagnostic for a lexical or syntax error.
+func FromParseError(err *parser.Error) *Diagnostic {
+	d := &Diagnostic{Severity: Error, Message: err.Msg, Pos: err.Pos}
+	if err.Found.Type != `` {
+		d.End = err.Found.End
+	}
+	return d
+}
+
+// FromParseErrors returns a diagnostic for each of errs.
+func FromParseErrors(errs []*parser.Error) []*Diagnostic {
+	ds := make([]*Diagnostic, len(errs))
+	for i, err := range errs {
+		ds[i] = FromParseError(err)
+	}
+	return ds
+}
+
+const notFound = `identifier not found: `
+
+// FromRuntimeError returns a diagnostic for err, an error raised by running
+// src, with its call stack as notes. If err is about an identifier that was
+// not found, identifiers spelled like it are suggested from those in scope
+// where it happened: names, which should hold those bound where src ran, the
+// builtins and the variables of the functions in src enclosing err.
+func FromRuntimeError(err *object.Error, src string, names []string) *Diagnostic {
+	d := &Diagnostic{
+		Severity: Error,
+		Message:  err.Message,
+		Pos:      err.Pos,
+		End:      err.End,
+		Notes:    err.StackLines(),
+	}
+	if err.Kind == object.KindName && strings.HasPrefix(err.Message, notFound) {
+		candidates := append([]string{}, names...)
+		candidates = append(candidates, evaluator.BuiltinNames()...)
+		candidates = append(candidates, ScopeNames(src, err.Pos)...)
+		d.Suggestions = Suggest(strings.TrimPrefix(err.Message, notFound), candidates)
+	}
+	return d
+}
+
+// ScopeNames returns the names bound by the functions in src whose bodies
+// enclose pos, innermost first: their parameters and the variables they
+// define, but not those of functions nested in them.
+func ScopeNames(src string, pos token.Position) []string {
+	if !pos.IsValid() {
+		return nil
+	}
+	var names []string
+	program := parser.New(lexer.New(src)).ParseProgram()
+	ast.Inspect(program, func(n ast.Node) bool {
+		fl, ok := n.(*ast.FunctionLiteral)
+		if !ok {
+			return true
+		}
+		if pos.Offset < fl.Pos().Offset || pos.Offset >= fl.End().Offset {
+			return false
+		}
+		names = append(functionNames(fl), names...)
+		return true
+	})
+	return names
+}
+
+// functionNames returns the names fl binds.
+func functionNames(fl *ast.FunctionLiteral) []string {
+	var names []string
+	for _, a := range fl.Args {
+		names = append(names, a.Value)
+	}
+	if fl.Rest != nil {
+		names = append(names, fl.Rest.Value)
+	}
+	ast.Inspect(fl.Body, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.LetStatement:
+			names = append(names, n.Name.Value)
+		case *ast.ForExpression:
+			if n.Variable != nil {
+				names = append(names, n.Variable.Value)
+			}
+		case *ast.TryExpression:
+			if n.CatchVar != nil {
+				names = append(names, n.CatchVar.Value)
+			}
+		case *ast.FunctionLiteral:
+			return false
+		}
+		return true
+	})
+	return names
+}
@@ -0,0 +1,197 @@
+package diagnostics
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/cszczepaniak/monkey/lexer"
+	"github.com/cszczepaniak/monkey/object"
+	"github.com/cszczepaniak/monkey/parser"
+	"github.com/cszczepaniak/monkey/token"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func pos(line, column, offset int) token.Position {
+	return token.Position{Line: line, Column: column, Offset: offset}
+}
+
+func TestFprint(t *testing.T) {
+	tests := []struct {
+		name     string
+		filename string
+		src      string
+		d        Diagnostic
+		exp      string
+	}{{
+		name: `span`,
+		src:  `let x = fooo + 1;`,
+		d: Diagnostic{
+			Message:     `identifier not found: fooo`,
+			Pos:         pos(1, 9, 8),
+			End:         pos(1, 13, 12),
+			Suggestions: []string{`foo`, `food`},
+		},
+		exp: "1:9: error: identifier not found: fooo\n" +
+			" 1 | let x = fooo + 1;\n" +
+			"   |         ^^^^\n" +
+			"   = help: did you mean foo or food?\n",
+	}, {
+		name: `token at position`,
+		src:  "let a = 1;\nlen(a) + \"abc\"",
+		d:    Diagnostic{Message: `type mismatch: INTEGER + STRING`, Pos: pos(2, 10, 20)},
+		exp: "2:10: error: type mismatch: INTEGER + STRING\n" +
+			" 2 | len(a) + \"abc\"\n" +
+			"   |          ^^^^^\n",
+	}, {
+		name: `tabs and wide characters`,
+		src:  "\tlet é = z;",
+		d:    Diagnostic{Message: `identifier not found: z`, Pos: pos(1, 10, 10)},
+		exp: "1:10: error: identifier not found: z\n" +
+			" 1 | \tlet é = z;\n" +
+			"   | \t        ^\n",
+	}, {
+		name: `span over several lines`,
+		src:  "let f = fn() {\n  1\n};",
+		d:    Diagnostic{Message: `unused`, Severity: Warning, Pos: pos(1, 9, 8), End: pos(3, 2, 20)},
+		exp: "1:9: warning: unused\n" +
+			" 1 | let f = fn() {\n" +
+			"   |         ^^^^^^\n",
+	}, {
+		name: `end of input`,
+		src:  "1 +\n",
+		d:    Diagnostic{Message: `no prefix parse function for EOF found`, Pos: pos(2, 1, 4), End: pos(2, 1, 4)},
+		exp: "2:1: error: no prefix parse function for EOF found\n" +
+			" 2 | \n" +
+			"   | ^\n",
+	}, {
+		name: `wide gutter and notes`,
+		src:  strings.Repeat("\n", 9) + `x / 0`,
+		d: Diagnostic{
+			Message: `division by zero`,
+			Pos:     pos(10, 1, 9),
+			Notes:   []string{`in f, called at 12:1`},
+		},
+		exp: "10:1: error: division by zero\n" +
+			" 10 | x / 0\n" +
+			"    | ^\n" +
+			"    = note: in f, called at 12:1\n",
+	}, {
+		name:     `other file`,
+		filename: `main.mk`,
+		src:      `x / 0`,
+		d: Diagnostic{
+			Message: `division by zero`,
+			Pos:     token.Position{Filename: `lib.mk`, Line: 1, Column: 1},
+			Notes:   []string{`in f, called at main.mk:1:1`},
+		},
+		exp: "lib.mk:1:1: error: division by zero\n" +
+			"  = note: in f, called at main.mk:1:1\n",
+	}, {
+		name: `position disagreeing with source`,
+		src:  `let a = 1; b`,
+		d:    Diagnostic{Message: `identifier not found: b`, Pos: pos(1, 12, 10)},
+		exp:  "1:12: error: identifier not found: b\n",
+	}, {
+		name: `no source`,
+		d:    Diagnostic{Message: `identifier not found: b`, Pos: pos(1, 1, 0)},
+		exp:  "1:1: error: identifier not found: b\n",
+	}, {
+		name: `no position`,
+		src:  `b`,
+		d:    Diagnostic{Message: `step limit exceeded`},
+		exp:  "error: step limit exceeded\n",
+	}}
+
+	for _, tc := range tests {
+		var out strings.Builder
+		p := Printer{Filename: tc.filename, Src: tc.src}
+		require.NoError(t, p.Fprint(&out, &tc.d), tc.name)
+		assert.Equal(t, tc.exp, out.String(), tc.name)
+	}
+}
+
+func TestFprintColor(t *testing.T) {
+	var out strings.Builder
+	p := Printer{Src: `lenn(x)`, Color: true}
+	d := &Diagnostic{Message: `identifier not found: lenn`, Pos: pos(1, 1, 0), Suggestions: []string{`len`}}
+	require.NoError(t, p.Fprint(&out, d))
+	assert.Equal(t, "\x1b[1m1:1:\x1b[0m \x1b[1;31merror:\x1b[0m \x1b[1midentifier not found: lenn\x1b[0m\n"+
+		"\x1b[1;34m 1 |\x1b[0m lenn(x)\n"+
+		"\x1b[1;34m   |\x1b[0m \x1b[1;31m^^^^\x1b[0m\n"+
+		"\x1b[1;34m   =\x1b[0m \x1b[1;36mhelp:\x1b[0m did you mean len?\n", out.String())
+}
+
+func TestFromParseErrors(t *testing.T) {
+	p := parser.New(lexer.New("let = 1;\nlet x = \"a"))
+	p.ParseProgram()
+	ds := FromParseErrors(p.ErrorList())
+	require.Len(t, ds, 2)
+
+	assert.Equal(t, `Expected next token to be IDENT, got = instead`, ds[0].Message)
+	assert.Equal(t, `1:5`, ds[0].Pos.String())
+	assert.Equal(t, `1:6`, ds[0].End.String())
+
+	// Lexical errors have no span, so the printer finds the token.
+	assert.Equal(t, `unterminated string literal`, ds[1].Message)
+	assert.False(t, ds[1].End.IsValid())
+}
+
+func TestFromRuntimeError(t *testing.T) {
+	err := &object.Error{
+		Message: `identifier not found: totl`,
+		Kind:    object.KindName,
+		Pos:     pos(1, 23, 22),
+		End:     pos(1, 27, 26),
+		Stack:   []object.Frame{{Function: `f`, Pos: pos(2, 1, 26)}},
+	}
+	d := FromRuntimeError(err, "let f = fn(total) { totl }\nf(1)", []string{`f`, `tota`})
+	assert.Equal(t, `identifier not found: totl`, d.Message)
+	assert.Equal(t, err.Pos, d.Pos)
+	assert.Equal(t, err.End, d.End)
+	assert.Equal(t, []string{`in f, called at 2:1`}, d.Notes)
+	assert.Equal(t, []string{`tota`, `total`}, d.Suggestions)
+
+	// Variables of functions not enclosing the error are out of scope.
+	err = &object.Error{Message: `identifier not found: coutn`, Kind: object.KindName, Pos: pos(1, 36, 35)}
+	d = FromRuntimeError(err, `let f = fn(count) { count }; f(1); coutn`, []string{`f`})
+	assert.Empty(t, d.Suggestions)
+	err = &object.Error{Message: `identifier not found: ad`, Kind: object.KindName, Pos: pos(1, 31, 30)}
+	d = FromRuntimeError(err, `let add = fn(a, b) { a + b }; ad`, []string{`add`})
+	assert.Equal(t, []string{`add`}, d.Suggestions)
+
+	d = FromRuntimeError(&object.Error{Message: `identifier not found: lenn`, Kind: object.KindName}, ``, nil)
+	assert.Equal(t, []string{`len`}, d.Suggestions)
+
+	d = FromRuntimeError(&object.Error{Message: `division by zero`, Kind: object.KindArithmetic}, `total`, []string{`total`})
+	assert.Empty(t, d.Suggestions)
+	assert.Empty(t, d.Notes)
+}
+
+func TestSuggest(t *testing.T) {
+	tests := []struct {
+		name       string
+		candidates []string
+		exp        []string
+	}{
+		{`x`, []string{`y`, `xx`}, nil},
+		{`ab`, []string{`ba`, `abc`, `b`, `xy`}, []string{`abc`, `b`, `ba`}},
+		{`lenght`, []string{`length`, `len`, `height`}, []string{`length`, `height`}},
+		{`counter`, []string{`counter`, `count`, `counters`, `encounter`}, []string{`counters`, `count`, `encounter`}},
+		{`puts`, []string{`put`, `put`, `puts`}, []string{`put`}},
+		{`abc`, []string{`abd`, `abe`, `abf`, `abg`}, []string{`abd`, `abe`, `abf`}},
+		{`ärger`, []string{`arger`}, []string{`arger`}},
+	}
+
+	for _, tc := range tests {
+		assert.Equal(t, tc.exp, Suggest(tc.name, tc.candidates), tc.name)
+	}
+}
+
+func TestScopeNames(t *testing.T) {
+	src := `let f = fn(x) { let y = 1; let g = fn(z) { zz }; yy }; w`
+	assert.Equal(t, []string{`z`, `x`, `y`, `g`}, ScopeNames(src, pos(1, 44, 43)))
+	assert.Equal(t, []string{`x`, `y`, `g`}, ScopeNames(src, pos(1, 50, 49)))
+	assert.Empty(t, ScopeNames(src, pos(1, 56, 55)))
+	assert.Empty(t, ScopeNames(src, token.Position{}))
+}
@@ -0,0 +1,135 @@
+package diagnostics
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/cszczepaniak/monkey/lexer"
+)
+
+// ANSI escape sequences for colouring output.
+const (
+	reset  = "\x1b[0m"
+	bold   = "\x1b[1m"
+	red    = "\x1b[1;31m"
+	yellow = "\x1b[1;33m"
+	blue   = "\x1b[1;34m"
+	cyan   = "\x1b[1;36m"
+)
+
+// Printer writes diagnostics, quoting the source they point into.
+type Printer struct {
+	// Filename and Src are the name and contents of the source to quote.
+	// Diagnostics which point anywhere else, or any diagnostic if Src is
+	// empty, are written without a quote.
+	Filename string
+	Src      string
+
+	// Color turns on ANSI colour.
+	Color bool
+}
+
+// Fprint writes d to w: its position, severity and message, then the line of
+// source it points at with its span underlined, then its notes and
+// suggestions. For example:
+//
+//	main.mk:2:5: error: identifier not found: lenght
+//	 2 | x + lenght
+//	   |     ^^^^^^
+//	   = help: did you mean length?
+func (p *Printer) Fprint(w io.Writer, d *Diagnostic) error {
+	var out strings.Builder
+	severity := red
+	if d.Severity == Warning {
+		severity = yellow
+	}
+
+	if d.Pos.IsValid() {
+		out.WriteString(p.paint(bold, d.Pos.String()+`:`) + ` `)
+	}
+	out.WriteString(p.paint(severity, d.Severity.String()+`:`) + ` ` + p.paint(bold, d.Message) + "\n")
+
+	gutter := `  `
+	if line, before, width, ok := p.quote(d); ok {
+		num := strconv.Itoa(d.Pos.Line)
+		gutter = strings.Repeat(` `, len(num)+2)
+		fmt.Fprintf(&out, "%s %s\n", p.paint(blue, ` `+num+` |`), line)
+		fmt.Fprintf(&out, "%s %s%s\n", p.paint(blue, gutter+`|`), indent(before), p.paint(severity, strings.Repeat(`^`, width)))
+	}
+	for _, note := range d.Notes {
+		fmt.Fprintf(&out, "%s %s %s\n", p.paint(blue, gutter+`=`), p.paint(bold, `note:`), note)
+	}
+	if len(d.Suggestions) > 0 {
+		fmt.Fprintf(&out, "%s %s did you mean %s?\n", p.paint(blue, gutter+`=`), p.paint(cyan, `help:`), list(d.Suggestions))
+	}
+
+	_, err := io.WriteString(w, out.String())
+	return err
+}
+
+func (p *Printer) paint(color, s string) string {
+	if !p.Color {
+		return s
+	}
+	return color + s + reset
+}
+
+// quote returns the line of source d starts on, the part of it before d's
+// span, and the width of the span in characters, up to the end of the line.
+// It reports false if d does not point into the source, or the source does
+// not agree with d's position, as when d is about a different version of it.
+func (p *Printer) quote(d *Diagnostic) (line, before string, width int, ok bool) {
+	pos := d.Pos
+	if p.Src == `` || !pos.IsValid() || pos.Filename != p.Filename || pos.Offset < 0 || pos.Offset > len(p.Src) {
+		return ``, ``, 0, false
+	}
+	start := strings.LastIndexByte(p.Src[:pos.Offset], '\n') + 1
+	end := len(p.Src)
+	if i := strings.IndexByte(p.Src[pos.Offset:], '\n'); i >= 0 {
+		end = pos.Offset + i
+	}
+	before = p.Src[start:pos.Offset]
+	if strings.Count(p.Src[:start], "\n")+1 != pos.Line || utf8.RuneCountInString(before)+1 != pos.Column {
+		return ``, ``, 0, false
+	}
+	line = strings.TrimSuffix(p.Src[start:end], "\r")
+
+	spanEnd := d.End.Offset
+	if !d.End.IsValid() {
+		tok := lexer.New(p.Src[pos.Offset:]).NextToken()
+		spanEnd = pos.Offset + tok.End.Offset
+	}
+	if spanEnd > len(line)+start {
+		spanEnd = len(line) + start
+	}
+	width = 1
+	if spanEnd > pos.Offset {
+		width = utf8.RuneCountInString(p.Src[pos.Offset:spanEnd])
+	}
+	return line, before, width, true
+}
+
+// indent returns blanks as wide as s, keeping its tabs, so that text after
+// them lines up with text after s.
+func indent(s string) string {
+	var out strings.Builder
+	for _, r := range s {
+		if r == '\t' {
+			out.WriteRune('\t')
+		} else {
+			out.WriteRune(' ')
+		}
+	}
+	return out.String()
+}
+
+// list joins names as in "a, b or c".
+func list(names []string) string {
+	if len(names) == 1 {
+		return names[0]
+	}
+	return strings.Join(names[:len(names)-1], `, `) + ` or ` + names[len(names)-1]
+}
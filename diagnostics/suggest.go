@@ -0,0 +1,75 @@
+package diagnostics
+
+import (
+	"sort"
+)
+
+// maxSuggestions is the most names Suggest returns.
+const maxSuggestions = 3
+
+// Suggest returns the names among candidates spelled closely enough to name
+// that it may be a typo for one of them, closest first. Names one edit away
+// are close to a name of two letters or more, names two edits away to one of
+// five letters or more, and so on.
+func Suggest(name string, candidates []string) []string {
+	maxDist := (len([]rune(name)) + 1) / 3
+	dists := make(map[string]int)
+	var names []string
+	for _, c := range candidates {
+		if _, ok := dists[c]; ok || c == name {
+			continue
+		}
+		d := distance(name, c)
+		if d <= maxDist {
+			dists[c] = d
+			names = append(names, c)
+		}
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if dists[names[i]] != dists[names[j]] {
+			return dists[names[i]] < dists[names[j]]
+		}
+		return names[i] < names[j]
+	})
+	if len(names) > maxSuggestions {
+		names = names[:maxSuggestions]
+	}
+	return names
+}
+
+// distance returns the number of single-character insertions, deletions,
+// substitutions and swaps of adjacent characters it takes to turn a into b.
+func distance(a, b string) int {
+	s, t := []rune(a), []rune(b)
+	// d[i][j] is the distance between s[:i] and t[:j].
+	d := make([][]int, len(s)+1)
+	for i := range d {
+		d[i] = make([]int, len(t)+1)
+		d[i][0] = i
+	}
+	for j := range d[0] {
+		d[0][j] = j
+	}
+	for i := 1; i <= len(s); i++ {
+		for j := 1; j <= len(t); j++ {
+			cost := 1
+			if s[i-1] == t[j-1] {
+				cost = 0
+			}
+			d[i][j] = minOf(d[i-1][j]+1, d[i][j-1]+1, d[i-1][j-1]+cost)
+			if i > 1 && j > 1 && s[i-1] == t[j-2] && s[i-2] == t[j-1] {
+				d[i][j] = minOf(d[i][j], d[i-2][j-2]+1)
+			}
+		}
+	}
+	return d[len(s)][len(t)]
+}
+
+func minOf(x int, ys ...int) int {
+	for _, y := range ys {
+		if y < x {
+			x = y
+		}
+	}
+	return x
+}
@@ -0,0 +1,164 @@
+package evaluator
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"unicode/utf8"
+
+	"github.com/cszczepaniak/monkey/object"
+)
+
+var builtins = map[string]*object.Builtin{
+	`len`:   {Name: `len`, Fn: builtinLen},
+	`puts`:  {Name: `puts`, Fn: builtinPuts(nil)},
+	`first`: {Name: `first`, Fn: builtinFirst},
+	`last`:  {Name: `last`, Fn: builtinLast},
+	`rest`:  {Name: `rest`, Fn: builtinRest},
+	`push`:  {Name: `push`, Fn: builtinPush},
+	`type`:  {Name: `type`, Fn: builtinType},
+	`range`: {Name: `range`, Fn: builtinRange},
+}
+
+func builtinLen(args ...object.Object) object.Object {
+	if len(args) != 1 {
+		return ArityError(1, 1, false, len(args))
+	}
+	switch arg := args[0].(type) {
+	case *object.String:
+		return &object.Integer{Value: int64(utf8.RuneCountInString(arg.Value))}
+	case *object.Array:
+		return &object.Integer{Value: int64(len(arg.Elements))}
+	case *object.Hash:
+		return &object.Integer{Value: int64(arg.Len())}
+	case *object.Range:
+		return &object.Integer{Value: arg.Len()}
+	default:
+		return newErrorf(object.KindType, "argument to `len` not supported, got %s", arg.Type())
+	}
+}
+
+// builtinPuts returns puts writing to out, or to os.Stdout if out is nil.
+// puts writes each argument on its own line. Strings are written as-is
+// rather than quoted.
+func builtinPuts(out io.Writer) object.BuiltinFunction {
+	return func(args ...object.Object) object.Object {
+		w := out
+		if w == nil {
+			w = os.Stdout
+		}
+		for _, arg := range args {
+			if s, ok := arg.(*object.String); ok {
+				fmt.Fprintln(w, s.Value)
+			} else {
+				fmt.Fprintln(w, arg.Inspect())
+			}
+		}
+		return NULL
+	}
+}
+
+// builtinsWithOutput returns the builtins, with puts writing to out.
+func builtinsWithOutput(out io.Writer) map[string]*object.Builtin {
+	m := make(map[string]*object.Builtin, len(builtins))
+	for name, b := range builtins {
+		m[name] = b
+	}
+	m[`puts`] = &object.Builtin{Name: `puts`, Fn: builtinPuts(out)}
+	return m
+}
+
+func builtinFirst(args ...object.Object) object.Object {
+	arr, err := arrayArgument(`first`, 1, args)
+	if err != nil {
+		return err
+	}
+	if len(arr.Elements) == 0 {
+		return NULL
+	}
+	return arr.Elements[0]
+}
+
+func builtinLast(args ...object.Object) object.Object {
+	arr, err := arrayArgument(`last`, 1, args)
+	if err != nil {
+		return err
+	}
+	if len(arr.Elements) == 0 {
+		return NULL
+	}
+	return arr.Elements[len(arr.Elements)-1]
+}
+
+func builtinRest(args ...object.Object) object.Object {
+	arr, err := arrayArgument(`rest`, 1, args)
+	if err != nil {
+		return err
+	}
+	if len(arr.Elements) == 0 {
+		return NULL
+	}
+	rest := make([]object.Object, len(arr.Elements)-1)
+	copy(rest, arr.Elements[1:])
+	return &object.Array{Elements: rest}
+}
+
+func builtinPush(args ...object.Object) object.Object {
+	arr, err := arrayArgument(`push`, 2, args)
+	if err != nil {
+		return err
+	}
+	elements := make([]object.Object, len(arr.Elements), len(arr.Elements)+1)
+	copy(elements, arr.Elements)
+	return &object.Array{Elements: append(elements, args[1])}
+}
+
+func builtinType(args ...object.Object) object.Object {
+	if len(args) != 1 {
+		return ArityError(1, 1, false, len(args))
+	}
+	return &object.String{Value: string(args[0].Type())}
+}
+
+// builtinRange returns the range from start to stop counting by step. Given
+// one argument, it is the stop, counting from 0; given two, step is 1.
+func builtinRange(args ...object.Object) object.Object {
+	if len(args) < 1 || len(args) > 3 {
+		return ArityError(1, 3, false, len(args))
+	}
+	nums := make([]int64, len(args))
+	for i, arg := range args {
+		n, ok := arg.(*object.Integer)
+		if !ok {
+			return newErrorf(object.KindType, "argument to `range` must be INTEGER, got %s", arg.Type())
+		}
+		nums[i] = n.Value
+	}
+
+	r := &object.Range{Step: 1}
+	switch len(nums) {
+	case 1:
+		r.Stop = nums[0]
+	case 2:
+		r.Start, r.Stop = nums[0], nums[1]
+	case 3:
+		r.Start, r.Stop, r.Step = nums[0], nums[1], nums[2]
+	}
+	if r.Step == 0 {
+		return newErrorf(object.KindArgument, "`range` step must not be zero")
+	}
+	return r
+}
+
+// arrayArgument checks that a builtin was given want arguments and that the
+// first is an array.
+func arrayArgument(name string, want int, args []object.Object) (*object.Array, *object.Error) {
+	if len(args) != want {
+		return nil, ArityError(want, want, false, len(args))
+	}
+	arr, ok := args[0].(*object.Array)
+	if !ok {
+		return nil, newErrorf(object.KindType, "argument to `%s` must be ARRAY, got %s", name, args[0].Type())
+	}
+	return arr, nil
+}
@@ -1,10 +1,17 @@
 package evaluator
 
 import (
+	"context"
 	"fmt"
+	"io"
+	"math"
+	"math/big"
+	"sort"
+	"strings"
 
 	"github.com/cszczepaniak/monkey/ast"
 	"github.com/cszczepaniak/monkey/object"
+	"github.com/cszczepaniak/monkey/token"
 )
 
 var (
@@ -13,74 +20,273 @@ var (
 	FALSE = &object.Boolean{Value: false}
 )
 
+// OverflowMode selects what happens when integer arithmetic overflows int64.
+type OverflowMode int
+
+const (
+	// OverflowWrap wraps around using two's complement, like Go.
+	OverflowWrap OverflowMode = iota
+	// OverflowError makes an overflowing operation evaluate to an error.
+	OverflowError
+	// OverflowPromote computes the exact result, using a big integer when it
+	// does not fit in an int64.
+	OverflowPromote
+)
+
+// Config configures an evaluator. The limits protect a host running
+// untrusted programs; a program exceeding one evaluates to an error whose
+// Kind says which.
+type Config struct {
+	Overflow OverflowMode
+
+	// MaxDepth limits how deeply function calls may nest. Zero means
+	// DefaultMaxDepth, which keeps deep recursion from exhausting the Go
+	// stack. Tail calls count towards it unless MaxSteps is set, so that
+	// endless tail recursion fails under the default config. With a step
+	// limit, the evaluator runs tail calls in constant depth and a
+	// tail-recursive loop may run for any number of iterations; the vm has
+	// no tail call optimization and still counts every call.
+	MaxDepth int
+	// MaxSteps limits the number of steps a program may take: nodes
+	// evaluated by the evaluator, or instructions executed by the vm. Zero
+	// means no limit.
+	MaxSteps int64
+	// MaxAlloc limits the approximate number of bytes a program may
+	// allocate for strings, arrays, hashes and big integers. Zero means no
+	// limit.
+	MaxAlloc int64
+
+	// Out is where puts writes. Nil means os.Stdout.
+	Out io.Writer
+}
+
+// DefaultMaxDepth is the call depth limit used when Config.MaxDepth is zero.
+const DefaultMaxDepth = 10000
+
+type Evaluator struct {
+	config   Config
+	builtins map[string]*object.Builtin
+
+	// budget and depth track the run in progress, if any.
+	budget *Budget
+	depth  int
+}
+
+func New(config Config) *Evaluator {
+	e := &Evaluator{config: config, builtins: builtins}
+	if config.Out != nil {
+		e.builtins = builtinsWithOutput(config.Out)
+	}
+	return e
+}
+
+// Eval evaluates node with the default configuration.
 func Eval(node ast.Node, env *object.Environment) object.Object {
+	return New(Config{}).Eval(node, env)
+}
+
+// ApplyFunction calls a function or builtin object with the default
+// configuration.
+func ApplyFunction(fn object.Object, args []object.Object) object.Object {
+	return New(Config{}).ApplyFunction(fn, args)
+}
+
+// Eval evaluates node in env. It never panics: a panic during evaluation,
+// such as one raised by a host function, is returned as an error object.
+func (e *Evaluator) Eval(node ast.Node, env *object.Environment) object.Object {
+	return e.EvalContext(context.Background(), node, env)
+}
+
+// EvalContext is like Eval, but stops with a KindCanceled error once ctx is
+// done. The limits in the evaluator's Config apply to each call, and to any
+// calls back into the evaluator made by host functions while it runs.
+func (e *Evaluator) EvalContext(ctx context.Context, node ast.Node, env *object.Environment) (res object.Object) {
+	defer e.start(ctx)()
+	defer recoverError(&res)
+	return e.eval(node, env)
+}
+
+// ApplyFunction calls a function or builtin object with the given arguments,
+// as if it had been called from Monkey code. Like Eval, it never panics.
+func (e *Evaluator) ApplyFunction(fn object.Object, args []object.Object) object.Object {
+	return e.ApplyFunctionContext(context.Background(), fn, args)
+}
+
+// ApplyFunctionContext is like ApplyFunction, but stops once ctx is done, as
+// EvalContext does.
+func (e *Evaluator) ApplyFunctionContext(ctx context.Context, fn object.Object, args []object.Object) (res object.Object) {
+	defer e.start(ctx)()
+	defer recoverError(&res)
+	return e.applyFunction(fn, args, token.Position{})
+}
+
+// start begins a run limited by ctx and the evaluator's Config, returning a
+// function which ends it. A run started while another is in progress is part
+// of it.
+func (e *Evaluator) start(ctx context.Context) func() {
+	if e.budget != nil {
+		return func() {}
+	}
+	e.budget = NewBudget(ctx, e.config)
+	return func() {
+		e.budget = nil
+		e.depth = 0
+	}
+}
+
+func recoverError(res *object.Object) {
+	if r := recover(); r != nil {
+		*res = newErrorf(object.KindRuntime, `internal error: %v`, r)
+	}
+}
+
+// eval evaluates node, attributing any error it produces to node unless the
+// error already has a position.
+func (e *Evaluator) eval(node ast.Node, env *object.Environment) object.Object {
+	if node == nil {
+		return newErrorf(object.KindRuntime, `cannot evaluate missing node; the program did not parse`)
+	}
+	if err := e.budget.Step(); err != nil {
+		locate(err, node)
+		return err
+	}
+	res := e.evalNode(node, env)
+	if allocates(node) {
+		if err := e.budget.Alloc(res); err != nil {
+			res = err
+		}
+	}
+	if err, ok := res.(*object.Error); ok && !err.Pos.IsValid() {
+		locate(err, node)
+	}
+	return res
+}
+
+// locate attributes err to node.
+func locate(err *object.Error, node ast.Node) {
+	err.Pos, err.End = node.Pos(), node.End()
+}
+
+func (e *Evaluator) evalNode(node ast.Node, env *object.Environment) object.Object {
 	switch n := node.(type) {
 	case *ast.Program:
-		return evalProgram(n, env)
+		return e.evalProgram(n, env)
 	case *ast.BlockStatement:
-		return evalBlockStatement(n, env)
+		return e.evalBlockStatement(n, env)
 	case *ast.ExpressionStatement:
-		return Eval(n.Expression, env)
+		return e.eval(n.Expression, env)
 	case *ast.CallExpression:
-		fn := Eval(n.Function, env)
-		if fn.Type() == object.ERROR {
-			return fn
-		}
-		args := evalExpressions(n.Args, env)
-		if len(args) == 1 && args[0].Type() == object.ERROR {
-			return args[0]
+		fn, args, err := e.evalCall(n, env)
+		if err != nil {
+			return err
 		}
-		return applyFunction(fn, args)
+		return e.applyFunction(fn, args, n.Pos())
 	case *ast.IfExpression:
-		return evalIfExpression(n, env)
+		return e.evalIfExpression(n, env)
+	case *ast.WhileExpression:
+		return e.evalWhileExpression(n, env)
+	case *ast.ForExpression:
+		return e.evalForExpression(n, env)
+	case *ast.BranchStatement:
+		if n.Token.Type == token.BREAK {
+			return breakSignal
+		}
+		return continueSignal
 	case *ast.ReturnStatement:
-		return evalReturnStatement(n, env)
+		return e.evalReturnStatement(n, env)
+	case *ast.ThrowStatement:
+		return e.evalThrowStatement(n, env)
+	case *ast.TryExpression:
+		return e.evalTryExpression(n, env)
 	case *ast.LetStatement:
-		val := Eval(n.Value, env)
-		if val.Type() == object.ERROR {
+		if env.IsConst(n.Name.Value) {
+			return newErrorf(object.KindName, `cannot redeclare constant %s`, n.Name.Value)
+		}
+		val := e.eval(n.Value, env)
+		if isAbrupt(val) {
 			return val
 		}
+		if _, ok := n.Value.(*ast.FunctionLiteral); ok {
+			val.(*object.Function).Name = n.Name.Value
+		}
+		if n.IsConst() {
+			return env.SetConst(n.Name.Value, val)
+		}
 		return env.Set(n.Name.Value, val)
+	case *ast.AssignExpression:
+		return e.evalAssignExpression(n, env)
 	case *ast.Identifier:
-		val, ok := env.Get(n.Value)
-		if !ok {
-			return newErrorf(`identifier not found: %s`, n.Value)
-		}
-		return val
+		return e.evalIdentifier(n, env)
 	case *ast.IntegerLiteral:
 		return &object.Integer{Value: n.Value}
+	case *ast.BigIntegerLiteral:
+		return e.BigIntegerLiteral(n.Value)
+	case *ast.FloatLiteral:
+		return &object.Float{Value: n.Value}
+	case *ast.StringLiteral:
+		return &object.String{Value: n.Value}
 	case *ast.BooleanLiteral:
 		return nativeBoolToBoolObject(n.Value)
+	case *ast.ArrayLiteral:
+		elements := e.evalExpressions(n.Elements, env)
+		if len(elements) == 1 && isAbrupt(elements[0]) {
+			return elements[0]
+		}
+		return &object.Array{Elements: elements}
+	case *ast.HashLiteral:
+		return e.evalHashLiteral(n, env)
+	case *ast.IndexExpression:
+		left := e.eval(n.Left, env)
+		if isAbrupt(left) {
+			return left
+		}
+		index := e.eval(n.Index, env)
+		if isAbrupt(index) {
+			return index
+		}
+		return evalIndexExpression(left, index)
 	case *ast.FunctionLiteral:
-		return &object.Function{Args: n.Args, Body: n.Body, Env: env}
+		return &object.Function{Args: n.Args, Defaults: n.Defaults, Rest: n.Rest, Body: n.Body, Env: env}
 	case *ast.PrefixExpression:
-		right := Eval(n.Right, env)
-		if right.Type() == object.ERROR {
+		right := e.eval(n.Right, env)
+		if isAbrupt(right) {
 			return right
 		}
-		return evalPrefixExpression(n.Operator, right)
+		return e.evalPrefixExpression(n.Operator, right)
 	case *ast.InfixExpression:
-		left := Eval(n.Left, env)
-		if left.Type() == object.ERROR {
+		if n.Operator == `&&` || n.Operator == `||` {
+			return e.evalLogicalExpression(n, env)
+		}
+		left := e.eval(n.Left, env)
+		if isAbrupt(left) {
 			return left
 		}
-		right := Eval(n.Right, env)
-		if right.Type() == object.ERROR {
+		right := e.eval(n.Right, env)
+		if isAbrupt(right) {
 			return right
 		}
-		return evalInfixExpression(n.Operator, left, right)
+		return e.evalInfixExpression(n.Operator, left, right)
+	case *ast.BadStatement, *ast.BadExpression:
+		err := newErrorf(object.KindRuntime, `cannot evaluate code that did not parse`)
+		locate(err, n)
+		return err
 	default:
-		return nil
+		return newErrorf(object.KindRuntime, `cannot evaluate %T`, node)
 	}
 }
 
-func evalProgram(p *ast.Program, env *object.Environment) object.Object {
+func (e *Evaluator) evalProgram(p *ast.Program, env *object.Environment) object.Object {
 	var res object.Object
 	for _, stmt := range p.Statements {
-		res = Eval(stmt, env)
+		res = e.eval(stmt, env)
 
 		switch r := res.(type) {
 		case *object.ReturnValue:
+			// A host function may evaluate a program from within a
+			// function, where a return can be a tail call.
+			if tc, ok := r.Value.(*tailCall); ok {
+				return e.applyFunction(tc.fn, tc.args, tc.pos)
+			}
 			return r.Value
 		case *object.Error:
 			return r
@@ -89,22 +295,23 @@ func evalProgram(p *ast.Program, env *object.Environment) object.Object {
 	return res
 }
 
-func evalBlockStatement(bs *ast.BlockStatement, env *object.Environment) object.Object {
-	var res object.Object
+func (e *Evaluator) evalBlockStatement(bs *ast.BlockStatement, env *object.Environment) object.Object {
+	var res object.Object = NULL
 	for _, stmt := range bs.Statements {
-		res = Eval(stmt, env)
-		if res != nil && (res.Type() == object.RETURN || res.Type() == object.ERROR) {
+		res = e.eval(stmt, env)
+		switch res.Type() {
+		case object.RETURN, object.ERROR, object.BREAK, object.CONTINUE:
 			return res
 		}
 	}
 	return res
 }
 
-func evalExpressions(exprs []ast.Expression, env *object.Environment) []object.Object {
+func (e *Evaluator) evalExpressions(exprs []ast.Expression, env *object.Environment) []object.Object {
 	var result []object.Object
-	for _, e := range exprs {
-		r := Eval(e, env)
-		if r.Type() == object.ERROR {
+	for _, expr := range exprs {
+		r := e.eval(expr, env)
+		if isAbrupt(r) {
 			return []object.Object{r}
 		}
 		result = append(result, r)
@@ -112,52 +319,366 @@ func evalExpressions(exprs []ast.Expression, env *object.Environment) []object.O
 	return result
 }
 
-func applyFunction(obj object.Object, args []object.Object) object.Object {
-	fn, ok := obj.(*object.Function)
-	if !ok {
-		return newErrorf(`not a function: %s`, obj.Type())
+func (e *Evaluator) evalIdentifier(ident *ast.Identifier, env *object.Environment) object.Object {
+	if val, ok := env.Get(ident.Value); ok {
+		return val
 	}
-	env := object.NewEnclosedEnvironment(fn.Env)
-	for i, a := range fn.Args {
-		env.Set(a.Value, args[i])
+	if builtin, ok := e.builtins[ident.Value]; ok {
+		return builtin
+	}
+	return newErrorf(object.KindName, `identifier not found: %s`, ident.Value)
+}
+
+// evalCall evaluates the function and arguments of a call. If either fails,
+// it returns the result of the failure instead.
+func (e *Evaluator) evalCall(ce *ast.CallExpression, env *object.Environment) (object.Object, []object.Object, object.Object) {
+	fn := e.eval(ce.Function, env)
+	if isAbrupt(fn) {
+		return nil, nil, fn
+	}
+	args := e.evalExpressions(ce.Args, env)
+	if len(args) == 1 && isAbrupt(args[0]) {
+		return nil, nil, args[0]
+	}
+	return fn, args, nil
+}
+
+// applyFunction calls obj with args from the call at pos, which is invalid
+// for a call made by the host. Tail calls made by the function run in the
+// same Go stack frame. When a step limit is set they count as the same call
+// towards the depth limit, so tail recursion can go arbitrarily deep.
+func (e *Evaluator) applyFunction(obj object.Object, args []object.Object, pos token.Position) object.Object {
+	switch fn := obj.(type) {
+	case *object.Function:
+		if err := checkArity(fn, len(args)); err != nil {
+			return err
+		}
+		if err := e.budget.Enter(e.depth); err != nil {
+			return err
+		}
+		e.depth++
+		defer func() { e.depth-- }()
+		return e.trampoline(fn, args, pos)
+	case *object.Builtin:
+		res := callBuiltin(fn, args)
+		if err := e.budget.Alloc(res); err != nil {
+			return err
+		}
+		return res
+	default:
+		return newErrorf(object.KindType, `not a function: %s`, obj.Type())
+	}
+}
+
+// callFunction calls fn with args, returning a tail call made by the
+// function, if any, rather than making it. The caller checks the number of
+// arguments.
+func (e *Evaluator) callFunction(fn *object.Function, args []object.Object) object.Object {
+	// A return in a default value returns from the function, like one in
+	// its body.
+	var result object.Object
+	env, err := e.extendFunctionEnv(fn, args)
+	if err != nil {
+		result = err
+	} else {
+		result = e.evalTailBlock(fn.Body, env)
 	}
-	result := Eval(fn.Body, env)
 	if ret, ok := result.(*object.ReturnValue); ok {
 		return ret.Value
 	}
 	return result
 }
 
-func evalIfExpression(is *ast.IfExpression, env *object.Environment) object.Object {
-	c := Eval(is.Condition, env)
-	if c.Type() == object.ERROR {
+// callBuiltin recovers from panics in builtins itself, rather than leaving it
+// to Eval, so that the resulting error is attributed to the call.
+func callBuiltin(fn *object.Builtin, args []object.Object) (res object.Object) {
+	defer recoverError(&res)
+	return fn.Fn(args...)
+}
+
+// checkArity reports whether fn may be called with got arguments. An arity
+// error is raised by the call, not the function called, so it is checked
+// before the function is entered.
+func checkArity(fn *object.Function, got int) *object.Error {
+	required := 0
+	for i := range fn.Args {
+		if i >= len(fn.Defaults) || fn.Defaults[i] == nil {
+			required++
+		}
+	}
+	if got < required || fn.Rest == nil && got > len(fn.Args) {
+		return ArityError(required, len(fn.Args), fn.Rest != nil, got)
+	}
+	return nil
+}
+
+// extendFunctionEnv binds a function's parameters to args in a new
+// environment. Defaults are evaluated in that environment, so they may refer
+// to earlier parameters.
+func (e *Evaluator) extendFunctionEnv(fn *object.Function, args []object.Object) (*object.Environment, object.Object) {
+	env := object.NewEnclosedEnvironment(fn.Env)
+	for i, a := range fn.Args {
+		if i < len(args) {
+			env.Set(a.Value, args[i])
+			continue
+		}
+		val := e.eval(fn.Defaults[i], env)
+		if isAbrupt(val) {
+			return nil, val
+		}
+		env.Set(a.Value, val)
+	}
+	if fn.Rest != nil {
+		rest := []object.Object{}
+		if len(args) > len(fn.Args) {
+			rest = append(rest, args[len(fn.Args):]...)
+		}
+		env.Set(fn.Rest.Value, &object.Array{Elements: rest})
+	}
+	return env, nil
+}
+
+// ArityError reports a call with got arguments to a function taking between
+// min and max of them, or at least min if it is variadic.
+func ArityError(min, max int, variadic bool, got int) *object.Error {
+	switch {
+	case variadic:
+		return newErrorf(object.KindArgument, `wrong number of arguments: want at least %d, got %d`, min, got)
+	case min != max:
+		return newErrorf(object.KindArgument, `wrong number of arguments: want %d to %d, got %d`, min, max, got)
+	default:
+		return newErrorf(object.KindArgument, `wrong number of arguments: want %d, got %d`, min, got)
+	}
+}
+
+func (e *Evaluator) evalIfExpression(is *ast.IfExpression, env *object.Environment) object.Object {
+	c := e.eval(is.Condition, env)
+	if isAbrupt(c) {
 		return c
 	}
-	if c == NULL || c == FALSE {
+	if !isTruthy(c) {
 		if is.Alternative != nil {
-			return evalBlockStatement(is.Alternative, env)
+			return e.evalBlockStatement(is.Alternative, env)
 		}
 		return NULL
 	}
-	return evalBlockStatement(is.Consequence, env)
+	return e.evalBlockStatement(is.Consequence, env)
+}
+
+// evalLogicalExpression evaluates && and ||, only evaluating the right operand
+// if the left does not decide the result. The result is always a boolean.
+func (e *Evaluator) evalLogicalExpression(ie *ast.InfixExpression, env *object.Environment) object.Object {
+	left := e.eval(ie.Left, env)
+	if isAbrupt(left) {
+		return left
+	}
+	if isTruthy(left) == (ie.Operator == `||`) {
+		return nativeBoolToBoolObject(isTruthy(left))
+	}
+	right := e.eval(ie.Right, env)
+	if isAbrupt(right) {
+		return right
+	}
+	return nativeBoolToBoolObject(isTruthy(right))
+}
+
+// isAbrupt reports whether obj ends the evaluation of the expressions around
+// it. It is either an error, or the result of a return, break or continue,
+// which unwinds to the enclosing function or loop.
+func isAbrupt(obj object.Object) bool {
+	switch obj.Type() {
+	case object.ERROR, object.RETURN, object.BREAK, object.CONTINUE:
+		return true
+	default:
+		return false
+	}
+}
+
+// isTruthy reports whether obj counts as true in a condition. Only false and
+// null are false.
+func isTruthy(obj object.Object) bool {
+	return obj != NULL && obj != FALSE
 }
 
-func evalReturnStatement(rs *ast.ReturnStatement, env *object.Environment) object.Object {
-	res := Eval(rs.ReturnValue, env)
-	if res.Type() == object.ERROR {
+// evalReturnStatement evaluates a return. Inside a function, the returned
+// value is in tail position.
+func (e *Evaluator) evalReturnStatement(rs *ast.ReturnStatement, env *object.Environment) object.Object {
+	var res object.Object
+	if e.depth > 0 {
+		res = e.evalTail(rs.ReturnValue, env)
+	} else {
+		res = e.eval(rs.ReturnValue, env)
+	}
+	if isAbrupt(res) {
 		return res
 	}
 	return &object.ReturnValue{Value: res}
 }
 
-func evalPrefixExpression(op string, right object.Object) object.Object {
+func evalIndexExpression(left, index object.Object) object.Object {
+	switch {
+	case left.Type() == object.ARRAY && index.Type() == object.INTEGER:
+		i, ok := index.(*object.Integer)
+		if !ok {
+			return NULL
+		}
+		return evalArrayIndexExpression(left.(*object.Array), i.Value)
+	case left.Type() == object.HASH:
+		return evalHashIndexExpression(left.(*object.Hash), index)
+	default:
+		return newErrorf(object.KindType, `index operator not supported: %s[%s]`, left.Type(), index.Type())
+	}
+}
+
+// evalAssignExpression stores a value in a variable or an element of an array
+// or hash. The assignment evaluates to the stored value.
+func (e *Evaluator) evalAssignExpression(ae *ast.AssignExpression, env *object.Environment) object.Object {
+	switch target := ae.Target.(type) {
+	case *ast.Identifier:
+		var cur object.Object
+		if ae.Operator != `=` {
+			cur = e.evalIdentifier(target, env)
+			if isAbrupt(cur) {
+				return cur
+			}
+		}
+		val := e.evalAssignedValue(ae, cur, env)
+		if isAbrupt(val) {
+			return val
+		}
+		switch env.Assign(target.Value, val) {
+		case object.ErrUndefined:
+			return newErrorf(object.KindName, `identifier not found: %s`, target.Value)
+		case object.ErrConstant:
+			return newErrorf(object.KindName, `cannot assign to constant %s`, target.Value)
+		}
+		return val
+	case *ast.IndexExpression:
+		left := e.eval(target.Left, env)
+		if isAbrupt(left) {
+			return left
+		}
+		index := e.eval(target.Index, env)
+		if isAbrupt(index) {
+			return index
+		}
+		var cur object.Object
+		if ae.Operator != `=` {
+			cur = evalIndexExpression(left, index)
+			if isAbrupt(cur) {
+				return cur
+			}
+		}
+		val := e.evalAssignedValue(ae, cur, env)
+		if isAbrupt(val) {
+			return val
+		}
+		return evalIndexAssignment(e.budget, left, index, val)
+	default:
+		return newErrorf(object.KindType, `cannot assign to %s`, ae.Target)
+	}
+}
+
+// evalAssignedValue evaluates the value an assignment stores. For a compound
+// assignment such as +=, it applies the operator to cur, the target's current
+// value, and the assignment's value.
+func (e *Evaluator) evalAssignedValue(ae *ast.AssignExpression, cur object.Object, env *object.Environment) object.Object {
+	val := e.eval(ae.Value, env)
+	if isAbrupt(val) || ae.Operator == `=` {
+		return val
+	}
+	return e.evalInfixExpression(strings.TrimSuffix(ae.Operator, `=`), cur, val)
+}
+
+// evalIndexAssignment stores val in an array or hash, charging budget for a
+// new hash entry. Like indexing, negative array indices count from the end,
+// but an index out of range is an error.
+func evalIndexAssignment(budget *Budget, left, index, val object.Object) object.Object {
+	switch {
+	case left.Type() == object.ARRAY && index.Type() == object.INTEGER:
+		arr := left.(*object.Array)
+		n := int64(len(arr.Elements))
+		i, ok := index.(*object.Integer)
+		if !ok || i.Value < -n || i.Value >= n {
+			return newErrorf(object.KindIndex, `index out of range: %s`, index.Inspect())
+		}
+		if i.Value < 0 {
+			arr.Elements[i.Value+n] = val
+		} else {
+			arr.Elements[i.Value] = val
+		}
+		return val
+	case left.Type() == object.HASH:
+		key, ok := index.(object.Hashable)
+		if !ok {
+			return newErrorf(object.KindType, `unusable as hash key: %s`, index.Type())
+		}
+		if left.(*object.Hash).Set(key, val) {
+			if err := budget.AllocHashEntry(); err != nil {
+				return err
+			}
+		}
+		return val
+	default:
+		return newErrorf(object.KindType, `index assignment not supported: %s[%s]`, left.Type(), index.Type())
+	}
+}
+
+func evalHashIndexExpression(hash *object.Hash, index object.Object) object.Object {
+	key, ok := index.(object.Hashable)
+	if !ok {
+		return newErrorf(object.KindType, `unusable as hash key: %s`, index.Type())
+	}
+	val, ok := hash.Get(key)
+	if !ok {
+		return NULL
+	}
+	return val
+}
+
+func (e *Evaluator) evalHashLiteral(hl *ast.HashLiteral, env *object.Environment) object.Object {
+	hash := object.NewHash()
+	for _, pair := range hl.Pairs {
+		key := e.eval(pair.Key, env)
+		if isAbrupt(key) {
+			return key
+		}
+		hashKey, ok := key.(object.Hashable)
+		if !ok {
+			return newErrorf(object.KindType, `unusable as hash key: %s`, key.Type())
+		}
+		val := e.eval(pair.Value, env)
+		if isAbrupt(val) {
+			return val
+		}
+		hash.Set(hashKey, val)
+	}
+	return hash
+}
+
+// evalArrayIndexExpression indexes from the end of the array for negative
+// indices. Indices out of range in either direction yield null.
+func evalArrayIndexExpression(arr *object.Array, i int64) object.Object {
+	n := int64(len(arr.Elements))
+	if i < 0 {
+		i += n
+	}
+	if i < 0 || i >= n {
+		return NULL
+	}
+	return arr.Elements[i]
+}
+
+func (e *Evaluator) evalPrefixExpression(op string, right object.Object) object.Object {
 	switch op {
 	case `!`:
 		return evalBangPrefixExpression(right)
 	case `-`:
-		return evalMinusPrefixExpression(right)
+		return e.evalMinusPrefixExpression(right)
+	case `~`:
+		return evalBitwiseNotPrefixExpression(right)
 	default:
-		return newErrorf(`unknown operator: %s%s`, op, right.Type())
+		return newErrorf(object.KindType, `unknown operator: %s%s`, op, right.Type())
 	}
 }
 
@@ -174,51 +695,115 @@ func evalBangPrefixExpression(right object.Object) object.Object {
 	}
 }
 
-func evalMinusPrefixExpression(right object.Object) object.Object {
-	if right.Type() != object.INTEGER {
-		return newErrorf(`unknown operator: -%s`, right.Type())
+func (e *Evaluator) evalMinusPrefixExpression(right object.Object) object.Object {
+	switch r := right.(type) {
+	case *object.Integer:
+		return e.integerResult(-r.Value, r.Value == math.MinInt64, func() *big.Int {
+			return new(big.Int).Neg(big.NewInt(r.Value))
+		}, `-%d`, r.Value)
+	case *object.BigInteger:
+		return normalizeBigInteger(new(big.Int).Neg(r.Value))
+	case *object.Float:
+		return &object.Float{Value: -r.Value}
+	default:
+		return newErrorf(object.KindType, `unknown operator: -%s`, right.Type())
+	}
+}
+
+func evalBitwiseNotPrefixExpression(right object.Object) object.Object {
+	switch r := right.(type) {
+	case *object.Integer:
+		return &object.Integer{Value: ^r.Value}
+	case *object.BigInteger:
+		return normalizeBigInteger(new(big.Int).Not(r.Value))
+	default:
+		return newErrorf(object.KindType, `unknown operator: ~%s`, right.Type())
 	}
-	integer := right.(*object.Integer)
-	return &object.Integer{Value: -integer.Value}
 }
 
-func evalInfixExpression(op string, left, right object.Object) object.Object {
+func (e *Evaluator) evalInfixExpression(op string, left, right object.Object) object.Object {
 	switch {
 	case left.Type() == object.INTEGER && right.Type() == object.INTEGER:
-		return evalIntegerInfixExpression(op, left, right)
+		return e.evalIntegerInfixExpression(op, left, right)
+	case isNumber(left) && isNumber(right):
+		return evalFloatInfixExpression(op, left, right)
+	case left.Type() == object.STRING && right.Type() == object.STRING:
+		return evalStringInfixExpression(op, left, right)
 	case op == `==`:
 		return nativeBoolToBoolObject(left == right)
 	case op == `!=`:
 		return nativeBoolToBoolObject(left != right)
 	case left.Type() != right.Type():
-		return newErrorf(`type mismatch: %s %s %s`, left.Type(), op, right.Type())
+		return newErrorf(object.KindType, `type mismatch: %s %s %s`, left.Type(), op, right.Type())
 	default:
-		return newErrorf(`unknown operator: %s %s %s`, left.Type(), op, right.Type())
+		return newErrorf(object.KindType, `unknown operator: %s %s %s`, left.Type(), op, right.Type())
 	}
 }
 
-func evalIntegerInfixExpression(op string, left, right object.Object) object.Object {
-	l, r := left.(*object.Integer).Value, right.(*object.Integer).Value
+func evalStringInfixExpression(op string, left, right object.Object) object.Object {
+	l, r := left.(*object.String).Value, right.(*object.String).Value
 	switch op {
 	case `+`:
-		return &object.Integer{Value: l + r}
-	case `-`:
-		return &object.Integer{Value: l - r}
-	case `*`:
-		return &object.Integer{Value: l * r}
-	case `/`:
-		return &object.Integer{Value: l / r}
+		return &object.String{Value: l + r}
 	case `==`:
 		return nativeBoolToBoolObject(l == r)
 	case `!=`:
 		return nativeBoolToBoolObject(l != r)
-	case `>`:
-		return nativeBoolToBoolObject(l > r)
-	case `<`:
-		return nativeBoolToBoolObject(l < r)
 	default:
-		return newErrorf(`unknown operator: %s %s %s`, left.Type(), op, right.Type())
+		return newErrorf(object.KindType, `unknown operator: %s %s %s`, left.Type(), op, right.Type())
+	}
+}
+
+// EvalPrefix applies a prefix operator to an evaluated operand. Together with
+// EvalInfix, EvalIndex and LookupBuiltin it lets other backends share the
+// evaluator's semantics.
+func (e *Evaluator) EvalPrefix(op string, right object.Object) object.Object {
+	return e.evalPrefixExpression(op, right)
+}
+
+// EvalInfix applies an infix operator to evaluated operands.
+func (e *Evaluator) EvalInfix(op string, left, right object.Object) object.Object {
+	return e.evalInfixExpression(op, left, right)
+}
+
+// EvalIndex indexes an evaluated array or hash.
+func EvalIndex(left, index object.Object) object.Object {
+	return evalIndexExpression(left, index)
+}
+
+// SetIndex stores val in an evaluated array or hash, charging budget for a
+// new hash entry.
+func SetIndex(budget *Budget, left, index, val object.Object) object.Object {
+	return evalIndexAssignment(budget, left, index, val)
+}
+
+// LookupBuiltin returns the builtin function with the given name, as used
+// by an evaluator with the default configuration.
+func LookupBuiltin(name string) (*object.Builtin, bool) {
+	b, ok := builtins[name]
+	return b, ok
+}
+
+// LookupBuiltin returns the builtin function with the given name, which
+// writes any output to the evaluator's Config.Out.
+func (e *Evaluator) LookupBuiltin(name string) (*object.Builtin, bool) {
+	b, ok := e.builtins[name]
+	return b, ok
+}
+
+// BuiltinNames returns the names of the builtin functions in sorted order.
+func BuiltinNames() []string {
+	names := make([]string, 0, len(builtins))
+	for name := range builtins {
+		names = append(names, name)
 	}
+	sort.Strings(names)
+	return names
+}
+
+// CallBuiltin calls a builtin, turning a panic into an error object.
+func CallBuiltin(fn *object.Builtin, args []object.Object) object.Object {
+	return callBuiltin(fn, args)
 }
 
 func nativeBoolToBoolObject(val bool) *object.Boolean {
@@ -228,6 +813,6 @@ func nativeBoolToBoolObject(val bool) *object.Boolean {
 	return FALSE
 }
 
-func newErrorf(format string, a ...interface{}) *object.Error {
-	return &object.Error{Message: fmt.Sprintf(format, a...)}
+func newErrorf(kind object.ErrorKind, format string, a ...interface{}) *object.Error {
+	return &object.Error{Message: fmt.Sprintf(format, a...), Kind: kind}
 }
@@ -1,12 +1,15 @@
 package evaluator
 
 import (
+	"bytes"
+	"strings"
 	"testing"
 
 	"github.com/cszczepaniak/monkey/lexer"
 	"github.com/cszczepaniak/monkey/object"
 	"github.com/cszczepaniak/monkey/parser"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestEvalIntegerExpression(t *testing.T) {
@@ -43,6 +46,12 @@ func TestEvalIntegerExpression(t *testing.T) {
 		`3 * (3 * 3) + 10`, 37,
 	}, {
 		`(5 + 10 * 2 + 15 / 3) * 2 + -10`, 50,
+	}, {
+		`7 % 3`, 1,
+	}, {
+		`-7 % 3`, -1,
+	}, {
+		`2 + 7 % 3 * 2`, 4,
 	}}
 
 	for _, tc := range tests {
@@ -101,6 +110,166 @@ func TestEvalBooleanExpression(t *testing.T) {
 	}
 }
 
+func TestStringExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{{
+		`"Hello World!"`, `Hello World!`,
+	}, {
+		`"Hello" + " " + "World!"`, `Hello World!`,
+	}, {
+		`let greet = fn(name) { "Hello, " + name }; greet("monkey")`, `Hello, monkey`,
+	}, {
+		`"a" == "a"`, true,
+	}, {
+		`"a" == "b"`, false,
+	}, {
+		`"a" != "b"`, true,
+	}, {
+		`"a" + "b" == "ab"`, true,
+	}}
+
+	for _, tc := range tests {
+		result := evalInput(tc.input)
+		switch exp := tc.expected.(type) {
+		case string:
+			assertStringObject(t, result, exp)
+		case bool:
+			assertBooleanObject(t, result, exp)
+		}
+	}
+}
+
+func TestStringInspect(t *testing.T) {
+	input := `"line one\n\t\"quoted\" \\ \u{1b}"`
+	result := evalInput(input)
+	assert.Equal(t, input, result.Inspect())
+	assert.Equal(t, result, evalInput(result.Inspect()))
+}
+
+func TestArrayLiterals(t *testing.T) {
+	result := evalInput(`[1, 2 * 2, 3 + 3]`)
+	assert.IsType(t, &object.Array{}, result)
+	arr := result.(*object.Array)
+	assert.Len(t, arr.Elements, 3)
+	assertIntegerObject(t, arr.Elements[0], 1)
+	assertIntegerObject(t, arr.Elements[1], 4)
+	assertIntegerObject(t, arr.Elements[2], 6)
+	assert.Equal(t, `[1, 4, 6]`, result.Inspect())
+	assert.Equal(t, `[]`, evalInput(`[]`).Inspect())
+}
+
+func TestArrayIndexExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{{
+		`[1, 2, 3][0]`, 1,
+	}, {
+		`[1, 2, 3][1]`, 2,
+	}, {
+		`[1, 2, 3][2]`, 3,
+	}, {
+		`let i = 0; [1][i];`, 1,
+	}, {
+		`[1, 2, 3][1 + 1];`, 3,
+	}, {
+		`let myArray = [1, 2, 3]; myArray[2];`, 3,
+	}, {
+		`let myArray = [1, 2, 3]; myArray[0] + myArray[1] + myArray[2];`, 6,
+	}, {
+		`let myArray = [1, 2, 3]; let i = myArray[0]; myArray[i]`, 2,
+	}, {
+		`[1, 2, 3][-1]`, 3,
+	}, {
+		`[1, 2, 3][-3]`, 1,
+	}, {
+		`[1, 2, 3][3]`, nil,
+	}, {
+		`[1, 2, 3][-4]`, nil,
+	}, {
+		`[][0]`, nil,
+	}}
+
+	for _, tc := range tests {
+		result := evalInput(tc.input)
+		exp, ok := tc.expected.(int)
+		if ok {
+			assertIntegerObject(t, result, int64(exp))
+		} else {
+			assertNullObject(t, result)
+		}
+	}
+}
+
+func TestHashLiterals(t *testing.T) {
+	input := `
+	let two = "two";
+	{
+		"one": 10 - 9,
+		two: 1 + 1,
+		"thr" + "ee": 6 / 2,
+		4: 4,
+		true: 5,
+		false: 6
+	}`
+	result := evalInput(input)
+	assert.IsType(t, &object.Hash{}, result)
+	hash := result.(*object.Hash)
+
+	expected := []struct {
+		key object.Hashable
+		val int64
+	}{
+		{&object.String{Value: `one`}, 1},
+		{&object.String{Value: `two`}, 2},
+		{&object.String{Value: `three`}, 3},
+		{&object.Integer{Value: 4}, 4},
+		{TRUE, 5},
+		{FALSE, 6},
+	}
+	assert.Equal(t, len(expected), hash.Len())
+	for i, pair := range hash.Pairs() {
+		assert.Equal(t, expected[i].key, pair.Key)
+		assertIntegerObject(t, pair.Value, expected[i].val)
+	}
+	assert.Equal(t, `{"one": 1, "two": 2, "three": 3, 4: 4, true: 5, false: 6}`, hash.Inspect())
+}
+
+func TestHashIndexExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{{
+		`{"foo": 5}["foo"]`, 5,
+	}, {
+		`{"foo": 5}["bar"]`, nil,
+	}, {
+		`let key = "foo"; {"foo": 5}[key]`, 5,
+	}, {
+		`{}["foo"]`, nil,
+	}, {
+		`{5: 5}[5]`, 5,
+	}, {
+		`{true: 5}[true]`, 5,
+	}, {
+		`{false: 5}[false]`, 5,
+	}, {
+		`{"a": 1, "a": 2}["a"]`, 2,
+	}}
+
+	for _, tc := range tests {
+		result := evalInput(tc.input)
+		exp, ok := tc.expected.(int)
+		if ok {
+			assertIntegerObject(t, result, int64(exp))
+		} else {
+			assertNullObject(t, result)
+		}
+	}
+}
+
 func TestBangOperator(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -214,6 +383,20 @@ func TestErrorHandling(t *testing.T) {
 		`unknown operator: BOOLEAN + BOOLEAN`,
 	}, {
 		`foobar;`, `identifier not found: foobar`,
+	}, {
+		`"Hello" - "World"`, `unknown operator: STRING - STRING`,
+	}, {
+		`"Hello" + 1`, `type mismatch: STRING + INTEGER`,
+	}, {
+		`[1, 2]["a"]`, `index operator not supported: ARRAY[STRING]`,
+	}, {
+		`1[0]`, `index operator not supported: INTEGER[INTEGER]`,
+	}, {
+		`[1, foo]`, `identifier not found: foo`,
+	}, {
+		`{"name": "Monkey"}[fn(x) { x }];`, `unusable as hash key: FUNCTION`,
+	}, {
+		`{[1]: 2}`, `unusable as hash key: ARRAY`,
 	}}
 
 	for _, tc := range tests {
@@ -224,6 +407,532 @@ func TestErrorHandling(t *testing.T) {
 	}
 }
 
+func TestErrorPositions(t *testing.T) {
+	tests := []struct {
+		input      string
+		expInspect string
+	}{{
+		`foobar;`, `ERROR: 1:1: identifier not found: foobar`,
+	}, {
+		"let x = 1;\nlet y = x + true;", `ERROR: 2:9: type mismatch: INTEGER + BOOLEAN`,
+	}, {
+		"let f = fn() {\n  -true\n};\nf();", `ERROR: 2:3: unknown operator: -BOOLEAN`,
+	}, {
+		`5(1)`, `ERROR: 1:1: not a function: INTEGER`,
+	}}
+
+	for _, tc := range tests {
+		result := evalInput(tc.input)
+		assert.IsType(t, &object.Error{}, result)
+		assert.Equal(t, tc.expInspect, result.Inspect())
+	}
+}
+
+func TestDivisionByZero(t *testing.T) {
+	tests := []struct {
+		input  string
+		expMsg string
+	}{
+		{`1 / 0`, `division by zero`},
+		{`1 % 0`, `modulo by zero`},
+		{`let f = fn(x) { 10 / x }; f(0)`, `division by zero`},
+	}
+
+	for _, tc := range tests {
+		for _, mode := range []OverflowMode{OverflowWrap, OverflowError, OverflowPromote} {
+			result := evalInputWithConfig(tc.input, Config{Overflow: mode})
+			assert.IsType(t, &object.Error{}, result)
+			assert.Equal(t, tc.expMsg, result.(*object.Error).Message)
+		}
+	}
+}
+
+func TestIntegerOverflow(t *testing.T) {
+	tests := []struct {
+		input      string
+		expWrap    string
+		expError   string
+		expPromote string
+	}{{
+		`9223372036854775807 + 1`,
+		`-9223372036854775808`,
+		`integer overflow: 9223372036854775807 + 1`,
+		`9223372036854775808`,
+	}, {
+		`-9223372036854775807 - 2`,
+		`9223372036854775807`,
+		`integer overflow: -9223372036854775807 - 2`,
+		`-9223372036854775809`,
+	}, {
+		`4611686018427387904 * 4`,
+		`0`,
+		`integer overflow: 4611686018427387904 * 4`,
+		`18446744073709551616`,
+	}, {
+		`let min = -9223372036854775807 - 1; min / -1`,
+		`-9223372036854775808`,
+		`integer overflow: -9223372036854775808 / -1`,
+		`9223372036854775808`,
+	}, {
+		`let min = -9223372036854775807 - 1; -min`,
+		`-9223372036854775808`,
+		`integer overflow: --9223372036854775808`,
+		`9223372036854775808`,
+	}, {
+		`9223372036854775807 + 1 - 1`,
+		`9223372036854775807`,
+		`integer overflow: 9223372036854775807 + 1`,
+		`9223372036854775807`,
+	}, {
+		`1 << 63`,
+		`-9223372036854775808`,
+		`integer overflow: 1 << 63`,
+		`9223372036854775808`,
+	}, {
+		`3 << 64`,
+		`0`,
+		`integer overflow: 3 << 64`,
+		`55340232221128654848`,
+	}, {
+		`-1 << 63`,
+		`-9223372036854775808`,
+		`-9223372036854775808`,
+		`-9223372036854775808`,
+	}, {
+		`18446744073709551617 * 2`,
+		`2`,
+		`integer overflow: literal 18446744073709551617`,
+		`36893488147419103234`,
+	}, {
+		`-9223372036854775808`,
+		`-9223372036854775808`,
+		`integer overflow: literal 9223372036854775808`,
+		`-9223372036854775808`,
+	}, {
+		`1 + 2`,
+		`3`,
+		`3`,
+		`3`,
+	}}
+
+	for _, tc := range tests {
+		assert.Equal(t, tc.expWrap, inspect(evalInputWithConfig(tc.input, Config{Overflow: OverflowWrap})), tc.input)
+		assert.Equal(t, tc.expError, inspect(evalInputWithConfig(tc.input, Config{Overflow: OverflowError})), tc.input)
+		assert.Equal(t, tc.expPromote, inspect(evalInputWithConfig(tc.input, Config{Overflow: OverflowPromote})), tc.input)
+	}
+}
+
+func TestBigIntegers(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`let big = 9223372036854775807 * 10; big / 10 == 9223372036854775807`, `true`},
+		{`let big = 9223372036854775807 * 10; big > 9223372036854775807`, `true`},
+		{`let big = 9223372036854775807 * 10; big % 7`, `0`},
+		{`let big = 9223372036854775807 * 10; big - big`, `0`},
+		{`let big = 9223372036854775807 * 10; -big`, `-92233720368547758070`},
+		{`let big = 9223372036854775807 * 10; type(big)`, `"INTEGER"`},
+		{`let big = 9223372036854775807 * 10; {big: 1}[big * 1]`, `1`},
+		{`let big = 9223372036854775807 * 10; [1][big]`, `null`},
+		{`let big = 9223372036854775807 * 10; big / 0`, `division by zero`},
+	}
+
+	for _, tc := range tests {
+		assert.Equal(t, tc.expected, inspect(evalInputWithConfig(tc.input, Config{Overflow: OverflowPromote})), tc.input)
+	}
+}
+
+func TestFloats(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`3.14`, `3.14`},
+		{`1e-9`, `1e-09`},
+		{`2.0`, `2.0`},
+		{`-2.5`, `-2.5`},
+		{`0.1 + 0.2`, `0.30000000000000004`},
+		{`1 + 0.5`, `1.5`},
+		{`0.5 * 4`, `2.0`},
+		{`7 / 2.0`, `3.5`},
+		{`7 / 2`, `3`},
+		{`5.5 % 2`, `1.5`},
+		{`1 == 1.0`, `true`},
+		{`1 < 1.5`, `true`},
+		{`2.5 > 3`, `false`},
+		{`1.0 / 0`, `+Inf`},
+		{`-1 / 0.0`, `-Inf`},
+		{`0 / 0.0`, `NaN`},
+		{`let big = 9223372036854775807 * 10; big * 0.5`, `4.611686018427388e+19`},
+		{`0xff + 0b1 + 0o7 + 1_000`, `1263`},
+		{`type(1.5)`, `"FLOAT"`},
+		{`"a" + 1.5`, `type mismatch: STRING + FLOAT`},
+		{`{1.5: 1}`, `unusable as hash key: FLOAT`},
+	}
+
+	for _, tc := range tests {
+		assert.Equal(t, tc.expected, inspect(evalInputWithConfig(tc.input, Config{Overflow: OverflowPromote})), tc.input)
+	}
+}
+
+func TestOperators(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`1 <= 1`, `true`},
+		{`2 <= 1`, `false`},
+		{`1 >= 2`, `false`},
+		{`1.5 >= 1`, `true`},
+		{`true && true`, `true`},
+		{`true && false`, `false`},
+		{`false || true`, `true`},
+		{`if (false) { 1 } || false`, `false`},
+		{`1 && "a"`, `true`},
+		{`false && missing`, `false`},
+		{`true || 1 / 0`, `true`},
+		{`true && missing`, `identifier not found: missing`},
+		{`let fail = fn() { 1 / 0 }; false && fail()`, `false`},
+		{`1 < 2 && 2 < 3 || false`, `true`},
+		{`6 & 3`, `2`},
+		{`6 | 3`, `7`},
+		{`6 ^ 3`, `5`},
+		{`~5`, `-6`},
+		{`1 << 10`, `1024`},
+		{`-1024 >> 3`, `-128`},
+		{`1 >> 100`, `0`},
+		{`1 << -1`, `negative shift count: -1`},
+		{`1 << 2000000`, `shift count too large: 2000000`},
+		{`1 | 2 ^ 3 & 4 << 1`, `3`},
+		{`1.5 & 1`, `unknown operator: FLOAT & INTEGER`},
+		{`~true`, `unknown operator: ~BOOLEAN`},
+		{`"a" <= "b"`, `unknown operator: STRING <= STRING`},
+	}
+
+	for _, tc := range tests {
+		assert.Equal(t, tc.expected, inspect(evalInput(tc.input)), tc.input)
+	}
+}
+
+func TestAssignment(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`let x = 1; x = 2; x`, `2`},
+		{`let x = 1; x = x + 1`, `2`},
+		{`let a = 1; let b = 2; a = b = 7; [a, b]`, `[7, 7]`},
+		{`let x = 10; x += 5; x -= 3; x *= 2; x /= 4`, `6`},
+		{`let s = "a"; s += "b"; s`, `"ab"`},
+		{`let f = 0.5; f *= 3; f`, `1.5`},
+		{`let counter = fn() { let n = 0; fn() { n += 1; n } }; let c = counter(); c(); c(); c()`, `3`},
+		{`let n = 0; let inc = fn() { n = n + 1 }; inc(); inc(); n`, `2`},
+		{`let f = fn(p) { let g = fn() { p *= 2 }; g(); g(); p }; f(3)`, `12`},
+		{`let x = 1; let f = fn() { let x = 5; x = 6; x }; [f(), x]`, `[6, 1]`},
+		{`let a = [1, 2, 3]; a[0] = 10; a[-1] += 7; a`, `[10, 2, 10]`},
+		{`let h = {"a": 1}; h["a"] -= 3; h["b"] = 2; h`, `{"a": -2, "b": 2}`},
+		{`let a = [[1], [2]]; a[1][0] = 5; a`, `[[1], [5]]`},
+		{`let a = [1]; let b = a; b[0] = 2; a`, `[2]`},
+		{`let a = [1]; a[1] = 2`, `index out of range: 1`},
+		{`let h = {}; h[fn() {}] = 1`, `unusable as hash key: FUNCTION`},
+		{`let s = "ab"; s[0] = "c"`, `index assignment not supported: STRING[INTEGER]`},
+		{`y = 1`, `identifier not found: y`},
+		{`let x = 1; x += "a"`, `type mismatch: INTEGER + STRING`},
+		{`let x = 5; x /= 0`, `division by zero`},
+		{`const k = 1; k`, `1`},
+		{`const k = 1; k = 2`, `cannot assign to constant k`},
+		{`const k = 1; k += 2`, `cannot assign to constant k`},
+		{`const k = 1; let f = fn() { k = 2 }; f()`, `cannot assign to constant k`},
+		{`const k = 1; let k = 2`, `cannot redeclare constant k`},
+		{`const k = 1; let f = fn() { let k = 2; k }; f()`, `2`},
+		{`const a = [1]; a[0] = 2; a`, `[2]`},
+	}
+
+	for _, tc := range tests {
+		assert.Equal(t, tc.expected, inspect(evalInput(tc.input)), tc.input)
+	}
+}
+
+func TestLoops(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`let i = 0; while (i < 5) { i += 1 }; i`, `5`},
+		{`while (false) { 1 }`, `null`},
+		{`let s = 0; let i = 0; while (true) { i += 1; if (i > 10) { break }; if (i % 2 == 0) { continue }; s += i }; s`, `25`},
+		{`let out = []; for (x in [1, 2, 3]) { out = push(out, x * 2) }; out`, `[2, 4, 6]`},
+		{`let ks = []; for (k in {"b": 1, "a": 2}) { ks = push(ks, k) }; ks`, `["b", "a"]`},
+		{`let h = {1: 1}; for (k in h) { h[k + 1] = 1 }; len(h)`, `2`},
+		{`let a = [1, 2]; let n = 0; for (x in a) { n += 1; if (n < 5) { a[1] = 7 } }; a`, `[1, 7]`},
+		{`let s = 0; for (i in range(5)) { s += i }; s`, `10`},
+		{`let r = []; for (i in range(1, 10, 4)) { r = push(r, i) }; r`, `[1, 5, 9]`},
+		{`let r = []; for (i in range(3, 0, -1)) { r = push(r, i) }; r`, `[3, 2, 1]`},
+		{`let r = []; for (i in range(0)) { r = push(r, i) }; r`, `[]`},
+		{`for (x in [1, 2]) {}; x`, `2`},
+		{`let x = for (i in [1]) { i }; x`, `null`},
+		{`let r = []; for (i in range(3)) { for (j in range(3)) { if (j > i) { break }; r = push(r, [i, j]) } }; r`, `[[0, 0], [1, 0], [1, 1], [2, 0], [2, 1], [2, 2]]`},
+		{`let f = fn(xs) { for (x in xs) { if (x > 1) { return x } }; 0 }; [f([1, 2, 3]), f([])]`, `[2, 0]`},
+		{`let n = 0; for (i in range(10)) { n = n + [1, if (i % 2 == 0) { continue } else { 2 }][1] }; n`, `10`},
+		{`let t = 0; for (i in range(5)) { t += 1 + if (i == 3) { break } else { 0 } }; t`, `3`},
+		{`let f = fn() { let fs = []; for (i in range(3)) { fs = push(fs, fn() { i }) }; fs[0]() }; f()`, `2`},
+		{`let f = fn() { 1 + if (true) { return 5 } }; f()`, `5`},
+		{`let g = fn(a = if (true) { return 7 }) { 1 }; [g(), g(2)]`, `[7, 1]`},
+		{`len(range(0, 10, 3))`, `4`},
+		{`len(range(10, 0))`, `0`},
+		{`len(range(-9223372036854775807 - 1, 9223372036854775807))`, `9223372036854775807`},
+		{`range(5)`, `range(0, 5, 1)`},
+		{`type(range(1))`, `"RANGE"`},
+		{`for (x in 5) {}`, `cannot iterate over INTEGER`},
+		{`for (x in range(1)) { y }`, `identifier not found: y`},
+		{`while (missing) {}`, `identifier not found: missing`},
+		{`range()`, `wrong number of arguments: want 1 to 3, got 0`},
+		{`range(1, 2.5)`, "argument to `range` must be INTEGER, got FLOAT"},
+		{`range(1, 2, 0)`, "`range` step must not be zero"},
+		{`const c = 1; for (c in [2]) {}`, `cannot redeclare constant c`},
+	}
+
+	for _, tc := range tests {
+		assert.Equal(t, tc.expected, inspect(evalInput(tc.input)), tc.input)
+	}
+}
+
+func TestTryCatch(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`try { 1 } catch (e) { 2 }`, `1`},
+		{`try { 1 + true } catch (e) { e }`, `{"message": "type mismatch: INTEGER + BOOLEAN", "kind": "type"}`},
+		{`try { foo } catch (e) { e["kind"] }`, `"name"`},
+		{`try { throw [1, 2] } catch (e) { e }`, `[1, 2]`},
+		{`try { throw "x" } catch (e) { e + "y" }`, `"xy"`},
+		{`let f = fn() { throw 1 }; try { f() } catch (e) { e + 1 }`, `2`},
+		{`try { try { throw 1 } catch (e) { throw e + 1 } } catch (e) { e }`, `2`},
+		{`let a = []; try { a = push(a, 1) } finally { a = push(a, 2) }; a`, `[1, 2]`},
+		{`let a = []; try { try { throw 1 } finally { a = push(a, 2) } } catch (e) { a = push(a, e) }; a`, `[2, 1]`},
+		{`let a = []; try { 1 } catch (e) { a = push(a, 1) } finally { a = push(a, 2) }; a`, `[2]`},
+		{`let a = []; try { throw 1 } catch (e) { a = push(a, 1) } finally { a = push(a, 2) }; a`, `[1, 2]`},
+		{`try { 1 } finally { 2 }`, `1`},
+		{`1 + try { throw 1 } catch (e) { 2 }`, `3`},
+		{`let f = fn() { try { return 1 } finally { 2 } }; f()`, `1`},
+		{`let f = fn() { try { return 1 } finally { return 2 } }; f()`, `2`},
+		{`let f = fn() { try { throw 1 } finally { return 2 } }; f()`, `2`},
+		{`let n = 0; for (i in range(5)) { try { if (i == 3) { break }; n += i } finally { n += 10 } }; n`, `43`},
+		{`let n = 0; for (i in range(3)) { try { continue } finally { n += 1 } }; n`, `3`},
+		{`let n = 0; while (true) { try { throw 1 } catch (e) { break } finally { n += 1 } }; n`, `1`},
+		// A tail call made in a try expression is caught by it.
+		{`let g = fn() { throw "g" }; let f = fn() { try { return g() } catch (e) { e } }; f()`, `"g"`},
+		{`let g = fn() { throw "g" }; let f = fn() { try { g() } catch (e) { e } }; f()`, `"g"`},
+		{`let f = fn() { try { 1 } catch (e) { 2 }; f2() }; f()`, `identifier not found: f2`},
+		{`throw 1`, `1`},
+		{`throw {"a": 1}`, `{"a": 1}`},
+		{`try { 1 } finally { throw 2 }`, `2`},
+		{`try { 1 } catch (e) { 2 } finally { 1 + true }`, `type mismatch: INTEGER + BOOLEAN`},
+		{`const e = 1; try { throw 2 } catch (e) { e }`, `cannot redeclare constant e`},
+	}
+
+	for _, tc := range tests {
+		assert.Equal(t, tc.expected, inspect(evalInput(tc.input)), tc.input)
+	}
+
+	res := evalInput(`throw "oops"`)
+	require.IsType(t, &object.Error{}, res)
+	assert.Equal(t, object.KindThrown, res.(*object.Error).Kind)
+	assert.Equal(t, `1:1: oops`, res.(*object.Error).Error())
+	assert.Equal(t, &object.String{Value: `oops`}, res.(*object.Error).Value)
+
+	// Errors for exceeding a limit end the run: they are not caught, and
+	// finally does not run.
+	tests = []struct {
+		input    string
+		expected string
+	}{
+		{`let n = 0; try { while (true) { n += 1 } } catch (e) { "caught" } finally { puts("finally") }`, `step limit of 1000 exceeded`},
+		{`let f = fn() { 1 + f() }; try { f() } catch (e) { "caught" }`, `stack overflow`},
+	}
+	for _, tc := range tests {
+		res := evalInputWithConfig(tc.input, Config{MaxSteps: 1000, MaxDepth: 100})
+		assert.Equal(t, tc.expected, inspect(res), tc.input)
+	}
+}
+
+func TestLimits(t *testing.T) {
+	tests := []struct {
+		config  Config
+		input   string
+		expKind object.ErrorKind
+		expErr  string
+	}{
+		{Config{}, `let f = fn() { 1 + f() }; f()`, object.KindCallDepth, `stack overflow`},
+		{Config{MaxDepth: 3}, `let f = fn(n) { if (n > 0) { 1 + f(n - 1) } }; f(3)`, object.KindCallDepth, `stack overflow`},
+		{Config{MaxSteps: 100}, `let n = 0; while (true) { n += 1 }`, object.KindStepLimit, `step limit of 100 exceeded`},
+		{Config{}, `let f = fn() { f() }; f()`, object.KindCallDepth, `stack overflow`},
+		{Config{MaxSteps: 10000}, `let f = fn() { f() }; f()`, object.KindStepLimit, `step limit of 10000 exceeded`},
+		{Config{MaxAlloc: 1000}, `let a = []; for (i in range(100)) { a = push(a, i) }`, object.KindMemoryLimit, `memory limit of 1000 bytes exceeded`},
+		{Config{MaxAlloc: 1000}, `[[1, 2, 3], {"a": 1}, "a" + "b", -(1 << 62) * 8]`, object.KindRuntime, ``},
+		{Config{MaxAlloc: 10000}, `let h = {}; for (i in range(200000)) { h[i] = true }`, object.KindMemoryLimit, `memory limit of 10000 bytes exceeded`},
+		{Config{MaxAlloc: 10000}, `let h = {}; for (i in range(200000)) { h[i % 10] = i }`, object.KindRuntime, ``},
+		{Config{}, `1 + true`, object.KindType, `type mismatch: INTEGER + BOOLEAN`},
+	}
+
+	for _, tc := range tests {
+		res := evalInputWithConfig(tc.input, tc.config)
+		if tc.expErr == `` {
+			assert.NotEqual(t, object.ERROR, res.Type(), tc.input)
+			continue
+		}
+		require.IsType(t, &object.Error{}, res, tc.input)
+		assert.Equal(t, tc.expKind, res.(*object.Error).Kind, tc.input)
+		assert.Equal(t, tc.expErr, res.(*object.Error).Message, tc.input)
+	}
+
+	// A limit reached within a host function calling back into the
+	// evaluator ends the whole run.
+	e := New(Config{MaxSteps: 1000})
+	env := object.NewEnvironment()
+	env.Set(`call`, &object.Builtin{Name: `call`, Fn: func(args ...object.Object) object.Object {
+		return e.ApplyFunction(args[0], nil)
+	}})
+	program := parser.New(lexer.New(`call(fn() { while (true) {} })`)).ParseProgram()
+	res := e.Eval(program, env)
+	require.IsType(t, &object.Error{}, res)
+	assert.Equal(t, object.KindStepLimit, res.(*object.Error).Kind)
+}
+
+func TestTailCalls(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`let c = fn(n) { if (n == 0) { "done" } else { c(n - 1) } }; c(1000000)`, `"done"`},
+		{`let c = fn(n) { if (n == 0) { return n; } return c(n - 1); }; c(100000)`, `0`},
+		{`let sum = fn(n, acc) { if (n == 0) { acc } else { sum(n - 1, acc + n) } }; sum(100000, 0)`, `5000050000`},
+		{`let even = fn(n) { if (n == 0) { true } else { odd(n - 1) } }; let odd = fn(n) { if (n == 0) { false } else { even(n - 1) } }; even(100001)`, `false`},
+		{`let f = fn(n) { if (n > 0) { f(n - 1) } }; f(100000)`, `null`},
+		{`let f = fn() { len() }; f()`, `wrong number of arguments: want 1, got 0`},
+	}
+
+	// Tail calls only run in constant depth once a step limit stops endless
+	// tail recursion.
+	for _, tc := range tests {
+		assert.Equal(t, tc.expected, inspect(evalInputWithConfig(tc.input, Config{MaxDepth: 100, MaxSteps: 1 << 40})), tc.input)
+	}
+	res := evalInputWithConfig(tests[0].input, Config{MaxDepth: 100})
+	require.IsType(t, &object.Error{}, res)
+	assert.Equal(t, object.KindCallDepth, res.(*object.Error).Kind)
+
+	// An error in a tail call is reported at the call.
+	res = evalInput("let f = fn(a) { a };\nlet g = fn() { f() };\ng()")
+	require.IsType(t, &object.Error{}, res)
+	assert.Equal(t, `2:16`, res.(*object.Error).Pos.String())
+}
+
+func TestErrorKinds(t *testing.T) {
+	tests := []struct {
+		input   string
+		expKind object.ErrorKind
+	}{
+		{`1 + true`, object.KindType},
+		{`5()`, object.KindType},
+		{`for (x in 1) {}`, object.KindType},
+		{`foo`, object.KindName},
+		{`const c = 1; c = 2`, object.KindName},
+		{`[1][1] = 2`, object.KindIndex},
+		{`fn(a) { a }()`, object.KindArgument},
+		{`range(1, 2, 0)`, object.KindArgument},
+		{`1 / 0`, object.KindArithmetic},
+		{`1 << -1`, object.KindArithmetic},
+	}
+
+	for _, tc := range tests {
+		res := evalInput(tc.input)
+		require.IsType(t, &object.Error{}, res, tc.input)
+		assert.Equal(t, tc.expKind, res.(*object.Error).Kind, tc.input)
+	}
+}
+
+func TestErrorStack(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`1 + true`, `1:1: type mismatch: INTEGER + BOOLEAN`},
+		{
+			"let g = fn(x) { x + true };\nlet f = fn(x) { let y = g(x); y };\nf(1)",
+			"1:17: type mismatch: INTEGER + BOOLEAN\n  in g, called at 2:25\n  in f, called at 3:1",
+		},
+		{
+			`let f = fn() { fn() { len(1) }() + 1 }; f()`,
+			"1:23: argument to `len` not supported, got INTEGER\n  in anonymous function, called at 1:16\n  in f, called at 1:41",
+		},
+		{
+			// The arity error is raised by the call in g, not in f.
+			`let f = fn(a) { a }; let g = fn() { f() + 1 }; g()`,
+			"1:37: wrong number of arguments: want 1, got 0\n  in g, called at 1:48",
+		},
+		{
+			// The tail call to g takes the place of the call to f.
+			`let g = fn() { 1 / 0 }; let f = fn() { g() }; let h = fn() { f() + 1 }; h()`,
+			"1:16: division by zero\n  in g, called at 1:40\n  in h, called at 1:73",
+		},
+		{
+			`let f = fn(n) { 1 + f(n + 1) }; f(0)`,
+			"1:21: stack overflow\n" + strings.Repeat("  in f, called at 1:21\n", 10) +
+				"  ... 9980 more calls\n" + strings.Repeat("  in f, called at 1:21\n", 9) + "  in f, called at 1:33",
+		},
+	}
+
+	for _, tc := range tests {
+		res := evalInput(tc.input)
+		require.IsType(t, &object.Error{}, res, tc.input)
+		assert.Equal(t, tc.expected, res.(*object.Error).Traceback(), tc.input)
+	}
+
+	// A call made by the host has no position.
+	e := New(Config{})
+	env := object.NewEnvironment()
+	program := parser.New(lexer.New(`let f = fn() { foo }`)).ParseProgram()
+	e.Eval(program, env)
+	f, _ := env.Get(`f`)
+	res := e.ApplyFunction(f, nil)
+	require.IsType(t, &object.Error{}, res)
+	assert.Equal(t, "1:16: identifier not found: foo\n  in f", res.(*object.Error).Traceback())
+}
+
+func TestNoPanics(t *testing.T) {
+	env := object.NewEnvironment()
+	env.Set(`boom`, &object.Builtin{Name: `boom`, Fn: func(args ...object.Object) object.Object {
+		panic(`host failure`)
+	}})
+	program := parser.New(lexer.New(`let x = 1; boom(x)`)).ParseProgram()
+	result := Eval(program, env)
+	assert.IsType(t, &object.Error{}, result)
+	assert.Equal(t, `internal error: host failure`, result.(*object.Error).Message)
+
+	result = Eval(nil, env)
+	assert.IsType(t, &object.Error{}, result)
+
+	result = ApplyFunction(env.Set(`f`, evalInput(`fn(x) { boom(x) }`)), []object.Object{TRUE})
+	assert.IsType(t, &object.Error{}, result)
+}
+
+func TestIncompletePrograms(t *testing.T) {
+	inputs := []string{
+		`let x = ;`,
+		`let y = 1 +;`,
+		`if (true) {}`,
+		`let f = fn() {}; f() + 1`,
+		`return;`,
+		`[1, 2`,
+	}
+
+	for _, input := range inputs {
+		assert.NotPanics(t, func() { evalInput(input) }, input)
+	}
+}
+
 func TestLetStatements(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -306,6 +1015,140 @@ func TestFunctionApplication(t *testing.T) {
 	}
 }
 
+func TestFunctionParameters(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{{
+		`let f = fn(x, y = 10) { x + y }; f(1)`, 11,
+	}, {
+		`let f = fn(x, y = 10) { x + y }; f(1, 2)`, 3,
+	}, {
+		`let f = fn(x, y = x * 2) { x + y }; f(3)`, 9,
+	}, {
+		`let n = 5; let f = fn(x = n) { x }; let m = 6; f()`, 5,
+	}, {
+		`let f = fn(first, ...rest) { len(rest) }; f(1, 2, 3)`, 2,
+	}, {
+		`let f = fn(first, ...rest) { rest }; f(1)`, `[]`,
+	}, {
+		`let f = fn(...all) { all }; f(1, "a", true)`, `[1, "a", true]`,
+	}, {
+		`let f = fn(a, b = 2, ...rest) { [a, b, rest] }; f(1, 3, 5, 7)`, `[1, 3, [5, 7]]`,
+	}, {
+		`let f = fn(x, y) { x }; f(1)`, `wrong number of arguments: want 2, got 1`,
+	}, {
+		`let f = fn(x) { x }; f(1, 2)`, `wrong number of arguments: want 1, got 2`,
+	}, {
+		`fn() { 1 }(1)`, `wrong number of arguments: want 0, got 1`,
+	}, {
+		`let f = fn(x, y = 1) { x }; f(1, 2, 3)`, `wrong number of arguments: want 1 to 2, got 3`,
+	}, {
+		`let f = fn(x, y, ...z) { x }; f(1)`, `wrong number of arguments: want at least 2, got 1`,
+	}, {
+		`let f = fn(x = oops) { x }; f()`, `identifier not found: oops`,
+	}}
+
+	for _, tc := range tests {
+		result := evalInput(tc.input)
+		switch exp := tc.expected.(type) {
+		case int:
+			assertIntegerObject(t, result, int64(exp))
+		case string:
+			if errObj, ok := result.(*object.Error); ok {
+				assert.Equal(t, exp, errObj.Message)
+			} else {
+				assert.Equal(t, exp, result.Inspect())
+			}
+		}
+	}
+}
+
+func TestBuiltinFunctions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{{
+		`len("")`, 0,
+	}, {
+		`len("four")`, 4,
+	}, {
+		`len("héllo")`, 5,
+	}, {
+		`len([1, 2, 3])`, 3,
+	}, {
+		`len({"a": 1})`, 1,
+	}, {
+		`len(1)`, "argument to `len` not supported, got INTEGER",
+	}, {
+		`len("one", "two")`, `wrong number of arguments: want 1, got 2`,
+	}, {
+		`first([1, 2, 3])`, 1,
+	}, {
+		`first([])`, nil,
+	}, {
+		`first(1)`, "argument to `first` must be ARRAY, got INTEGER",
+	}, {
+		`last([1, 2, 3])`, 3,
+	}, {
+		`last([])`, nil,
+	}, {
+		`last(1)`, "argument to `last` must be ARRAY, got INTEGER",
+	}, {
+		`rest([1, 2, 3])`, []int64{2, 3},
+	}, {
+		`rest([1])`, []int64{},
+	}, {
+		`rest([])`, nil,
+	}, {
+		`push([], 1)`, []int64{1},
+	}, {
+		`let a = [1]; push(a, 2); a`, []int64{1},
+	}, {
+		`push(1, 1)`, "argument to `push` must be ARRAY, got INTEGER",
+	}, {
+		`push([1])`, `wrong number of arguments: want 2, got 1`,
+	}, {
+		`puts("hello", 1)`, nil,
+	}, {
+		`type("a")`, `STRING`,
+	}, {
+		`type(len)`, `BUILTIN`,
+	}, {
+		`let len = fn(x) { 42 }; len("a")`, 42,
+	}}
+
+	for _, tc := range tests {
+		result := evalInput(tc.input)
+		switch exp := tc.expected.(type) {
+		case int:
+			assertIntegerObject(t, result, int64(exp))
+		case string:
+			if errObj, ok := result.(*object.Error); ok {
+				assert.Equal(t, exp, errObj.Message)
+			} else {
+				assertStringObject(t, result, exp)
+			}
+		case []int64:
+			assert.IsType(t, &object.Array{}, result)
+			arr := result.(*object.Array)
+			assert.Len(t, arr.Elements, len(exp))
+			for i, el := range arr.Elements {
+				assertIntegerObject(t, el, exp[i])
+			}
+		case nil:
+			assertNullObject(t, result)
+		}
+	}
+}
+
+func TestPutsOutput(t *testing.T) {
+	var out bytes.Buffer
+	result := evalInputWithConfig(`puts("a", [1, "b"]); let p = puts; p(2)`, Config{Out: &out})
+	assertNullObject(t, result)
+	assert.Equal(t, "a\n[1, \"b\"]\n2\n", out.String())
+}
+
 func evalInput(input string) object.Object {
 	l := lexer.New(input)
 	p := parser.New(l)
@@ -315,12 +1158,32 @@ func evalInput(input string) object.Object {
 	return Eval(program, env)
 }
 
+func evalInputWithConfig(input string, config Config) object.Object {
+	program := parser.New(lexer.New(input)).ParseProgram()
+	return New(config).Eval(program, object.NewEnvironment())
+}
+
+// inspect returns the message of an error, or the inspected value of any
+// other object.
+func inspect(obj object.Object) string {
+	if err, ok := obj.(*object.Error); ok {
+		return err.Message
+	}
+	return obj.Inspect()
+}
+
 func assertIntegerObject(t *testing.T, obj object.Object, exp int64) {
 	assert.IsType(t, &object.Integer{}, obj)
 	integer := obj.(*object.Integer)
 	assert.Equal(t, exp, integer.Value)
 }
 
+func assertStringObject(t *testing.T, obj object.Object, exp string) {
+	assert.IsType(t, &object.String{}, obj)
+	str := obj.(*object.String)
+	assert.Equal(t, exp, str.Value)
+}
+
 func assertBooleanObject(t *testing.T, obj object.Object, exp bool) {
 	assert.IsType(t, &object.Boolean{}, obj)
 	boolean := obj.(*object.Boolean)
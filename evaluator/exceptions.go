@@ -0,0 +1,88 @@
+package evaluator
+
+import (
+	"github.com/cszczepaniak/monkey/ast"
+	"github.com/cszczepaniak/monkey/object"
+)
+
+// Throw returns the error raised by throwing val.
+func Throw(val object.Object) *object.Error {
+	msg := val.Inspect()
+	if s, ok := val.(*object.String); ok {
+		msg = s.Value
+	}
+	return &object.Error{Message: msg, Kind: object.KindThrown, Value: val}
+}
+
+// Caught returns the value a catch clause binds for err: the value thrown,
+// or for an error raised by the runtime, a hash of its message and kind.
+func Caught(err *object.Error) object.Object {
+	if err.Value != nil {
+		return err.Value
+	}
+	h := object.NewHash()
+	h.Set(&object.String{Value: `message`}, &object.String{Value: err.Message})
+	h.Set(&object.String{Value: `kind`}, &object.String{Value: err.Kind.String()})
+	return h
+}
+
+// catchable reports whether res is an error a try expression may catch.
+func catchable(res object.Object) bool {
+	err, ok := res.(*object.Error)
+	return ok && !err.Kind.IsLimit()
+}
+
+func (e *Evaluator) evalThrowStatement(ts *ast.ThrowStatement, env *object.Environment) object.Object {
+	val := e.eval(ts.Value, env)
+	if isAbrupt(val) {
+		return val
+	}
+	return Throw(val)
+}
+
+// evalTryExpression evaluates a try expression. Finally runs however the
+// body and the catch clause end, except when a limit ends the run, and an
+// abrupt end of Finally takes the place of theirs.
+func (e *Evaluator) evalTryExpression(te *ast.TryExpression, env *object.Environment) object.Object {
+	res := e.resolveTail(e.eval(te.Body, env))
+	if te.Catch != nil && catchable(res) {
+		res = e.evalCatch(te, res.(*object.Error), env)
+	}
+	if te.Finally == nil {
+		return res
+	}
+	if err, ok := res.(*object.Error); ok && err.Kind.IsLimit() {
+		return res
+	}
+	if fin := e.eval(te.Finally, env); isAbrupt(fin) {
+		return fin
+	}
+	return res
+}
+
+func (e *Evaluator) evalCatch(te *ast.TryExpression, err *object.Error, env *object.Environment) object.Object {
+	name := te.CatchVar.Value
+	if env.IsConst(name) {
+		return newErrorf(object.KindName, `cannot redeclare constant %s`, name)
+	}
+	env.Set(name, Caught(err))
+	return e.resolveTail(e.eval(te.Catch, env))
+}
+
+// resolveTail makes the tail call returned by a return statement, if res is
+// one, so that it runs inside the try expression the return appears in.
+func (e *Evaluator) resolveTail(res object.Object) object.Object {
+	ret, ok := res.(*object.ReturnValue)
+	if !ok {
+		return res
+	}
+	tc, ok := ret.Value.(*tailCall)
+	if !ok {
+		return res
+	}
+	val := e.applyFunction(tc.fn, tc.args, tc.pos)
+	if isAbrupt(val) {
+		return val
+	}
+	return &object.ReturnValue{Value: val}
+}
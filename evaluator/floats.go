@@ -0,0 +1,62 @@
+package evaluator
+
+import (
+	"math"
+	"math/big"
+
+	"github.com/cszczepaniak/monkey/object"
+)
+
+// evalFloatInfixExpression applies op to two numbers, at least one of which
+// is a float. The other is promoted to a float first. Floats follow IEEE 754,
+// so dividing by zero gives an infinity or NaN rather than an error.
+func evalFloatInfixExpression(op string, left, right object.Object) object.Object {
+	a, b := toFloat(left), toFloat(right)
+	switch op {
+	case `+`:
+		return &object.Float{Value: a + b}
+	case `-`:
+		return &object.Float{Value: a - b}
+	case `*`:
+		return &object.Float{Value: a * b}
+	case `/`:
+		return &object.Float{Value: a / b}
+	case `%`:
+		return &object.Float{Value: math.Mod(a, b)}
+	case `==`:
+		return nativeBoolToBoolObject(a == b)
+	case `!=`:
+		return nativeBoolToBoolObject(a != b)
+	case `>`:
+		return nativeBoolToBoolObject(a > b)
+	case `<`:
+		return nativeBoolToBoolObject(a < b)
+	case `>=`:
+		return nativeBoolToBoolObject(a >= b)
+	case `<=`:
+		return nativeBoolToBoolObject(a <= b)
+	default:
+		return newErrorf(object.KindType, `unknown operator: %s %s %s`, left.Type(), op, right.Type())
+	}
+}
+
+// isNumber reports whether obj is an integer or a float.
+func isNumber(obj object.Object) bool {
+	return obj.Type() == object.INTEGER || obj.Type() == object.FLOAT
+}
+
+// toFloat converts a number to a float64, rounding integers which have no
+// exact representation to the nearest float.
+func toFloat(obj object.Object) float64 {
+	switch n := obj.(type) {
+	case *object.Float:
+		return n.Value
+	case *object.Integer:
+		return float64(n.Value)
+	case *object.BigInteger:
+		f, _ := new(big.Float).SetInt(n.Value).Float64()
+		return f
+	default:
+		return math.NaN()
+	}
+}
@@ -0,0 +1,191 @@
+package evaluator
+
+import (
+	"math"
+	"math/big"
+
+	"github.com/cszczepaniak/monkey/object"
+)
+
+func (e *Evaluator) evalIntegerInfixExpression(op string, left, right object.Object) object.Object {
+	l, lok := left.(*object.Integer)
+	r, rok := right.(*object.Integer)
+	if !lok || !rok {
+		return evalBigIntegerInfixExpression(op, toBigInt(left), toBigInt(right))
+	}
+
+	a, b := l.Value, r.Value
+	bigResult := func(f func(z, x, y *big.Int) *big.Int) func() *big.Int {
+		return func() *big.Int {
+			return f(new(big.Int), big.NewInt(a), big.NewInt(b))
+		}
+	}
+	switch op {
+	case `+`:
+		c := a + b
+		return e.integerResult(c, (c > a) != (b > 0), bigResult((*big.Int).Add), `%d + %d`, a, b)
+	case `-`:
+		c := a - b
+		return e.integerResult(c, (c < a) != (b > 0), bigResult((*big.Int).Sub), `%d - %d`, a, b)
+	case `*`:
+		c := a * b
+		overflow := a != 0 && (c/a != b || a == -1 && b == math.MinInt64)
+		return e.integerResult(c, overflow, bigResult((*big.Int).Mul), `%d * %d`, a, b)
+	case `/`:
+		if b == 0 {
+			return newErrorf(object.KindArithmetic, `division by zero`)
+		}
+		return e.integerResult(a/b, a == math.MinInt64 && b == -1, bigResult((*big.Int).Quo), `%d / %d`, a, b)
+	case `%`:
+		if b == 0 {
+			return newErrorf(object.KindArithmetic, `modulo by zero`)
+		}
+		return &object.Integer{Value: a % b}
+	case `&`:
+		return &object.Integer{Value: a & b}
+	case `|`:
+		return &object.Integer{Value: a | b}
+	case `^`:
+		return &object.Integer{Value: a ^ b}
+	case `<<`:
+		if err := checkShiftCount(b); err != nil {
+			return err
+		}
+		c := a << uint64(b)
+		overflow := b >= 64 && a != 0 || b < 64 && c>>uint64(b) != a
+		return e.integerResult(c, overflow, func() *big.Int {
+			return new(big.Int).Lsh(big.NewInt(a), uint(b))
+		}, `%d << %d`, a, b)
+	case `>>`:
+		if err := checkShiftCount(b); err != nil {
+			return err
+		}
+		return &object.Integer{Value: a >> uint64(b)}
+	case `==`:
+		return nativeBoolToBoolObject(a == b)
+	case `!=`:
+		return nativeBoolToBoolObject(a != b)
+	case `>`:
+		return nativeBoolToBoolObject(a > b)
+	case `<`:
+		return nativeBoolToBoolObject(a < b)
+	case `>=`:
+		return nativeBoolToBoolObject(a >= b)
+	case `<=`:
+		return nativeBoolToBoolObject(a <= b)
+	default:
+		return newErrorf(object.KindType, `unknown operator: %s %s %s`, left.Type(), op, right.Type())
+	}
+}
+
+// integerResult applies the overflow mode to the result of an int64
+// operation. wrapped is the two's complement result and exact computes the
+// true result when it is needed. The format and arguments describe the
+// operation in an overflow error.
+func (e *Evaluator) integerResult(wrapped int64, overflow bool, exact func() *big.Int, format string, a ...interface{}) object.Object {
+	if !overflow {
+		return &object.Integer{Value: wrapped}
+	}
+	switch e.config.Overflow {
+	case OverflowError:
+		return newErrorf(object.KindArithmetic, `integer overflow: `+format, a...)
+	case OverflowPromote:
+		return normalizeBigInteger(exact())
+	default:
+		return &object.Integer{Value: wrapped}
+	}
+}
+
+func evalBigIntegerInfixExpression(op string, a, b *big.Int) object.Object {
+	switch op {
+	case `+`:
+		return normalizeBigInteger(new(big.Int).Add(a, b))
+	case `-`:
+		return normalizeBigInteger(new(big.Int).Sub(a, b))
+	case `*`:
+		return normalizeBigInteger(new(big.Int).Mul(a, b))
+	case `/`:
+		if b.Sign() == 0 {
+			return newErrorf(object.KindArithmetic, `division by zero`)
+		}
+		return normalizeBigInteger(new(big.Int).Quo(a, b))
+	case `%`:
+		if b.Sign() == 0 {
+			return newErrorf(object.KindArithmetic, `modulo by zero`)
+		}
+		return normalizeBigInteger(new(big.Int).Rem(a, b))
+	case `&`:
+		return normalizeBigInteger(new(big.Int).And(a, b))
+	case `|`:
+		return normalizeBigInteger(new(big.Int).Or(a, b))
+	case `^`:
+		return normalizeBigInteger(new(big.Int).Xor(a, b))
+	case `<<`, `>>`:
+		if !b.IsInt64() {
+			return newErrorf(object.KindArithmetic, `shift count too large: %s`, b)
+		}
+		if err := checkShiftCount(b.Int64()); err != nil {
+			return err
+		}
+		if op == `>>` {
+			return normalizeBigInteger(new(big.Int).Rsh(a, uint(b.Int64())))
+		}
+		return normalizeBigInteger(new(big.Int).Lsh(a, uint(b.Int64())))
+	case `==`:
+		return nativeBoolToBoolObject(a.Cmp(b) == 0)
+	case `!=`:
+		return nativeBoolToBoolObject(a.Cmp(b) != 0)
+	case `>`:
+		return nativeBoolToBoolObject(a.Cmp(b) > 0)
+	case `<`:
+		return nativeBoolToBoolObject(a.Cmp(b) < 0)
+	case `>=`:
+		return nativeBoolToBoolObject(a.Cmp(b) >= 0)
+	case `<=`:
+		return nativeBoolToBoolObject(a.Cmp(b) <= 0)
+	default:
+		return newErrorf(object.KindType, `unknown operator: %s %s %s`, object.INTEGER, op, object.INTEGER)
+	}
+}
+
+// maxShift bounds shift counts, so that a shift cannot ask for a big integer
+// of unbounded size.
+const maxShift = 1 << 20
+
+func checkShiftCount(n int64) *object.Error {
+	switch {
+	case n < 0:
+		return newErrorf(object.KindArithmetic, `negative shift count: %d`, n)
+	case n > maxShift:
+		return newErrorf(object.KindArithmetic, `shift count too large: %d`, n)
+	}
+	return nil
+}
+
+func toBigInt(obj object.Object) *big.Int {
+	switch i := obj.(type) {
+	case *object.Integer:
+		return big.NewInt(i.Value)
+	case *object.BigInteger:
+		return i.Value
+	default:
+		return nil
+	}
+}
+
+// BigIntegerLiteral returns the value of an integer literal too large for an
+// int64, which overflows like the result of an operation would.
+func (e *Evaluator) BigIntegerLiteral(v *big.Int) object.Object {
+	low := new(big.Int).And(v, new(big.Int).SetUint64(math.MaxUint64))
+	exact := func() *big.Int { return new(big.Int).Set(v) }
+	return e.integerResult(int64(low.Uint64()), true, exact, `literal %s`, v)
+}
+
+// normalizeBigInteger returns an Integer if i fits in an int64, so that equal
+// values always have the same representation.
+func normalizeBigInteger(i *big.Int) object.Object {
+	if i.IsInt64() {
+		return &object.Integer{Value: i.Int64()}
+	}
+	return &object.BigInteger{Value: i}
+}
@@ -0,0 +1,133 @@
+package evaluator
+
+import (
+	"context"
+
+	"github.com/cszczepaniak/monkey/ast"
+	"github.com/cszczepaniak/monkey/object"
+)
+
+// cancelCheckInterval is how many steps pass between checks of whether the
+// context of a run is done, since checking is slow compared with a step. The
+// first step is always checked.
+const cancelCheckInterval = 1024
+
+// Budget tracks the resources used by one run of a program against the
+// limits of a Config. The vm shares it, so that both backends enforce the
+// same limits. A nil *Budget imposes no limits.
+type Budget struct {
+	ctx    context.Context
+	config Config
+	steps  int64
+	alloc  int64
+}
+
+// NewBudget returns a budget for a run which is canceled when ctx is done.
+func NewBudget(ctx context.Context, config Config) *Budget {
+	return &Budget{ctx: ctx, config: config}
+}
+
+// MaxDepth returns the call depth limit, applying the default.
+func (b *Budget) MaxDepth() int {
+	if b == nil || b.config.MaxDepth == 0 {
+		return DefaultMaxDepth
+	}
+	return b.config.MaxDepth
+}
+
+// Enter checks that a function may be called at the given depth, which is
+// the number of calls already in progress.
+func (b *Budget) Enter(depth int) *object.Error {
+	if depth >= b.MaxDepth() {
+		return StackOverflow()
+	}
+	return nil
+}
+
+// tailCallsNest reports whether tail calls count towards the call depth
+// limit. They do unless the host sets a step limit, since nothing else would
+// stop endless tail recursion.
+func (b *Budget) tailCallsNest() bool {
+	return b == nil || b.config.MaxSteps <= 0
+}
+
+// Step counts one step of the run, checking the step limit and, now and
+// then, whether the run was canceled.
+func (b *Budget) Step() *object.Error {
+	if b == nil {
+		return nil
+	}
+	b.steps++
+	if b.config.MaxSteps > 0 && b.steps > b.config.MaxSteps {
+		return newErrorf(object.KindStepLimit, `step limit of %d exceeded`, b.config.MaxSteps)
+	}
+	if b.steps%cancelCheckInterval == 1 {
+		if err := b.ctx.Err(); err != nil {
+			return newErrorf(object.KindCanceled, `evaluation canceled: %v`, err)
+		}
+	}
+	return nil
+}
+
+// Alloc charges the run for obj, which has just been created. Only objects
+// whose size depends on the program, such as strings and arrays, are
+// charged. Their elements are not, since they were charged when they were
+// created themselves.
+func (b *Budget) Alloc(obj object.Object) *object.Error {
+	return b.charge(sizeOf(obj))
+}
+
+// AllocHashEntry charges the run for an entry added to an existing hash.
+func (b *Budget) AllocHashEntry() *object.Error {
+	return b.charge(hashEntrySize)
+}
+
+func (b *Budget) charge(size int64) *object.Error {
+	if b == nil || b.config.MaxAlloc <= 0 {
+		return nil
+	}
+	b.alloc += size
+	if b.alloc > b.config.MaxAlloc {
+		return newErrorf(object.KindMemoryLimit, `memory limit of %d bytes exceeded`, b.config.MaxAlloc)
+	}
+	return nil
+}
+
+// StackOverflow returns the error for exceeding the call depth limit.
+func StackOverflow() *object.Error {
+	return newErrorf(object.KindCallDepth, `stack overflow`)
+}
+
+// hashEntrySize approximates the number of bytes taken by each entry of a
+// hash.
+const hashEntrySize = 64
+
+// sizeOf approximates the number of bytes taken by obj itself, not counting
+// the objects it refers to.
+func sizeOf(obj object.Object) int64 {
+	switch obj := obj.(type) {
+	case *object.String:
+		return 16 + int64(len(obj.Value))
+	case *object.Array:
+		return 24 + 16*int64(len(obj.Elements))
+	case *object.Hash:
+		return 48 + hashEntrySize*int64(obj.Len())
+	case *object.BigInteger:
+		return 32 + int64(len(obj.Value.Bits()))*8
+	default:
+		return 0
+	}
+}
+
+// allocates reports whether evaluating node creates a new object which the
+// evaluator should charge for, rather than returning an existing one.
+func allocates(node ast.Node) bool {
+	switch n := node.(type) {
+	case *ast.ArrayLiteral, *ast.HashLiteral, *ast.PrefixExpression, *ast.InfixExpression:
+		return true
+	case *ast.AssignExpression:
+		return n.Operator != `=`
+	default:
+		return false
+	}
+}
@@ -0,0 +1,127 @@
+package evaluator
+
+import (
+	"github.com/cszczepaniak/monkey/ast"
+	"github.com/cszczepaniak/monkey/object"
+)
+
+var (
+	breakSignal    = &object.Break{}
+	continueSignal = &object.Continue{}
+)
+
+func (e *Evaluator) evalWhileExpression(we *ast.WhileExpression, env *object.Environment) object.Object {
+	for {
+		c := e.eval(we.Condition, env)
+		if isAbrupt(c) {
+			return c
+		}
+		if !isTruthy(c) {
+			return NULL
+		}
+		if res, done := loopResult(e.evalBlockStatement(we.Body, env)); done {
+			return res
+		}
+	}
+}
+
+// evalForExpression binds the loop variable in env, like let, so it is still
+// set after the loop.
+func (e *Evaluator) evalForExpression(fe *ast.ForExpression, env *object.Environment) object.Object {
+	iterable := e.eval(fe.Iterable, env)
+	if isAbrupt(iterable) {
+		return iterable
+	}
+	it, err := Iterate(iterable)
+	if err != nil {
+		return err
+	}
+	name := fe.Variable.Value
+	if env.IsConst(name) {
+		return newErrorf(object.KindName, `cannot redeclare constant %s`, name)
+	}
+	for {
+		val, ok := it.Next()
+		if !ok {
+			return NULL
+		}
+		env.Set(name, val)
+		if res, done := loopResult(e.evalBlockStatement(fe.Body, env)); done {
+			return res
+		}
+	}
+}
+
+// loopResult interprets the result of a loop body, reporting whether the loop
+// is done and if so, what its result is.
+func loopResult(res object.Object) (object.Object, bool) {
+	switch res.Type() {
+	case object.RETURN, object.ERROR:
+		return res, true
+	case object.BREAK:
+		return NULL, true
+	default:
+		return nil, false
+	}
+}
+
+// Iterator yields the values bound by a for loop in turn.
+type Iterator interface {
+	// Next returns the next value, or false if there are none left.
+	Next() (object.Object, bool)
+}
+
+// Iterate returns an iterator over the elements of an array or a range, or
+// the keys of a hash in insertion order. Keys added to a hash while iterating
+// over it are not visited, while array elements are read as they are reached.
+func Iterate(obj object.Object) (Iterator, *object.Error) {
+	switch obj := obj.(type) {
+	case *object.Array:
+		return &arrayIterator{arr: obj}, nil
+	case *object.Hash:
+		return &hashIterator{pairs: obj.Pairs()}, nil
+	case *object.Range:
+		return &rangeIterator{r: obj, n: obj.Len()}, nil
+	default:
+		return nil, newErrorf(object.KindType, `cannot iterate over %s`, obj.Type())
+	}
+}
+
+type arrayIterator struct {
+	arr *object.Array
+	i   int
+}
+
+func (it *arrayIterator) Next() (object.Object, bool) {
+	if it.i >= len(it.arr.Elements) {
+		return nil, false
+	}
+	it.i++
+	return it.arr.Elements[it.i-1], true
+}
+
+type hashIterator struct {
+	pairs []object.HashPair
+	i     int
+}
+
+func (it *hashIterator) Next() (object.Object, bool) {
+	if it.i >= len(it.pairs) {
+		return nil, false
+	}
+	it.i++
+	return it.pairs[it.i-1].Key, true
+}
+
+type rangeIterator struct {
+	r    *object.Range
+	i, n int64
+}
+
+func (it *rangeIterator) Next() (object.Object, bool) {
+	if it.i >= it.n {
+		return nil, false
+	}
+	it.i++
+	return &object.Integer{Value: it.r.At(it.i - 1)}, true
+}
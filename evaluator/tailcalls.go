@@ -0,0 +1,116 @@
+package evaluator
+
+import (
+	"github.com/cszczepaniak/monkey/ast"
+	"github.com/cszczepaniak/monkey/object"
+	"github.com/cszczepaniak/monkey/token"
+)
+
+// tailCall is a call in tail position of a function body, which is returned
+// to applyFunction to be made there instead of being made where it appears.
+// The calling function has finished by then, so a chain of tail calls runs
+// in constant Go stack. A tailCall never escapes applyFunction.
+type tailCall struct {
+	fn       *object.Function
+	args     []object.Object
+	pos, end token.Position
+}
+
+func (tc *tailCall) Type() object.Type { return `TAIL_CALL` }
+func (tc *tailCall) Inspect() string   { return `tail call` }
+
+// trampoline calls fn with args from the call at pos, and then makes the
+// tail call it returns, if any, and any tail call that returns, until one
+// returns a value. Each tail call takes the place of the call that made it
+// in the stack of an error. Unless the host set a step limit, each tail call
+// still counts towards the call depth limit, as it does on the vm.
+func (e *Evaluator) trampoline(fn *object.Function, args []object.Object, pos token.Position) object.Object {
+	depth := e.depth
+	defer func() { e.depth = depth }()
+	for {
+		res := e.callFunction(fn, args)
+		tc, ok := res.(*tailCall)
+		if ok && e.budget.tailCallsNest() {
+			if err := e.budget.Enter(e.depth); err != nil {
+				err.Pos, err.End = tc.pos, tc.end
+				res, ok = err, false
+			} else {
+				e.depth++
+			}
+		}
+		if !ok {
+			if err, ok := res.(*object.Error); ok {
+				err.Stack = append(err.Stack, object.Frame{Function: fn.Name, Pos: pos})
+			}
+			return res
+		}
+		fn, args, pos = tc.fn, tc.args, tc.pos
+	}
+}
+
+// evalTail evaluates an expression in tail position, returning a call to a
+// Monkey function as a tailCall rather than making it. An if expression
+// passes tail position on to its branches.
+func (e *Evaluator) evalTail(node ast.Expression, env *object.Environment) object.Object {
+	switch n := node.(type) {
+	case *ast.CallExpression:
+		if err := e.budget.Step(); err != nil {
+			locate(err, n)
+			return err
+		}
+		fn, args, err := e.evalCall(n, env)
+		if err != nil {
+			return err
+		}
+		f, ok := fn.(*object.Function)
+		if !ok {
+			res := e.applyFunction(fn, args, n.Pos())
+			if err, ok := res.(*object.Error); ok && !err.Pos.IsValid() {
+				locate(err, n)
+			}
+			return res
+		}
+		if err := checkArity(f, len(args)); err != nil {
+			locate(err, n)
+			return err
+		}
+		return &tailCall{fn: f, args: args, pos: n.Pos(), end: n.End()}
+	case *ast.IfExpression:
+		if err := e.budget.Step(); err != nil {
+			locate(err, n)
+			return err
+		}
+		c := e.eval(n.Condition, env)
+		if isAbrupt(c) {
+			return c
+		}
+		if isTruthy(c) {
+			return e.evalTailBlock(n.Consequence, env)
+		}
+		if n.Alternative != nil {
+			return e.evalTailBlock(n.Alternative, env)
+		}
+		return NULL
+	default:
+		return e.eval(node, env)
+	}
+}
+
+// evalTailBlock evaluates a block whose value is in tail position, such as a
+// function body. Its last statement, if it is an expression, is in tail
+// position too.
+func (e *Evaluator) evalTailBlock(bs *ast.BlockStatement, env *object.Environment) object.Object {
+	if len(bs.Statements) == 0 {
+		return NULL
+	}
+	last := len(bs.Statements) - 1
+	for _, stmt := range bs.Statements[:last] {
+		if res := e.eval(stmt, env); isAbrupt(res) {
+			return res
+		}
+	}
+	if es, ok := bs.Statements[last].(*ast.ExpressionStatement); ok && es.Expression != nil {
+		return e.evalTail(es.Expression, env)
+	}
+	return e.eval(bs.Statements[last], env)
+}
@@ -0,0 +1,141 @@
+// Package monkey embeds the Monkey interpreter in Go programs.
+package monkey
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/cszczepaniak/monkey/evaluator"
+	"github.com/cszczepaniak/monkey/lexer"
+	"github.com/cszczepaniak/monkey/object"
+	"github.com/cszczepaniak/monkey/parser"
+)
+
+// Interpreter evaluates Monkey programs in a persistent environment, so
+// bindings made by one call to Run are visible to the next. An Interpreter is
+// not safe for concurrent use.
+type Interpreter struct {
+	env       *object.Environment
+	evaluator *evaluator.Evaluator
+}
+
+func New() *Interpreter {
+	return NewWithConfig(evaluator.Config{})
+}
+
+func NewWithConfig(config evaluator.Config) *Interpreter {
+	return &Interpreter{
+		env:       object.NewEnvironment(),
+		evaluator: evaluator.New(config),
+	}
+}
+
+// ParseError is returned when the source passed to Run does not parse.
+// ErrorList holds the same errors as Errors, as structured values.
+type ParseError struct {
+	Errors    []string
+	ErrorList []*parser.Error
+}
+
+func (e *ParseError) Error() string {
+	return strings.Join(e.Errors, "\n")
+}
+
+// Env returns the environment the interpreter evaluates programs in.
+func (in *Interpreter) Env() *object.Environment {
+	return in.env
+}
+
+// Set binds name to a Go value, converted as described by ToObject. Functions
+// are registered as if by RegisterFunc.
+func (in *Interpreter) Set(name string, value interface{}) error {
+	if reflect.TypeOf(value) != nil && reflect.TypeOf(value).Kind() == reflect.Func {
+		return in.RegisterFunc(name, value)
+	}
+	obj, err := ToObject(value)
+	if err != nil {
+		return fmt.Errorf(`cannot set %s: %w`, name, err)
+	}
+	in.env.Set(name, obj)
+	return nil
+}
+
+// RegisterFunc makes a Go function callable from Monkey as name. Arguments are
+// converted from Monkey objects to the function's parameter types, and the
+// function may return nothing, a value, an error, or a value and an error. A
+// non-nil error becomes a Monkey error.
+func (in *Interpreter) RegisterFunc(name string, fn interface{}) error {
+	if reflect.TypeOf(fn) == nil || reflect.TypeOf(fn).Kind() != reflect.Func {
+		return fmt.Errorf(`cannot register %s: %T is not a function`, name, fn)
+	}
+	builtin, err := newBuiltin(name, reflect.ValueOf(fn))
+	if err != nil {
+		return fmt.Errorf(`cannot register %s: %w`, name, err)
+	}
+	in.env.Set(name, builtin)
+	return nil
+}
+
+// Run parses and evaluates src. A program that fails to parse returns a
+// *ParseError, and one that evaluates to a Monkey error returns it as an
+// *object.Error.
+func (in *Interpreter) Run(src string) (object.Object, error) {
+	return in.RunFile(``, src)
+}
+
+// RunFile is like Run, but positions in errors report the given file name.
+func (in *Interpreter) RunFile(filename, src string) (object.Object, error) {
+	return in.RunFileContext(context.Background(), filename, src)
+}
+
+// RunContext is like Run, but stops evaluating once ctx is done, returning
+// an *object.Error of kind object.KindCanceled.
+func (in *Interpreter) RunContext(ctx context.Context, src string) (object.Object, error) {
+	return in.RunFileContext(ctx, ``, src)
+}
+
+// RunFileContext is like RunFile, but stops evaluating once ctx is done.
+func (in *Interpreter) RunFileContext(ctx context.Context, filename, src string) (object.Object, error) {
+	p := parser.New(lexer.NewFile(filename, src))
+	program := p.ParseProgram()
+	if len(p.Errors()) > 0 {
+		return nil, &ParseError{Errors: p.Errors(), ErrorList: p.ErrorList()}
+	}
+	return result(in.evaluator.EvalContext(ctx, program, in.env))
+}
+
+// Call calls the Monkey function bound to name with args converted by
+// ToObject.
+func (in *Interpreter) Call(name string, args ...interface{}) (object.Object, error) {
+	return in.CallContext(context.Background(), name, args...)
+}
+
+// CallContext is like Call, but stops evaluating once ctx is done.
+// Builtins are found by name unless the environment rebinds it.
+func (in *Interpreter) CallContext(ctx context.Context, name string, args ...interface{}) (object.Object, error) {
+	fn, ok := in.env.Get(name)
+	if !ok {
+		fn, ok = in.evaluator.LookupBuiltin(name)
+	}
+	if !ok {
+		return nil, fmt.Errorf(`identifier not found: %s`, name)
+	}
+	objs := make([]object.Object, len(args))
+	for i, a := range args {
+		obj, err := ToObject(a)
+		if err != nil {
+			return nil, fmt.Errorf(`argument %d to %s: %w`, i+1, name, err)
+		}
+		objs[i] = obj
+	}
+	return result(in.evaluator.ApplyFunctionContext(ctx, fn, objs))
+}
+
+func result(obj object.Object) (object.Object, error) {
+	if errObj, ok := obj.(*object.Error); ok {
+		return nil, errObj
+	}
+	return obj, nil
+}
@@ -0,0 +1,280 @@
+package monkey
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"math"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cszczepaniak/monkey/evaluator"
+	"github.com/cszczepaniak/monkey/object"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunKeepsBindings(t *testing.T) {
+	in := New()
+
+	_, err := in.Run(`let double = fn(x) { x * 2 };`)
+	require.NoError(t, err)
+
+	res, err := in.Run(`double(21)`)
+	require.NoError(t, err)
+	assert.Equal(t, int64(42), FromObject(res))
+}
+
+func TestRunErrors(t *testing.T) {
+	in := New()
+
+	_, err := in.Run(`let x = ;`)
+	assert.IsType(t, &ParseError{}, err)
+	assert.Contains(t, err.Error(), `1:9: no prefix parse function for ; found`)
+
+	_, err = in.Run(`1 + true`)
+	assert.IsType(t, &object.Error{}, err)
+	assert.Equal(t, `1:1: type mismatch: INTEGER + BOOLEAN`, err.Error())
+}
+
+func TestRegisterFunc(t *testing.T) {
+	tests := []struct {
+		name     string
+		fn       interface{}
+		input    string
+		expected interface{}
+	}{{
+		`add`, func(a, b int64) int64 { return a + b }, `add(2, 3)`, int64(5),
+	}, {
+		`upper`, strings.ToUpper, `upper("abc")`, `ABC`,
+	}, {
+		`not`, func(b bool) bool { return !b }, `not(false)`, true,
+	}, {
+		`sqrt`, math.Sqrt, `sqrt(2.25) + sqrt(4)`, 3.5,
+	}, {
+		`sum`, func(xs []int) int {
+			total := 0
+			for _, x := range xs {
+				total += x
+			}
+			return total
+		}, `sum([1, 2, 3])`, int64(6),
+	}, {
+		`join`, func(sep string, parts ...string) string { return strings.Join(parts, sep) },
+		`join("-", "a", "b", "c")`, `a-b-c`,
+	}, {
+		`keys`, func(m map[string]int) []string {
+			var keys []string
+			for k := range m {
+				keys = append(keys, k)
+			}
+			return keys
+		}, `keys({"only": 1})`, []interface{}{`only`},
+	}, {
+		`pair`, func() map[string]interface{} { return map[string]interface{}{`b`: 2, `a`: `x`} },
+		`pair()["a"] + pair()["a"]`, `xx`,
+	}, {
+		`nothing`, func() {}, `nothing()`, nil,
+	}, {
+		`check`, func(n int) (int, error) {
+			if n < 0 {
+				return 0, errors.New(`negative`)
+			}
+			return n, nil
+		}, `check(4)`, int64(4),
+	}, {
+		`any`, func(v interface{}) interface{} { return v }, `any([1, "a"])`, []interface{}{int64(1), `a`},
+	}, {
+		`obj`, func(o object.Object) string { return string(o.Type()) }, `obj({})`, `HASH`,
+	}}
+
+	for _, tc := range tests {
+		in := New()
+		require.NoError(t, in.RegisterFunc(tc.name, tc.fn))
+		res, err := in.Run(tc.input)
+		require.NoError(t, err, tc.input)
+		assert.Equal(t, tc.expected, FromObject(res), tc.input)
+	}
+}
+
+func TestRegisterFuncErrors(t *testing.T) {
+	in := New()
+	require.NoError(t, in.RegisterFunc(`check`, func(n int8) (int8, error) {
+		if n < 0 {
+			return 0, errors.New(`negative`)
+		}
+		if n == 0 {
+			return 0, &object.Error{Message: `zero`, Kind: object.KindArithmetic}
+		}
+		return n, nil
+	}))
+	require.NoError(t, in.RegisterFunc(`boom`, func() int { panic(`boom`) }))
+
+	tests := []struct {
+		input   string
+		expKind object.ErrorKind
+		expErr  string
+	}{
+		{`check(-1)`, object.KindRuntime, `1:1: negative`},
+		{`check(0)`, object.KindArithmetic, `1:1: zero`},
+		{`check()`, object.KindArgument, `1:1: wrong number of arguments: want 1, got 0`},
+		{`check("a")`, object.KindArgument, "1:1: argument 1 to `check`: cannot use STRING as int8"},
+		{`check(1000)`, object.KindArgument, "1:1: argument 1 to `check`: 1000 overflows int8"},
+		{`boom()`, object.KindRuntime, `1:1: internal error: boom`},
+	}
+	for _, tc := range tests {
+		_, err := in.Run(tc.input)
+		require.IsType(t, &object.Error{}, err, tc.input)
+		assert.Equal(t, tc.expKind, err.(*object.Error).Kind, tc.input)
+		assert.EqualError(t, err, tc.expErr, tc.input)
+	}
+
+	assert.Error(t, in.RegisterFunc(`notAFunc`, 5))
+	assert.Error(t, in.RegisterFunc(`tooMany`, func() (int, int, error) { return 0, 0, nil }))
+	assert.Error(t, in.RegisterFunc(`badSecond`, func() (int, int) { return 0, 0 }))
+}
+
+func TestSetAndCall(t *testing.T) {
+	in := New()
+	require.NoError(t, in.Set(`greeting`, `hello`))
+	require.NoError(t, in.Set(`nums`, []int{1, 2, 3}))
+	require.NoError(t, in.Set(`shout`, strings.ToUpper))
+
+	_, err := in.Run(`let greet = fn(name) { shout(greeting + ", " + name) + "!" };`)
+	require.NoError(t, err)
+
+	res, err := in.Call(`greet`, `monkey`)
+	require.NoError(t, err)
+	assert.Equal(t, `HELLO, MONKEY!`, FromObject(res))
+
+	res, err = in.Call(`len`, []int{1})
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), FromObject(res))
+
+	res, err = in.Call(`nope`)
+	assert.Nil(t, res)
+	assert.EqualError(t, err, `identifier not found: nope`)
+
+	res, err = in.Run(`len(nums)`)
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), FromObject(res))
+
+	_, err = in.Call(`greet`, struct{}{})
+	assert.EqualError(t, err, `argument 1 to greet: unsupported Go type struct {}`)
+
+	err = in.Set(`bad`, map[[1]int]int{{1}: 1})
+	assert.EqualError(t, err, `cannot set bad: unusable as hash key: ARRAY`)
+}
+
+func TestHostPanicsBecomeErrors(t *testing.T) {
+	in := New()
+	require.NoError(t, in.RegisterFunc(`explode`, func() int { panic(`kaboom`) }))
+
+	_, err := in.Run(`explode()`)
+	assert.EqualError(t, err, `1:1: internal error: kaboom`)
+}
+
+func TestOverflowConfig(t *testing.T) {
+	in := NewWithConfig(evaluator.Config{Overflow: evaluator.OverflowPromote})
+	require.NoError(t, in.RegisterFunc(`digits`, func(i *big.Int) int { return len(i.String()) }))
+
+	res, err := in.Run(`let big = 9223372036854775807 * 9223372036854775807; digits(big)`)
+	require.NoError(t, err)
+	assert.Equal(t, int64(38), FromObject(res))
+
+	in = NewWithConfig(evaluator.Config{Overflow: evaluator.OverflowError})
+	_, err = in.Run(`9223372036854775807 + 1`)
+	assert.EqualError(t, err, `1:1: integer overflow: 9223372036854775807 + 1`)
+}
+
+func TestOutputConfig(t *testing.T) {
+	var out bytes.Buffer
+	in := NewWithConfig(evaluator.Config{Out: &out})
+	_, err := in.Run(`puts("a")`)
+	require.NoError(t, err)
+	_, err = in.Call(`puts`, 1)
+	require.NoError(t, err)
+	assert.Equal(t, "a\n1\n", out.String())
+}
+
+func TestLimits(t *testing.T) {
+	tests := []struct {
+		config  evaluator.Config
+		input   string
+		expKind object.ErrorKind
+		expErr  string
+	}{{
+		evaluator.Config{},
+		`let f = fn() { 1 + f() }; f()`,
+		object.KindCallDepth,
+		`1:20: stack overflow`,
+	}, {
+		evaluator.Config{MaxDepth: 10},
+		`let f = fn(n) { if (n > 0) { 1 + f(n - 1) } }; f(20)`,
+		object.KindCallDepth,
+		`1:34: stack overflow`,
+	}, {
+		// Without a step limit, tail calls count towards the call depth, so
+		// the default config stops endless tail recursion too.
+		evaluator.Config{},
+		`let f = fn() { f() }; f()`,
+		object.KindCallDepth,
+		`1:16: stack overflow`,
+	}, {
+		// With one, tail calls do not nest and the step limit stops it.
+		evaluator.Config{MaxSteps: 10000},
+		`let f = fn() { f() }; f()`,
+		object.KindStepLimit,
+		`1:16: step limit of 10000 exceeded`,
+	}, {
+		evaluator.Config{MaxSteps: 1000},
+		`while (true) {}`,
+		object.KindStepLimit,
+		`1:8: step limit of 1000 exceeded`,
+	}, {
+		evaluator.Config{MaxAlloc: 1 << 20},
+		`let s = "x"; while (true) { s += s }`,
+		object.KindMemoryLimit,
+		`1:29: memory limit of 1048576 bytes exceeded`,
+	}}
+
+	for _, tc := range tests {
+		_, err := NewWithConfig(tc.config).Run(tc.input)
+		require.IsType(t, &object.Error{}, err, tc.input)
+		assert.Equal(t, tc.expKind, err.(*object.Error).Kind, tc.input)
+		assert.EqualError(t, err, tc.expErr, tc.input)
+	}
+}
+
+func TestLimitsApplyPerRun(t *testing.T) {
+	in := NewWithConfig(evaluator.Config{MaxSteps: 1000})
+	for i := 0; i < 3; i++ {
+		_, err := in.Run(`let n = 0; while (n < 50) { n += 1 }`)
+		require.NoError(t, err)
+	}
+}
+
+func TestRunContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	in := New()
+	require.NoError(t, in.RegisterFunc(`cancel`, cancel))
+
+	_, err := in.RunContext(ctx, `cancel(); while (true) {}`)
+	require.IsType(t, &object.Error{}, err)
+	assert.Equal(t, object.KindCanceled, err.(*object.Error).Kind)
+	assert.Contains(t, err.Error(), `evaluation canceled: context canceled`)
+
+	_, err = in.Run(`let f = fn() { 1 }`)
+	require.NoError(t, err)
+	_, err = in.CallContext(ctx, `f`)
+	require.IsType(t, &object.Error{}, err)
+	assert.Equal(t, object.KindCanceled, err.(*object.Error).Kind)
+
+	ctx, cancel = context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err = NewWithConfig(evaluator.Config{MaxSteps: 1 << 40}).RunContext(ctx, `let f = fn() { f() }; f()`)
+	require.IsType(t, &object.Error{}, err)
+	assert.Equal(t, object.KindCanceled, err.(*object.Error).Kind)
+}
@@ -1,24 +1,118 @@
 package lexer
 
-import "github.com/cszczepaniak/monkey/token"
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/cszczepaniak/monkey/token"
+)
+
+// Mode controls optional lexer behaviour.
+type Mode uint
+
+const (
+	// ScanComments makes the lexer return comments as COMMENT tokens rather
+	// than skipping them.
+	ScanComments Mode = 1 << iota
+)
 
 type Lexer struct {
+	mode         Mode
+	filename     string
 	input        string
-	position     int
-	readPosition int
-	ch           byte
+	position     int // byte offset of ch
+	readPosition int // byte offset of the character after ch
+	ch           rune
+	line         int
+	column       int
+	errors       []Error
+}
+
+// Error is a problem found in the input.
+type Error struct {
+	Pos token.Position
+	Msg string
+}
+
+func (e Error) Error() string {
+	return e.Pos.String() + `: ` + e.Msg
 }
 
+const bom = "\uFEFF"
+
 func New(input string) *Lexer {
-	l := &Lexer{input: input}
+	return NewFile(``, input)
+}
+
+// NewFile returns a Lexer whose token positions report the given file name.
+// The input is decoded as UTF-8, and a leading byte order mark is ignored.
+func NewFile(filename, input string) *Lexer {
+	l := &Lexer{filename: filename, input: input, line: 1}
+	if strings.HasPrefix(input, bom) {
+		l.readPosition = len(bom)
+	}
 	l.readChar()
 	return l
 }
 
+// Mode returns the lexer's mode.
+func (l *Lexer) Mode() Mode {
+	return l.mode
+}
+
+// SetMode sets the lexer's mode for the tokens read after it.
+func (l *Lexer) SetMode(mode Mode) {
+	l.mode = mode
+}
+
 func (l *Lexer) NextToken() token.Token {
-	var tok token.Token
+	return l.NextTokenMode(l.mode)
+}
+
+// NextTokenMode is like NextToken, but reads the token in the given mode
+// rather than the lexer's own, which is left as it is.
+func (l *Lexer) NextTokenMode(mode Mode) token.Token {
+	for {
+		l.skipWhitespace()
+
+		pos := l.pos()
+		var tok token.Token
+		if l.ch == '/' && (l.peekChar() == '/' || l.peekChar() == '*') {
+			tok = l.readComment()
+			if tok.Type == token.COMMENT && mode&ScanComments == 0 {
+				continue
+			}
+		} else {
+			tok = l.readToken()
+		}
+		tok.Pos = pos
+		tok.End = l.pos()
+		return tok
+	}
+}
+
+// Errors returns the problems found in the input so far. Each one has a
+// corresponding ILLEGAL token in the token stream.
+func (l *Lexer) Errors() []string {
+	errs := make([]string, len(l.errors))
+	for i, e := range l.errors {
+		errs[i] = e.Error()
+	}
+	return errs
+}
 
-	l.skipWhitespace()
+// ErrorList returns the problems found in the input so far, like Errors, as
+// structured values.
+func (l *Lexer) ErrorList() []Error {
+	return l.errors
+}
+
+func (l *Lexer) readToken() token.Token {
+	var tok token.Token
 
 	switch l.ch {
 	case '=':
@@ -39,6 +133,17 @@ func (l *Lexer) NextToken() token.Token {
 		}
 	case ';':
 		tok = token.New(token.SEMICOLON, l.ch)
+	case ':':
+		tok = token.New(token.COLON, l.ch)
+	case '.':
+		if l.peekChar() == '.' && l.peekCharN(2) == '.' {
+			l.readChar()
+			l.readChar()
+			tok = token.Token{Type: token.ELLIPSIS, Literal: `...`}
+		} else {
+			l.errorf(l.pos(), `illegal character %q`, l.ch)
+			tok = token.New(token.ILLEGAL, l.ch)
+		}
 	case '(':
 		tok = token.New(token.LPAREN, l.ch)
 	case ')':
@@ -46,21 +151,37 @@ func (l *Lexer) NextToken() token.Token {
 	case ',':
 		tok = token.New(token.COMMA, l.ch)
 	case '+':
-		tok = token.New(token.PLUS, l.ch)
+		tok = l.readOperator(token.PLUS, map[rune]token.Type{'=': token.PLUS_ASSIGN})
 	case '-':
-		tok = token.New(token.MINUS, l.ch)
+		tok = l.readOperator(token.MINUS, map[rune]token.Type{'=': token.MINUS_ASSIGN})
 	case '*':
-		tok = token.New(token.ASTERISK, l.ch)
+		tok = l.readOperator(token.ASTERISK, map[rune]token.Type{'=': token.ASTERISK_ASSIGN})
 	case '/':
-		tok = token.New(token.SLASH, l.ch)
+		tok = l.readOperator(token.SLASH, map[rune]token.Type{'=': token.SLASH_ASSIGN})
+	case '%':
+		tok = token.New(token.PERCENT, l.ch)
 	case '<':
-		tok = token.New(token.LT, l.ch)
+		tok = l.readOperator(token.LT, map[rune]token.Type{'=': token.LTE, '<': token.LSHIFT})
 	case '>':
-		tok = token.New(token.GT, l.ch)
+		tok = l.readOperator(token.GT, map[rune]token.Type{'=': token.GTE, '>': token.RSHIFT})
+	case '&':
+		tok = l.readOperator(token.AMPERSAND, map[rune]token.Type{'&': token.AND})
+	case '|':
+		tok = l.readOperator(token.PIPE, map[rune]token.Type{'|': token.OR})
+	case '^':
+		tok = token.New(token.CARET, l.ch)
+	case '~':
+		tok = token.New(token.TILDE, l.ch)
 	case '{':
 		tok = token.New(token.LBRACE, l.ch)
 	case '}':
 		tok = token.New(token.RBRACE, l.ch)
+	case '[':
+		tok = token.New(token.LBRACKET, l.ch)
+	case ']':
+		tok = token.New(token.RBRACKET, l.ch)
+	case '"':
+		tok = l.readString()
 	case 0:
 		tok.Literal = ""
 		tok.Type = token.EOF
@@ -70,60 +191,314 @@ func (l *Lexer) NextToken() token.Token {
 			tok.Type = token.LookupIdent(tok.Literal)
 			return tok
 		} else if isDigit(l.ch) {
-			tok.Type = token.INT
-			tok.Literal = l.readNumber()
-			return tok
+			return l.readNumber()
+		}
+		// Invalid UTF-8 has already been reported by readChar.
+		if !l.invalidUTF8() {
+			l.errorf(l.pos(), `illegal character %q`, l.ch)
 		}
-		tok = token.New(token.ILLEGAL, l.ch)
+		tok = token.Token{Type: token.ILLEGAL, Literal: l.input[l.position:l.readPosition]}
 	}
 
 	l.readChar()
 	return tok
 }
 
+// readOperator reads an operator which is either the single character typ or,
+// if the next character is in twoChar, the two-character operator it maps to.
+// It leaves the lexer on the operator's last character.
+func (l *Lexer) readOperator(typ token.Type, twoChar map[rune]token.Type) token.Token {
+	if t, ok := twoChar[l.peekChar()]; ok {
+		l.readChar()
+		return token.Token{Type: t, Literal: string(t)}
+	}
+	return token.New(typ, l.ch)
+}
+
+// readComment reads a // comment up to the end of the line, or a /* */
+// comment, which may contain nested /* */ comments. It leaves the lexer just
+// past the comment. A comment containing invalid UTF-8 is ILLEGAL.
+func (l *Lexer) readComment() token.Token {
+	start := l.pos()
+	errs := len(l.errors)
+	comment := func() token.Token {
+		tok := token.Token{Type: token.COMMENT, Literal: l.input[start.Offset:l.position]}
+		if len(l.errors) > errs {
+			tok.Type = token.ILLEGAL
+		}
+		return tok
+	}
+	if l.peekChar() == '/' {
+		for l.ch != '\n' && l.ch != 0 {
+			l.readChar()
+		}
+		return comment()
+	}
+
+	depth := 0
+	for {
+		switch {
+		case l.ch == 0:
+			l.errorf(start, `unterminated block comment`)
+			return token.Token{Type: token.ILLEGAL, Literal: l.input[start.Offset:l.position]}
+		case l.ch == '/' && l.peekChar() == '*':
+			depth++
+			l.readChar()
+		case l.ch == '*' && l.peekChar() == '/':
+			depth--
+			l.readChar()
+			if depth == 0 {
+				l.readChar()
+				return comment()
+			}
+		}
+		l.readChar()
+	}
+}
+
 func (l *Lexer) skipWhitespace() {
 	for l.ch == ' ' || l.ch == '\t' || l.ch == '\n' || l.ch == '\r' {
 		l.readChar()
 	}
 }
 
+// readChar advances to the next character, reporting it if it is not valid
+// UTF-8. At the end of the input, ch is 0.
 func (l *Lexer) readChar() {
-	if l.readPosition >= len(l.input) {
+	if l.readPosition > len(l.input) {
+		return
+	}
+	if l.ch == '\n' {
+		l.line++
+		l.column = 0
+	}
+	l.column++
+	if l.readPosition == len(l.input) {
 		l.ch = 0
-	} else {
-		l.ch = l.input[l.readPosition]
+		l.position = len(l.input)
+		l.readPosition = len(l.input) + 1
+		return
 	}
+	r, width := utf8.DecodeRuneInString(l.input[l.readPosition:])
+	l.ch = r
 	l.position = l.readPosition
-	l.readPosition++
+	l.readPosition += width
+	if l.invalidUTF8() {
+		l.errorf(l.pos(), `invalid UTF-8 encoding`)
+	}
+}
+
+// invalidUTF8 reports whether ch stands in for a byte that is not valid
+// UTF-8, rather than being an encoded U+FFFD.
+func (l *Lexer) invalidUTF8() bool {
+	return l.ch == utf8.RuneError && l.readPosition-l.position == 1
 }
 
-func (l *Lexer) peekChar() byte {
-	if l.readPosition >= len(l.input) {
+func (l *Lexer) pos() token.Position {
+	return token.Position{
+		Filename: l.filename,
+		Offset:   l.position,
+		Line:     l.line,
+		Column:   l.column,
+	}
+}
+
+func (l *Lexer) peekChar() rune {
+	return l.peekCharN(1)
+}
+
+// peekCharN returns the character n characters ahead of the current one.
+func (l *Lexer) peekCharN(n int) rune {
+	next := l.readPosition
+	for ; n > 1 && next < len(l.input); n-- {
+		_, width := utf8.DecodeRuneInString(l.input[next:])
+		next += width
+	}
+	if next >= len(l.input) {
 		return 0
 	}
-	return l.input[l.readPosition]
+	r, _ := utf8.DecodeRuneInString(l.input[next:])
+	return r
+}
+
+// readString reads a double-quoted string literal, leaving the lexer on the
+// closing quote. The token's literal is the unescaped value.
+func (l *Lexer) readString() token.Token {
+	start := l.position
+	ok := true
+	var out strings.Builder
+	for {
+		l.readChar()
+		switch l.ch {
+		case '"':
+			if !ok {
+				return token.Token{Type: token.ILLEGAL, Literal: l.input[start : l.position+1]}
+			}
+			return token.Token{Type: token.STRING, Literal: out.String()}
+		case 0, '\n':
+			l.errorf(l.pos(), `unterminated string literal`)
+			return token.Token{Type: token.ILLEGAL, Literal: l.input[start:l.position]}
+		case '\\':
+			if !l.readEscape(&out) {
+				ok = false
+			}
+		default:
+			if l.invalidUTF8() {
+				ok = false
+			}
+			out.WriteRune(l.ch)
+		}
+	}
+}
+
+func (l *Lexer) readEscape(out *strings.Builder) bool {
+	pos := l.pos()
+	switch l.peekChar() {
+	case 'n':
+		out.WriteByte('\n')
+	case 't':
+		out.WriteByte('\t')
+	case 'r':
+		out.WriteByte('\r')
+	case '"':
+		out.WriteByte('"')
+	case '\\':
+		out.WriteByte('\\')
+	case 'u':
+		l.readChar()
+		return l.readUnicodeEscape(pos, out)
+	case 0, '\n':
+		return false
+	default:
+		l.errorf(pos, `unknown escape sequence \%c`, l.peekChar())
+		l.readChar()
+		return false
+	}
+	l.readChar()
+	return true
+}
+
+func (l *Lexer) readUnicodeEscape(pos token.Position, out *strings.Builder) bool {
+	if l.peekChar() != '{' {
+		l.errorf(pos, `malformed unicode escape: expected \u{...}`)
+		return false
+	}
+	l.readChar()
+	digits := l.position + 1
+	for isHexDigit(l.peekChar()) {
+		l.readChar()
+	}
+	hex := l.input[digits : l.position+1]
+	if l.peekChar() != '}' || len(hex) == 0 || len(hex) > 6 {
+		l.errorf(pos, `malformed unicode escape: expected \u{...}`)
+		return false
+	}
+	l.readChar()
+
+	r, _ := strconv.ParseUint(hex, 16, 32)
+	if r > utf8.MaxRune || r >= 0xD800 && r <= 0xDFFF {
+		l.errorf(pos, `invalid code point in unicode escape: %s`, hex)
+		return false
+	}
+	out.WriteRune(rune(r))
+	return true
+}
+
+func (l *Lexer) errorf(pos token.Position, format string, a ...interface{}) {
+	l.errors = append(l.errors, Error{Pos: pos, Msg: fmt.Sprintf(format, a...)})
 }
 
 func (l *Lexer) readIdentifier() string {
 	position := l.position
-	for isLetter(l.ch) {
+	for isLetter(l.ch) || unicode.IsDigit(l.ch) {
 		l.readChar()
 	}
 	return l.input[position:l.position]
 }
 
-func (l *Lexer) readNumber() string {
-	position := l.position
-	for isDigit(l.ch) {
+// readNumber reads an integer or float literal, using Go's syntax: integers
+// may have a 0x, 0o or 0b prefix, and digits may be separated by underscores.
+// Letters and digits directly following the literal are read as part of it,
+// so that "0b12" is reported as one malformed literal.
+func (l *Lexer) readNumber() token.Token {
+	pos := l.pos()
+	tok := token.Token{Type: token.INT}
+
+	var digits func(rune) bool
+	if l.ch == '0' {
+		switch l.peekChar() {
+		case 'x', 'X':
+			digits = isHexDigit
+		case 'o', 'O':
+			digits = isOctalDigit
+		case 'b', 'B':
+			digits = isBinaryDigit
+		}
+	}
+	if digits != nil {
+		l.readChar()
+		l.readChar()
+		l.readDigits(digits)
+	} else {
+		l.readDigits(isDigit)
+		if l.ch == '.' && isDigit(l.peekChar()) {
+			tok.Type = token.FLOAT
+			l.readChar()
+			l.readDigits(isDigit)
+		}
+		if l.ch == 'e' || l.ch == 'E' {
+			tok.Type = token.FLOAT
+			l.readChar()
+			if l.ch == '+' || l.ch == '-' {
+				l.readChar()
+			}
+			l.readDigits(isDigit)
+		}
+	}
+	for isLetter(l.ch) || unicode.IsDigit(l.ch) {
+		l.readChar()
+	}
+	tok.Literal = l.input[pos.Offset:l.position]
+
+	var err error
+	if tok.Type == token.FLOAT {
+		_, err = strconv.ParseFloat(tok.Literal, 64)
+	} else {
+		_, err = strconv.ParseInt(tok.Literal, 0, 64)
+	}
+	if errors.Is(err, strconv.ErrSyntax) {
+		l.errorf(pos, `malformed number literal %s`, tok.Literal)
+		tok.Type = token.ILLEGAL
+	}
+	return tok
+}
+
+func (l *Lexer) readDigits(digits func(rune) bool) {
+	for digits(l.ch) || l.ch == '_' {
 		l.readChar()
 	}
-	return l.input[position:l.position]
 }
 
-func isLetter(ch byte) bool {
-	return ch >= 'a' && ch <= 'z' || ch >= 'A' && ch <= 'Z' || ch == '_'
+// isLetter reports whether ch may start an identifier. As in Go, identifiers
+// are made of Unicode letters, digits and underscores, and start with a
+// letter or underscore.
+func isLetter(ch rune) bool {
+	return ch >= 'a' && ch <= 'z' || ch >= 'A' && ch <= 'Z' || ch == '_' ||
+		ch >= utf8.RuneSelf && unicode.IsLetter(ch)
 }
 
-func isDigit(ch byte) bool {
+func isDigit(ch rune) bool {
 	return ch >= '0' && ch <= '9'
 }
+
+func isBinaryDigit(ch rune) bool {
+	return ch == '0' || ch == '1'
+}
+
+func isOctalDigit(ch rune) bool {
+	return ch >= '0' && ch <= '7'
+}
+
+func isHexDigit(ch rune) bool {
+	return isDigit(ch) || ch >= 'a' && ch <= 'f' || ch >= 'A' && ch <= 'F'
+}
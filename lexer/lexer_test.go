@@ -18,7 +18,7 @@ func TestNextToken(t *testing.T) {
 			  
 		let result = add(five, ten);
 
-		!-/*5;
+		!-/ *5%2;
 		5 < 10 > 5;
 		if (5 < 10) {
 			return true;
@@ -27,6 +27,14 @@ func TestNextToken(t *testing.T) {
 		}
 		10 == 10;
 		10 != 9;
+		[1, 2];
+		{"foo": "bar"}
+		fn(...rest)
+		a <= b >= c && d || e;
+		~a & b | c ^ d << e >> f;
+		const g = 1; g += 1 -= 1 *= 1 /= 1;
+		while for in break continue
+		try catch finally throw
 		`
 
 	tests := []struct {
@@ -74,6 +82,8 @@ func TestNextToken(t *testing.T) {
 		{token.SLASH, "/"},
 		{token.ASTERISK, "*"},
 		{token.INT, "5"},
+		{token.PERCENT, "%"},
+		{token.INT, "2"},
 		{token.SEMICOLON, ";"},
 		{token.INT, "5"},
 		{token.LT, "<"},
@@ -106,6 +116,69 @@ func TestNextToken(t *testing.T) {
 		{token.NEQ, "!="},
 		{token.INT, "9"},
 		{token.SEMICOLON, ";"},
+		{token.LBRACKET, "["},
+		{token.INT, "1"},
+		{token.COMMA, ","},
+		{token.INT, "2"},
+		{token.RBRACKET, "]"},
+		{token.SEMICOLON, ";"},
+		{token.LBRACE, "{"},
+		{token.STRING, "foo"},
+		{token.COLON, ":"},
+		{token.STRING, "bar"},
+		{token.RBRACE, "}"},
+		{token.FUNCTION, "fn"},
+		{token.LPAREN, "("},
+		{token.ELLIPSIS, "..."},
+		{token.IDENT, "rest"},
+		{token.RPAREN, ")"},
+		{token.IDENT, "a"},
+		{token.LTE, "<="},
+		{token.IDENT, "b"},
+		{token.GTE, ">="},
+		{token.IDENT, "c"},
+		{token.AND, "&&"},
+		{token.IDENT, "d"},
+		{token.OR, "||"},
+		{token.IDENT, "e"},
+		{token.SEMICOLON, ";"},
+		{token.TILDE, "~"},
+		{token.IDENT, "a"},
+		{token.AMPERSAND, "&"},
+		{token.IDENT, "b"},
+		{token.PIPE, "|"},
+		{token.IDENT, "c"},
+		{token.CARET, "^"},
+		{token.IDENT, "d"},
+		{token.LSHIFT, "<<"},
+		{token.IDENT, "e"},
+		{token.RSHIFT, ">>"},
+		{token.IDENT, "f"},
+		{token.SEMICOLON, ";"},
+		{token.CONST, "const"},
+		{token.IDENT, "g"},
+		{token.ASSIGN, "="},
+		{token.INT, "1"},
+		{token.SEMICOLON, ";"},
+		{token.IDENT, "g"},
+		{token.PLUS_ASSIGN, "+="},
+		{token.INT, "1"},
+		{token.MINUS_ASSIGN, "-="},
+		{token.INT, "1"},
+		{token.ASTERISK_ASSIGN, "*="},
+		{token.INT, "1"},
+		{token.SLASH_ASSIGN, "/="},
+		{token.INT, "1"},
+		{token.SEMICOLON, ";"},
+		{token.WHILE, "while"},
+		{token.FOR, "for"},
+		{token.IN, "in"},
+		{token.BREAK, "break"},
+		{token.CONTINUE, "continue"},
+		{token.TRY, "try"},
+		{token.CATCH, "catch"},
+		{token.FINALLY, "finally"},
+		{token.THROW, "throw"},
 		{token.EOF, ""},
 	}
 
@@ -118,3 +191,221 @@ func TestNextToken(t *testing.T) {
 		assert.Equal(t, tc.expectedLiteral, tok.Literal)
 	}
 }
+
+func TestTokenPositions(t *testing.T) {
+	input := "let x = 10;\n  x == 5\n"
+
+	tests := []struct {
+		expectedType token.Type
+		expectedPos  string
+		expectedEnd  string
+	}{
+		{token.LET, `test.mk:1:1`, `test.mk:1:4`},
+		{token.IDENT, `test.mk:1:5`, `test.mk:1:6`},
+		{token.ASSIGN, `test.mk:1:7`, `test.mk:1:8`},
+		{token.INT, `test.mk:1:9`, `test.mk:1:11`},
+		{token.SEMICOLON, `test.mk:1:11`, `test.mk:1:12`},
+		{token.IDENT, `test.mk:2:3`, `test.mk:2:4`},
+		{token.EQ, `test.mk:2:5`, `test.mk:2:7`},
+		{token.INT, `test.mk:2:8`, `test.mk:2:9`},
+		{token.EOF, `test.mk:3:1`, `test.mk:3:1`},
+		{token.EOF, `test.mk:3:1`, `test.mk:3:1`},
+	}
+
+	l := NewFile(`test.mk`, input)
+
+	for _, tc := range tests {
+		tok := l.NextToken()
+
+		assert.Equal(t, tc.expectedType, tok.Type)
+		assert.Equal(t, tc.expectedPos, tok.Pos.String())
+		assert.Equal(t, tc.expectedEnd, tok.End.String())
+	}
+}
+
+func TestTokenOffsets(t *testing.T) {
+	l := New("a\nbc")
+
+	tok := l.NextToken()
+	assert.Equal(t, 0, tok.Pos.Offset)
+	assert.Equal(t, 1, tok.End.Offset)
+
+	tok = l.NextToken()
+	assert.Equal(t, 2, tok.Pos.Offset)
+	assert.Equal(t, 4, tok.End.Offset)
+	assert.Equal(t, `2:1`, tok.Pos.String())
+}
+
+func TestStringLiterals(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`"foobar"`, `foobar`},
+		{`"foo bar"`, `foo bar`},
+		{`""`, ``},
+		{`"a\nb\tc"`, "a\nb\tc"},
+		{`"say \"hi\""`, `say "hi"`},
+		{`"back\\slash"`, `back\slash`},
+		{`"\u{48}\u{1F600}"`, "H\U0001F600"},
+		{`"héllo"`, `héllo`},
+	}
+
+	for _, tc := range tests {
+		l := New(tc.input)
+		tok := l.NextToken()
+		assert.Equal(t, token.Type(token.STRING), tok.Type)
+		assert.Equal(t, tc.expected, tok.Literal)
+		assert.Equal(t, len(tc.input), tok.End.Offset)
+		assert.Equal(t, token.Type(token.EOF), l.NextToken().Type)
+		assert.Empty(t, l.Errors())
+	}
+}
+
+func TestLexerErrors(t *testing.T) {
+	tests := []struct {
+		input  string
+		expErr string
+	}{
+		{`"abc`, `1:5: unterminated string literal`},
+		{"\"abc\ndef", `1:5: unterminated string literal`},
+		{`"a\qb"`, `1:3: unknown escape sequence \q`},
+		{`"\u0041"`, `1:2: malformed unicode escape: expected \u{...}`},
+		{`"\u{}"`, `1:2: malformed unicode escape: expected \u{...}`},
+		{`"\u{D800}"`, `1:2: invalid code point in unicode escape: D800`},
+		{`let # = 1`, `1:5: illegal character '#'`},
+		{`a.b`, `1:2: illegal character '.'`},
+		{"1 /* a /* b */", `1:3: unterminated block comment`},
+		{"let a = \xff", `1:9: invalid UTF-8 encoding`},
+		{"\"héllo\xffworld\"", `1:7: invalid UTF-8 encoding`},
+		{"1 // é\xc3", `1:7: invalid UTF-8 encoding`},
+		{"let π = 1 € 2", `1:11: illegal character '€'`},
+		{`0b102`, `1:1: malformed number literal 0b102`},
+		{`1__000`, `1:1: malformed number literal 1__000`},
+		{`0x`, `1:1: malformed number literal 0x`},
+		{`1e+`, `1:1: malformed number literal 1e+`},
+		{`12abc`, `1:1: malformed number literal 12abc`},
+	}
+
+	for _, tc := range tests {
+		l := New(tc.input)
+		illegal := false
+		for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+			illegal = illegal || tok.Type == token.ILLEGAL
+		}
+		assert.True(t, illegal, tc.input)
+		assert.Equal(t, []string{tc.expErr}, l.Errors())
+	}
+}
+
+func TestNumbers(t *testing.T) {
+	tests := []struct {
+		input        string
+		expectedType token.Type
+	}{
+		{`0`, token.INT},
+		{`1_000_000`, token.INT},
+		{`0xff`, token.INT},
+		{`0XFF_FF`, token.INT},
+		{`0o755`, token.INT},
+		{`0b1010`, token.INT},
+		{`3.14`, token.FLOAT},
+		{`1e-9`, token.FLOAT},
+		{`1E+9`, token.FLOAT},
+		{`6.022_140e23`, token.FLOAT},
+	}
+
+	for _, tc := range tests {
+		l := New(tc.input)
+		tok := l.NextToken()
+		assert.Equal(t, tc.expectedType, tok.Type, tc.input)
+		assert.Equal(t, tc.input, tok.Literal)
+		assert.Equal(t, token.Type(token.EOF), l.NextToken().Type, tc.input)
+		assert.Empty(t, l.Errors(), tc.input)
+	}
+
+	// A dot not followed by a digit is not part of the number.
+	l := New(`1.a`)
+	assert.Equal(t, `1`, l.NextToken().Literal)
+	assert.Equal(t, `.`, l.NextToken().Literal)
+}
+
+func TestUnicode(t *testing.T) {
+	input := "\uFEFFlet π = \"ünïcode\";\nlet 变量1 = π;\nÄ_2 a1"
+
+	tests := []struct {
+		expectedType    token.Type
+		expectedLiteral string
+		expectedPos     string
+		expectedOffset  int
+	}{
+		{token.LET, `let`, `1:1`, 3},
+		{token.IDENT, `π`, `1:5`, 7},
+		{token.ASSIGN, `=`, `1:7`, 10},
+		{token.STRING, `ünïcode`, `1:9`, 12},
+		{token.SEMICOLON, `;`, `1:18`, 23},
+		{token.LET, `let`, `2:1`, 25},
+		{token.IDENT, `变量1`, `2:5`, 29},
+		{token.ASSIGN, `=`, `2:9`, 37},
+		{token.IDENT, `π`, `2:11`, 39},
+		{token.SEMICOLON, `;`, `2:12`, 41},
+		{token.IDENT, `Ä_2`, `3:1`, 43},
+		{token.IDENT, `a1`, `3:5`, 48},
+		{token.EOF, ``, `3:7`, 50},
+	}
+
+	l := New(input)
+	for _, tc := range tests {
+		tok := l.NextToken()
+		assert.Equal(t, tc.expectedType, tok.Type)
+		assert.Equal(t, tc.expectedLiteral, tok.Literal)
+		assert.Equal(t, tc.expectedPos, tok.Pos.String())
+		assert.Equal(t, tc.expectedOffset, tok.Pos.Offset)
+	}
+	assert.Empty(t, l.Errors())
+}
+
+func TestComments(t *testing.T) {
+	input := "a // line\nb /* block /* nested */ */ c\n/* multi\nline */ d // end"
+
+	l := New(input)
+	var lits []string
+	for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+		lits = append(lits, tok.Literal)
+	}
+	assert.Equal(t, []string{`a`, `b`, `c`, `d`}, lits)
+	assert.Empty(t, l.Errors())
+
+	tests := []struct {
+		expectedType    token.Type
+		expectedLiteral string
+		expectedPos     string
+	}{
+		{token.IDENT, `a`, `1:1`},
+		{token.COMMENT, `// line`, `1:3`},
+		{token.IDENT, `b`, `2:1`},
+		{token.COMMENT, `/* block /* nested */ */`, `2:3`},
+		{token.IDENT, `c`, `2:28`},
+		{token.COMMENT, "/* multi\nline */", `3:1`},
+		{token.IDENT, `d`, `4:9`},
+		{token.COMMENT, `// end`, `4:11`},
+		{token.EOF, ``, `4:17`},
+	}
+
+	l = New(input)
+	l.SetMode(ScanComments)
+	for _, tc := range tests {
+		tok := l.NextToken()
+		assert.Equal(t, tc.expectedType, tok.Type)
+		assert.Equal(t, tc.expectedLiteral, tok.Literal)
+		assert.Equal(t, tc.expectedPos, tok.Pos.String())
+	}
+
+	l = New(input)
+	for _, tc := range tests {
+		tok := l.NextTokenMode(ScanComments)
+		assert.Equal(t, tc.expectedType, tok.Type)
+		assert.Equal(t, tc.expectedLiteral, tok.Literal)
+	}
+	assert.Equal(t, Mode(0), l.Mode())
+}
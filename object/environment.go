@@ -1,8 +1,20 @@
 package object
 
+import (
+	"errors"
+	"sort"
+)
+
+// Errors returned by Environment.Assign.
+var (
+	ErrUndefined = errors.New(`undefined`)
+	ErrConstant  = errors.New(`constant`)
+)
+
 type Environment struct {
-	store map[string]Object
-	outer *Environment
+	store  map[string]Object
+	consts map[string]bool
+	outer  *Environment
 }
 
 func NewEnvironment() *Environment {
@@ -23,7 +35,60 @@ func (e *Environment) Get(name string) (Object, bool) {
 	return v, ok
 }
 
+// Set binds name to val in e, replacing any binding of name in e, even a
+// constant one.
 func (e *Environment) Set(name string, val Object) Object {
 	e.store[name] = val
+	delete(e.consts, name)
+	return val
+}
+
+// SetConst binds name to val in e as a constant, which Assign refuses to
+// change.
+func (e *Environment) SetConst(name string, val Object) Object {
+	e.store[name] = val
+	if e.consts == nil {
+		e.consts = make(map[string]bool)
+	}
+	e.consts[name] = true
 	return val
 }
+
+// IsConst reports whether name is bound as a constant in e itself, ignoring
+// the environments enclosing it.
+func (e *Environment) IsConst(name string) bool {
+	return e.consts[name]
+}
+
+// Assign rebinds name to val in the innermost environment, starting at e,
+// which binds it. It returns ErrUndefined if no environment binds name, and
+// ErrConstant if the binding is constant.
+func (e *Environment) Assign(name string, val Object) error {
+	for env := e; env != nil; env = env.outer {
+		if _, ok := env.store[name]; ok {
+			if env.consts[name] {
+				return ErrConstant
+			}
+			env.store[name] = val
+			return nil
+		}
+	}
+	return ErrUndefined
+}
+
+// Names returns the names bound in e and the environments enclosing it, in
+// sorted order.
+func (e *Environment) Names() []string {
+	seen := make(map[string]bool)
+	var names []string
+	for env := e; env != nil; env = env.outer {
+		for name := range env.store {
+			if !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+	}
+	sort.Strings(names)
+	return names
+}
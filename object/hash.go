@@ -0,0 +1,138 @@
+package object
+
+import (
+	"bytes"
+	"hash/fnv"
+)
+
+type HashKey struct {
+	Type  Type
+	Value uint64
+}
+
+// Hashable is implemented by objects which can be used as hash keys. Equal
+// objects must produce equal HashKeys.
+type Hashable interface {
+	Object
+	HashKey() HashKey
+}
+
+func (i *Integer) HashKey() HashKey {
+	return HashKey{Type: i.Type(), Value: uint64(i.Value)}
+}
+
+func (bi *BigInteger) HashKey() HashKey {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(bi.Value.String()))
+	return HashKey{Type: bi.Type(), Value: h.Sum64()}
+}
+
+func (b *Boolean) HashKey() HashKey {
+	var v uint64
+	if b.Value {
+		v = 1
+	}
+	return HashKey{Type: b.Type(), Value: v}
+}
+
+func (s *String) HashKey() HashKey {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(s.Value))
+	return HashKey{Type: s.Type(), Value: h.Sum64()}
+}
+
+type HashPair struct {
+	Key   Hashable
+	Value Object
+}
+
+// Hash maps hashable keys to values, remembering the order in which keys were
+// first inserted. Keys with equal HashKeys are told apart by comparing them.
+type Hash struct {
+	// buckets maps each HashKey to the indexes in pairs of the keys with it.
+	buckets map[HashKey][]int
+	pairs   []HashPair
+}
+
+func NewHash() *Hash {
+	return &Hash{buckets: make(map[HashKey][]int)}
+}
+
+func (h *Hash) Get(key Hashable) (Object, bool) {
+	if i, ok := h.find(key, key.HashKey()); ok {
+		return h.pairs[i].Value, true
+	}
+	return nil, false
+}
+
+// Set maps key to value. It reports whether key was added, rather than
+// already in the hash.
+func (h *Hash) Set(key Hashable, value Object) bool {
+	hk := key.HashKey()
+	if i, ok := h.find(key, hk); ok {
+		h.pairs[i] = HashPair{Key: key, Value: value}
+		return false
+	}
+	h.buckets[hk] = append(h.buckets[hk], len(h.pairs))
+	h.pairs = append(h.pairs, HashPair{Key: key, Value: value})
+	return true
+}
+
+// find returns the index in h.pairs of key, whose HashKey is hk.
+func (h *Hash) find(key Hashable, hk HashKey) (int, bool) {
+	for _, i := range h.buckets[hk] {
+		if keysEqual(h.pairs[i].Key, key) {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+func (h *Hash) Len() int {
+	return len(h.pairs)
+}
+
+// Pairs returns the key/value pairs in insertion order.
+func (h *Hash) Pairs() []HashPair {
+	return append([]HashPair(nil), h.pairs...)
+}
+
+// keysEqual reports whether a and b are the same key.
+func keysEqual(a, b Hashable) bool {
+	switch a := a.(type) {
+	case *Integer:
+		b, ok := b.(*Integer)
+		return ok && a.Value == b.Value
+	case *BigInteger:
+		b, ok := b.(*BigInteger)
+		return ok && a.Value.Cmp(b.Value) == 0
+	case *Boolean:
+		b, ok := b.(*Boolean)
+		return ok && a.Value == b.Value
+	case *String:
+		b, ok := b.(*String)
+		return ok && a.Value == b.Value
+	default:
+		return a.Type() == b.Type() && a.Inspect() == b.Inspect()
+	}
+}
+
+func (h *Hash) Inspect() string {
+	var out bytes.Buffer
+
+	out.WriteString(`{`)
+	for i, pair := range h.Pairs() {
+		out.WriteString(pair.Key.Inspect())
+		out.WriteString(`: `)
+		out.WriteString(pair.Value.Inspect())
+		if i < len(h.pairs)-1 {
+			out.WriteString(`, `)
+		}
+	}
+	out.WriteString(`}`)
+
+	return out.String()
+}
+func (h *Hash) Type() Type {
+	return HASH
+}
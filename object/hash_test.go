@@ -0,0 +1,61 @@
+package object
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHashKeys(t *testing.T) {
+	hello1 := &String{Value: `Hello World`}
+	hello2 := &String{Value: `Hello World`}
+	diff := &String{Value: `My name is johnny`}
+
+	assert.Equal(t, hello1.HashKey(), hello2.HashKey())
+	assert.NotEqual(t, hello1.HashKey(), diff.HashKey())
+
+	assert.Equal(t, (&Integer{Value: 1}).HashKey(), (&Integer{Value: 1}).HashKey())
+	assert.NotEqual(t, (&Integer{Value: 1}).HashKey(), (&Boolean{Value: true}).HashKey())
+}
+
+func TestHashInsertionOrder(t *testing.T) {
+	h := NewHash()
+	h.Set(&String{Value: `b`}, &Integer{Value: 1})
+	h.Set(&String{Value: `a`}, &Integer{Value: 2})
+	h.Set(&String{Value: `b`}, &Integer{Value: 3})
+
+	assert.Equal(t, 2, h.Len())
+	assert.Equal(t, `{"b": 3, "a": 2}`, h.Inspect())
+
+	v, ok := h.Get(&String{Value: `a`})
+	assert.True(t, ok)
+	assert.Equal(t, &Integer{Value: 2}, v)
+
+	_, ok = h.Get(&String{Value: `c`})
+	assert.False(t, ok)
+}
+
+func TestHashKeyCollisions(t *testing.T) {
+	// A big integer hashes into the same key space as the integers, so some
+	// integer has the same HashKey.
+	large := &BigInteger{Value: new(big.Int).Lsh(big.NewInt(1), 64)}
+	small := &Integer{Value: int64(large.HashKey().Value)}
+	assert.Equal(t, large.HashKey(), small.HashKey())
+
+	h := NewHash()
+	h.Set(large, &String{Value: `big`})
+	_, ok := h.Get(small)
+	assert.False(t, ok)
+
+	h.Set(small, &String{Value: `small`})
+	h.Set(&BigInteger{Value: new(big.Int).Set(large.Value)}, &String{Value: `big again`})
+	assert.Equal(t, 2, h.Len())
+
+	v, ok := h.Get(large)
+	assert.True(t, ok)
+	assert.Equal(t, &String{Value: `big again`}, v)
+	v, ok = h.Get(small)
+	assert.True(t, ok)
+	assert.Equal(t, &String{Value: `small`}, v)
+}
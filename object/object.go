@@ -3,17 +3,33 @@ package object
 import (
 	"bytes"
 	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
 
 	"github.com/cszczepaniak/monkey/ast"
+	"github.com/cszczepaniak/monkey/code"
+	"github.com/cszczepaniak/monkey/token"
 )
 
 const (
 	INTEGER  = "INTEGER"
+	FLOAT    = "FLOAT"
+	STRING   = "STRING"
 	BOOLEAN  = "BOOLEAN"
 	NULL     = "NULL"
 	RETURN   = "RETURN"
+	BREAK    = "BREAK"
+	CONTINUE = "CONTINUE"
 	ERROR    = "ERROR"
 	FUNCTION = "FUNCTION"
+	ARRAY    = "ARRAY"
+	HASH     = "HASH"
+	RANGE    = "RANGE"
+	BUILTIN  = "BUILTIN"
+
+	COMPILED_FUNCTION = "COMPILED_FUNCTION"
 )
 
 type Type string
@@ -34,6 +50,48 @@ func (i *Integer) Type() Type {
 	return INTEGER
 }
 
+// BigInteger holds an integer outside the range of int64. It has the same
+// Monkey type as Integer; arithmetic converts results which fit back into an
+// Integer, so the two never hold equal values.
+type BigInteger struct {
+	Value *big.Int
+}
+
+func (bi *BigInteger) Inspect() string {
+	return bi.Value.String()
+}
+func (bi *BigInteger) Type() Type {
+	return INTEGER
+}
+
+type Float struct {
+	Value float64
+}
+
+// Inspect formats the float so that it always reads back as a float: whole
+// numbers keep a trailing ".0".
+func (f *Float) Inspect() string {
+	s := strconv.FormatFloat(f.Value, 'g', -1, 64)
+	if !strings.ContainsAny(s, `.eIN`) {
+		s += `.0`
+	}
+	return s
+}
+func (f *Float) Type() Type {
+	return FLOAT
+}
+
+type String struct {
+	Value string
+}
+
+func (s *String) Inspect() string {
+	return token.Quote(s.Value)
+}
+func (s *String) Type() Type {
+	return STRING
+}
+
 type Boolean struct {
 	Value bool
 }
@@ -65,39 +123,315 @@ func (rv *ReturnValue) Type() Type {
 	return RETURN
 }
 
+// Break and Continue are the results of break and continue statements. Like
+// ReturnValue, they stop the evaluation of blocks until they reach the
+// enclosing loop.
+type Break struct{}
+
+func (b *Break) Inspect() string {
+	return `break`
+}
+func (b *Break) Type() Type {
+	return BREAK
+}
+
+type Continue struct{}
+
+func (c *Continue) Inspect() string {
+	return `continue`
+}
+func (c *Continue) Type() Type {
+	return CONTINUE
+}
+
+// ErrorKind classifies errors, so that a host can tell why a program failed
+// without parsing the message.
+type ErrorKind int
+
+const (
+	// KindRuntime is an error that fits no other kind, such as a panic in a
+	// host function.
+	KindRuntime ErrorKind = iota
+	// KindType is an operation on a value of the wrong type, such as a type
+	// mismatch or calling something that is not a function.
+	KindType
+	// KindName is a use of an undefined identifier, or an assignment to a
+	// constant.
+	KindName
+	// KindIndex is an index out of range.
+	KindIndex
+	// KindArgument is a call with the wrong number of arguments, or with an
+	// argument whose value its function does not accept.
+	KindArgument
+	// KindArithmetic is a division by zero, an integer overflow or a bad
+	// shift count.
+	KindArithmetic
+	// KindThrown is a value raised by a throw statement.
+	KindThrown
+	// The remaining kinds report that a program exceeded one of the limits
+	// set by its host, or that the host canceled it.
+	KindCallDepth
+	KindStepLimit
+	KindMemoryLimit
+	KindCanceled
+)
+
+func (k ErrorKind) String() string {
+	switch k {
+	case KindRuntime:
+		return `runtime`
+	case KindType:
+		return `type`
+	case KindName:
+		return `name`
+	case KindIndex:
+		return `index`
+	case KindArgument:
+		return `argument`
+	case KindArithmetic:
+		return `arithmetic`
+	case KindThrown:
+		return `thrown`
+	case KindCallDepth:
+		return `call depth`
+	case KindStepLimit:
+		return `step limit`
+	case KindMemoryLimit:
+		return `memory limit`
+	case KindCanceled:
+		return `canceled`
+	default:
+		return fmt.Sprintf(`ErrorKind(%d)`, int(k))
+	}
+}
+
+// IsLimit reports whether k is one of the kinds for exceeding a limit or
+// being canceled. Such errors end the run; a try expression cannot catch
+// them.
+func (k ErrorKind) IsLimit() bool {
+	return k >= KindCallDepth
+}
+
+// Frame is a call in the Monkey call stack: the function called, named by
+// the let statement it was bound in if any, and the position of the call.
+// Pos is invalid for a call made by the host.
+type Frame struct {
+	Function string
+	Pos      token.Position
+}
+
+func (f Frame) String() string {
+	name := f.Function
+	if name == `` {
+		name = `anonymous function`
+	}
+	if !f.Pos.IsValid() {
+		return `in ` + name
+	}
+	return `in ` + name + `, called at ` + f.Pos.String()
+}
+
 type Error struct {
 	Message string
+	Kind    ErrorKind
+	// Pos and End are the start and end of the node that failed.
+	Pos, End token.Position
+	// Stack holds the calls that were active when the error was raised,
+	// innermost first. A tail call replaces the call that made it.
+	Stack []Frame
+	// Value is the value thrown, for an error of kind KindThrown.
+	Value Object
 }
 
 func (e *Error) Inspect() string {
-	return `ERROR: ` + e.Message
+	return `ERROR: ` + e.Error()
 }
 func (e *Error) Type() Type {
 	return ERROR
 }
 
+// Error lets a Monkey error be returned as a Go error.
+func (e *Error) Error() string {
+	if e.Pos.IsValid() {
+		return e.Pos.String() + `: ` + e.Message
+	}
+	return e.Message
+}
+
+// tracebackEdge is the number of frames Traceback shows at each end of a
+// long stack.
+const tracebackEdge = 10
+
+// Traceback returns the error followed by its call stack, one frame per
+// line. The middle of a long stack, such as that of a runaway recursion, is
+// elided.
+func (e *Error) Traceback() string {
+	var out strings.Builder
+	out.WriteString(e.Error())
+	for _, line := range e.StackLines() {
+		out.WriteString("\n  " + line)
+	}
+	return out.String()
+}
+
+// StackLines returns the lines of the call stack Traceback writes after the
+// error.
+func (e *Error) StackLines() []string {
+	var lines []string
+	for i, f := range e.Stack {
+		if len(e.Stack) > 2*tracebackEdge+1 && i >= tracebackEdge && i < len(e.Stack)-tracebackEdge {
+			if i == tracebackEdge {
+				lines = append(lines, fmt.Sprintf(`... %d more calls`, len(e.Stack)-2*tracebackEdge))
+			}
+			continue
+		}
+		lines = append(lines, f.String())
+	}
+	return lines
+}
+
 type Function struct {
-	Args []*ast.Identifier
-	Body *ast.BlockStatement
-	Env  *Environment
+	// Name is the name the function was bound to by a let statement, if
+	// any.
+	Name     string
+	Args     []*ast.Identifier
+	Defaults []ast.Expression
+	Rest     *ast.Identifier
+	Body     *ast.BlockStatement
+	Env      *Environment
 }
 
 func (f *Function) Inspect() string {
+	return inspectFunction(f.Args, f.Defaults, f.Rest, f.Body)
+}
+func (f *Function) Type() Type {
+	return FUNCTION
+}
+
+type Array struct {
+	Elements []Object
+}
+
+func (a *Array) Inspect() string {
 	var out bytes.Buffer
 
-	out.WriteString(`fn(`)
-	for i, a := range f.Args {
-		out.WriteString(a.String())
-		if i < len(f.Args)-1 {
+	out.WriteString(`[`)
+	for i, el := range a.Elements {
+		out.WriteString(el.Inspect())
+		if i < len(a.Elements)-1 {
 			out.WriteString(`, `)
 		}
 	}
+	out.WriteString(`]`)
+
+	return out.String()
+}
+func (a *Array) Type() Type {
+	return ARRAY
+}
+
+// Range is the sequence of integers from Start up to, but not including,
+// Stop, counting by Step. A negative Step counts down.
+type Range struct {
+	Start, Stop, Step int64
+}
+
+func (r *Range) Inspect() string {
+	return fmt.Sprintf(`range(%d, %d, %d)`, r.Start, r.Stop, r.Step)
+}
+func (r *Range) Type() Type {
+	return RANGE
+}
+
+// Len returns the number of integers in the range, or math.MaxInt64 if there
+// are more than that.
+func (r *Range) Len() int64 {
+	var span, step uint64
+	switch {
+	case r.Step > 0 && r.Start < r.Stop:
+		span, step = uint64(r.Stop)-uint64(r.Start), uint64(r.Step)
+	case r.Step < 0 && r.Start > r.Stop:
+		span, step = uint64(r.Start)-uint64(r.Stop), -uint64(r.Step)
+	default:
+		return 0
+	}
+	n := (span-1)/step + 1
+	if n > math.MaxInt64 {
+		return math.MaxInt64
+	}
+	return int64(n)
+}
+
+// At returns the i'th integer in the range.
+func (r *Range) At(i int64) int64 {
+	return int64(uint64(r.Start) + uint64(i)*uint64(r.Step))
+}
+
+type BuiltinFunction func(args ...Object) Object
+
+type Builtin struct {
+	Name string
+	Fn   BuiltinFunction
+}
+
+func (b *Builtin) Inspect() string {
+	return `builtin function ` + b.Name
+}
+func (b *Builtin) Type() Type {
+	return BUILTIN
+}
+
+func inspectFunction(args []*ast.Identifier, defaults []ast.Expression, rest *ast.Identifier, body *ast.BlockStatement) string {
+	var out bytes.Buffer
+
+	out.WriteString(`fn(`)
+	out.WriteString(ast.ParamsString(args, defaults, rest))
 	out.WriteString(") {\n")
-	out.WriteString(f.Body.String())
+	out.WriteString(body.String())
 	out.WriteString("\n}")
 
 	return out.String()
 }
-func (f *Function) Type() Type {
+
+// CompiledFunction is a function compiled to bytecode. Parameters occupy the
+// first NumParams locals, followed by the rest parameter if Variadic.
+// Positions and Ends map instruction offsets to the start and end of the
+// source they were compiled from, and LocalNames names each local slot.
+type CompiledFunction struct {
+	// Name is the name the function was bound to by a let statement, if
+	// any.
+	Name         string
+	Instructions code.Instructions
+	NumLocals    int
+	NumParams    int
+	NumRequired  int
+	Variadic     bool
+	Positions    map[int]token.Position
+	Ends         map[int]token.Position
+	LocalNames   []string
+	Literal      *ast.FunctionLiteral
+}
+
+func (cf *CompiledFunction) Inspect() string {
+	return fmt.Sprintf(`CompiledFunction[%p]`, cf)
+}
+func (cf *CompiledFunction) Type() Type {
+	return COMPILED_FUNCTION
+}
+
+// Closure is a compiled function together with the free variables it
+// captured. It is the virtual machine's counterpart to Function.
+type Closure struct {
+	Fn   *CompiledFunction
+	Free []Object
+}
+
+func (c *Closure) Inspect() string {
+	if lit := c.Fn.Literal; lit != nil {
+		return inspectFunction(lit.Args, lit.Defaults, lit.Rest, lit.Body)
+	}
+	return c.Fn.Inspect()
+}
+func (c *Closure) Type() Type {
 	return FUNCTION
 }
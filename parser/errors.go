@@ -0,0 +1,23 @@
+package parser
+
+import (
+	"github.com/cszczepaniak/monkey/token"
+)
+
+// Error is a lexical or syntax error in the input.
+type Error struct {
+	Pos token.Position
+	Msg string
+
+	// Expected holds the types of token that would have been accepted where
+	// the error was found, if the parser knows them.
+	Expected []token.Type
+
+	// Found is the token at which the error was found. It is the zero token
+	// for lexical errors.
+	Found token.Token
+}
+
+func (e *Error) Error() string {
+	return e.Pos.String() + `: ` + e.Msg
+}
@@ -1,7 +1,10 @@
 package parser
 
 import (
+	"errors"
 	"fmt"
+	"math/big"
+	"sort"
 	"strconv"
 
 	"github.com/cszczepaniak/monkey/ast"
@@ -18,43 +21,103 @@ type Parser struct {
 	l              *lexer.Lexer
 	curToken       token.Token
 	peekToken      token.Token
-	errors         []string
+	curDoc         *ast.CommentGroup
+	peekDoc        *ast.CommentGroup
+	errors         []*Error
 	prefixParseFns map[token.Type]prefixParseFn
 	infixParseFns  map[token.Type]infixParseFn
+
+	// loopDepth is the number of loops enclosing the current token within
+	// the innermost function, for checking break and continue.
+	loopDepth int
+
+	// depth is the number of braces left open before the current token, and
+	// lost is set from a syntax error until the parser has skipped to the
+	// end of the statement it is in.
+	depth int
+	lost  bool
 }
 
+// New returns a parser reading tokens from l. The parser reads comments from
+// l whatever its mode, so that it can attach doc comments to statements.
 func New(l *lexer.Lexer) *Parser {
-	p := &Parser{l: l, errors: []string{}}
+	p := &Parser{l: l}
 	p.nextToken()
 	p.nextToken()
 
 	p.prefixParseFns = make(map[token.Type]prefixParseFn)
 	p.registerPrefix(token.IDENT, p.parseIdentifier)
 	p.registerPrefix(token.INT, p.parseIntLiteral)
+	p.registerPrefix(token.FLOAT, p.parseFloatLiteral)
+	p.registerPrefix(token.STRING, p.parseStringLiteral)
+	p.registerPrefix(token.ILLEGAL, p.parseIllegal)
 	p.registerPrefix(token.TRUE, p.parseBoolLiteral)
 	p.registerPrefix(token.FALSE, p.parseBoolLiteral)
 	p.registerPrefix(token.BANG, p.parsePrefixExpression)
 	p.registerPrefix(token.MINUS, p.parsePrefixExpression)
+	p.registerPrefix(token.TILDE, p.parsePrefixExpression)
 	p.registerPrefix(token.LPAREN, p.parseGroupedExpression)
 	p.registerPrefix(token.IF, p.parseIfExpression)
+	p.registerPrefix(token.WHILE, p.parseWhileExpression)
+	p.registerPrefix(token.FOR, p.parseForExpression)
+	p.registerPrefix(token.TRY, p.parseTryExpression)
 	p.registerPrefix(token.FUNCTION, p.parseFunctionLiteral)
+	p.registerPrefix(token.LBRACKET, p.parseArrayLiteral)
+	p.registerPrefix(token.LBRACE, p.parseHashLiteral)
 
 	p.infixParseFns = make(map[token.Type]infixParseFn)
 	p.registerInfix(token.PLUS, p.parseInfixExpression)
 	p.registerInfix(token.MINUS, p.parseInfixExpression)
 	p.registerInfix(token.ASTERISK, p.parseInfixExpression)
 	p.registerInfix(token.SLASH, p.parseInfixExpression)
+	p.registerInfix(token.PERCENT, p.parseInfixExpression)
 	p.registerInfix(token.LT, p.parseInfixExpression)
 	p.registerInfix(token.GT, p.parseInfixExpression)
+	p.registerInfix(token.LTE, p.parseInfixExpression)
+	p.registerInfix(token.GTE, p.parseInfixExpression)
 	p.registerInfix(token.EQ, p.parseInfixExpression)
 	p.registerInfix(token.NEQ, p.parseInfixExpression)
+	p.registerInfix(token.AND, p.parseInfixExpression)
+	p.registerInfix(token.OR, p.parseInfixExpression)
+	p.registerInfix(token.AMPERSAND, p.parseInfixExpression)
+	p.registerInfix(token.PIPE, p.parseInfixExpression)
+	p.registerInfix(token.CARET, p.parseInfixExpression)
+	p.registerInfix(token.LSHIFT, p.parseInfixExpression)
+	p.registerInfix(token.RSHIFT, p.parseInfixExpression)
+	p.registerInfix(token.ASSIGN, p.parseAssignExpression)
+	p.registerInfix(token.PLUS_ASSIGN, p.parseAssignExpression)
+	p.registerInfix(token.MINUS_ASSIGN, p.parseAssignExpression)
+	p.registerInfix(token.ASTERISK_ASSIGN, p.parseAssignExpression)
+	p.registerInfix(token.SLASH_ASSIGN, p.parseAssignExpression)
 	p.registerInfix(token.LPAREN, p.parseCallExpression)
+	p.registerInfix(token.LBRACKET, p.parseIndexExpression)
 
 	return p
 }
 
+// Errors returns the lexical and syntax errors found in the input, in the
+// order they appear in it.
 func (p *Parser) Errors() []string {
-	return p.errors
+	list := p.ErrorList()
+	errs := make([]string, len(list))
+	for i, e := range list {
+		errs[i] = e.Error()
+	}
+	return errs
+}
+
+// ErrorList returns the errors found in the input, like Errors, as
+// structured values.
+func (p *Parser) ErrorList() []*Error {
+	var errs []*Error
+	for _, e := range p.l.ErrorList() {
+		errs = append(errs, &Error{Pos: e.Pos, Msg: e.Msg})
+	}
+	errs = append(errs, p.errors...)
+	sort.SliceStable(errs, func(i, j int) bool {
+		return errs[i].Pos.Offset < errs[j].Pos.Offset
+	})
+	return errs
 }
 
 func (p *Parser) ParseProgram() *ast.Program {
@@ -62,29 +125,48 @@ func (p *Parser) ParseProgram() *ast.Program {
 	program.Statements = []ast.Statement{}
 
 	for !p.curTokenIs(token.EOF) {
-		stmt := p.parseStatement()
-		if stmt != nil {
-			program.Statements = append(program.Statements, stmt)
-		}
+		program.Statements = append(program.Statements, p.parseStatement())
 		p.nextToken()
 	}
 
 	return program
 }
 
+// parseStatement parses the statement at the current token. If there is a
+// syntax error in it, the parser skips to its end and returns a BadStatement
+// in its place, so that it can carry on from the next statement.
 func (p *Parser) parseStatement() ast.Statement {
+	from, depth, lost := p.curToken.Pos, p.depth, p.lost
+	stmt := p.parseStatementKind()
+	if !p.lost {
+		return stmt
+	}
+	// A statement nested in one already being skipped is skipped with it.
+	if !lost {
+		p.synchronize(depth)
+	}
+	return &ast.BadStatement{From: from, To: p.curToken.End}
+}
+
+func (p *Parser) parseStatementKind() ast.Statement {
 	switch p.curToken.Type {
-	case token.LET:
+	case token.LET, token.CONST:
 		return p.parseLetStatement()
 	case token.RETURN:
 		return p.parseReturnStatement()
+	case token.THROW:
+		return p.parseThrowStatement()
+	case token.BREAK, token.CONTINUE:
+		return p.parseBranchStatement()
 	default:
 		return p.parseExpressionStatement()
 	}
 }
 
-func (p *Parser) parseLetStatement() *ast.LetStatement {
-	stmt := &ast.LetStatement{Token: p.curToken}
+// parseLetStatement returns nil if the statement could not be parsed, in
+// which case parseStatement replaces it.
+func (p *Parser) parseLetStatement() ast.Statement {
+	stmt := &ast.LetStatement{Token: p.curToken, Doc: p.curDoc}
 	if !p.expectPeek(token.IDENT) {
 		return nil
 	}
@@ -111,6 +193,29 @@ func (p *Parser) parseReturnStatement() *ast.ReturnStatement {
 	return stmt
 }
 
+func (p *Parser) parseThrowStatement() *ast.ThrowStatement {
+	stmt := &ast.ThrowStatement{Token: p.curToken}
+	p.nextToken()
+
+	stmt.Value = p.parseExpression(LOWEST)
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+	return stmt
+}
+
+func (p *Parser) parseBranchStatement() ast.Statement {
+	stmt := &ast.BranchStatement{Token: p.curToken}
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+	if p.loopDepth == 0 {
+		p.errorf(stmt.Token, `%s outside loop`, stmt.Token.Literal)
+		return &ast.BadStatement{From: stmt.Pos(), To: stmt.End()}
+	}
+	return stmt
+}
+
 func (p *Parser) parseExpressionStatement() *ast.ExpressionStatement {
 	stmt := &ast.ExpressionStatement{Token: p.curToken}
 
@@ -127,21 +232,33 @@ func (p *Parser) parseBlockStatement() *ast.BlockStatement {
 	block := &ast.BlockStatement{Token: p.curToken, Statements: []ast.Statement{}}
 	p.nextToken()
 
+	depth := p.depth
 	for !p.curTokenIs(token.RBRACE) && !p.curTokenIs(token.EOF) {
-		stmt := p.parseStatement()
-		if stmt != nil {
-			block.Statements = append(block.Statements, stmt)
+		block.Statements = append(block.Statements, p.parseStatement())
+		// A statement which fails at the closing brace, as in { return },
+		// ends the block there.
+		if p.level() < depth {
+			break
 		}
 		p.nextToken()
 	}
+	if p.curTokenIs(token.RBRACE) {
+		block.Rbrace = p.curToken
+	}
 	return block
 }
 
+// badExpression returns a placeholder for the expression from the position
+// from up to the current token, which could not be parsed.
+func (p *Parser) badExpression(from token.Position) ast.Expression {
+	return &ast.BadExpression{From: from, To: p.curToken.End}
+}
+
 func (p *Parser) parseExpression(precedence int) ast.Expression {
 	prefix, ok := p.prefixParseFns[p.curToken.Type]
 	if !ok {
-		p.noPrefixParseFnError(p.curToken.Type)
-		return nil
+		p.noPrefixParseFnError()
+		return &ast.BadExpression{From: p.curToken.Pos, To: p.curToken.End}
 	}
 	leftExp := prefix()
 
@@ -173,11 +290,30 @@ func (p *Parser) parseInfixExpression(left ast.Expression) ast.Expression {
 	return expr
 }
 
+// parseAssignExpression parses the value of an assignment to left. The value
+// is parsed below assignment precedence, so that a = b = c assigns b first.
+func (p *Parser) parseAssignExpression(left ast.Expression) ast.Expression {
+	expr := &ast.AssignExpression{Token: p.curToken, Operator: p.curToken.Literal, Target: left}
+	p.nextToken()
+	expr.Value = p.parseExpression(ASSIGN - 1)
+
+	switch left.(type) {
+	case *ast.Identifier, *ast.IndexExpression:
+		return expr
+	case *ast.BadExpression:
+		// The target did not parse, which has already been reported.
+	default:
+		p.errorf(expr.Token, `cannot assign to %s`, left)
+	}
+	return p.badExpression(left.Pos())
+}
+
 func (p *Parser) parseGroupedExpression() ast.Expression {
+	from := p.curToken.Pos
 	p.nextToken()
 	expr := p.parseExpression(LOWEST)
 	if !p.expectPeek(token.RPAREN) {
-		return nil
+		return p.badExpression(from)
 	}
 	return expr
 }
@@ -186,17 +322,17 @@ func (p *Parser) parseIfExpression() ast.Expression {
 	expr := &ast.IfExpression{Token: p.curToken}
 
 	if !p.expectPeek(token.LPAREN) {
-		return nil
+		return p.badExpression(expr.Token.Pos)
 	}
 	p.nextToken()
 
 	expr.Condition = p.parseExpression(LOWEST)
 
 	if !p.expectPeek(token.RPAREN) {
-		return nil
+		return p.badExpression(expr.Token.Pos)
 	}
 	if !p.expectPeek(token.LBRACE) {
-		return nil
+		return p.badExpression(expr.Token.Pos)
 	}
 
 	expr.Consequence = p.parseBlockStatement()
@@ -204,7 +340,7 @@ func (p *Parser) parseIfExpression() ast.Expression {
 	if p.peekTokenIs(token.ELSE) {
 		p.nextToken()
 		if !p.expectPeek(token.LBRACE) {
-			return nil
+			return p.badExpression(expr.Token.Pos)
 		}
 		expr.Alternative = p.parseBlockStatement()
 	}
@@ -212,20 +348,141 @@ func (p *Parser) parseIfExpression() ast.Expression {
 	return expr
 }
 
+func (p *Parser) parseWhileExpression() ast.Expression {
+	expr := &ast.WhileExpression{Token: p.curToken}
+
+	if !p.expectPeek(token.LPAREN) {
+		return p.badExpression(expr.Token.Pos)
+	}
+	p.nextToken()
+
+	expr.Condition = p.parseExpression(LOWEST)
+
+	if !p.expectPeek(token.RPAREN) {
+		return p.badExpression(expr.Token.Pos)
+	}
+	if !p.expectPeek(token.LBRACE) {
+		return p.badExpression(expr.Token.Pos)
+	}
+
+	expr.Body = p.parseLoopBody()
+	return expr
+}
+
+func (p *Parser) parseForExpression() ast.Expression {
+	expr := &ast.ForExpression{Token: p.curToken}
+
+	if !p.expectPeek(token.LPAREN) {
+		return p.badExpression(expr.Token.Pos)
+	}
+	if !p.expectPeek(token.IDENT) {
+		return p.badExpression(expr.Token.Pos)
+	}
+	expr.Variable = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+	if !p.expectPeek(token.IN) {
+		return p.badExpression(expr.Token.Pos)
+	}
+	p.nextToken()
+
+	expr.Iterable = p.parseExpression(LOWEST)
+
+	if !p.expectPeek(token.RPAREN) {
+		return p.badExpression(expr.Token.Pos)
+	}
+	if !p.expectPeek(token.LBRACE) {
+		return p.badExpression(expr.Token.Pos)
+	}
+
+	expr.Body = p.parseLoopBody()
+	return expr
+}
+
+func (p *Parser) parseTryExpression() ast.Expression {
+	expr := &ast.TryExpression{Token: p.curToken}
+
+	if !p.expectPeek(token.LBRACE) {
+		return p.badExpression(expr.Token.Pos)
+	}
+	expr.Body = p.parseBlockStatement()
+
+	if p.peekTokenIs(token.CATCH) {
+		p.nextToken()
+		if !p.expectPeek(token.LPAREN) {
+			return p.badExpression(expr.Token.Pos)
+		}
+		if !p.expectPeek(token.IDENT) {
+			return p.badExpression(expr.Token.Pos)
+		}
+		expr.CatchVar = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+		if !p.expectPeek(token.RPAREN) {
+			return p.badExpression(expr.Token.Pos)
+		}
+		if !p.expectPeek(token.LBRACE) {
+			return p.badExpression(expr.Token.Pos)
+		}
+		expr.Catch = p.parseBlockStatement()
+	}
+	if p.peekTokenIs(token.FINALLY) {
+		p.nextToken()
+		if !p.expectPeek(token.LBRACE) {
+			return p.badExpression(expr.Token.Pos)
+		}
+		expr.Finally = p.parseBlockStatement()
+	}
+
+	if expr.Catch == nil && expr.Finally == nil {
+		msg := fmt.Sprintf(`expected catch or finally after try block, got %s`, p.peekToken.Type)
+		p.report(p.peekToken, []token.Type{token.CATCH, token.FINALLY}, msg)
+		return p.badExpression(expr.Token.Pos)
+	}
+	return expr
+}
+
+// parseLoopBody parses the block of a loop, in which break and continue are
+// allowed.
+func (p *Parser) parseLoopBody() *ast.BlockStatement {
+	p.loopDepth++
+	defer func() { p.loopDepth-- }()
+	return p.parseBlockStatement()
+}
+
 func (p *Parser) parseIdentifier() ast.Expression {
 	return &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
 }
 
 func (p *Parser) parseIntLiteral() ast.Expression {
 	val, err := strconv.ParseInt(p.curToken.Literal, 0, 64)
+	if errors.Is(err, strconv.ErrRange) {
+		if val, ok := new(big.Int).SetString(p.curToken.Literal, 0); ok {
+			return &ast.BigIntegerLiteral{Token: p.curToken, Value: val}
+		}
+	}
 	if err != nil {
-		msg := fmt.Sprintf(`could not parse %q as integer`, p.curToken.Literal)
-		p.errors = append(p.errors, msg)
-		return nil
+		p.errorf(p.curToken, `could not parse %q as integer`, p.curToken.Literal)
+		return p.badExpression(p.curToken.Pos)
 	}
 	return &ast.IntegerLiteral{Token: p.curToken, Value: val}
 }
 
+func (p *Parser) parseFloatLiteral() ast.Expression {
+	val, err := strconv.ParseFloat(p.curToken.Literal, 64)
+	if err != nil {
+		p.errorf(p.curToken, `could not parse %q as float`, p.curToken.Literal)
+		return p.badExpression(p.curToken.Pos)
+	}
+	return &ast.FloatLiteral{Token: p.curToken, Value: val}
+}
+
+func (p *Parser) parseStringLiteral() ast.Expression {
+	return &ast.StringLiteral{Token: p.curToken, Value: p.curToken.Literal}
+}
+
+// parseIllegal skips over an ILLEGAL token. The lexer has already reported
+// why the token is illegal, so there is no need for another error here.
+func (p *Parser) parseIllegal() ast.Expression {
+	return p.badExpression(p.curToken.Pos)
+}
+
 func (p *Parser) parseBoolLiteral() ast.Expression {
 	return &ast.BooleanLiteral{Token: p.curToken, Value: p.curTokenIs(token.TRUE)}
 }
@@ -233,52 +490,136 @@ func (p *Parser) parseBoolLiteral() ast.Expression {
 func (p *Parser) parseFunctionLiteral() ast.Expression {
 	fn := &ast.FunctionLiteral{Token: p.curToken}
 
+	// Loops around the function do not enclose its body.
+	loopDepth := p.loopDepth
+	p.loopDepth = 0
+	defer func() { p.loopDepth = loopDepth }()
+
 	if !p.expectPeek(token.LPAREN) {
-		return nil
+		return p.badExpression(fn.Token.Pos)
+	}
+	if !p.parseFunctionArguments(fn) {
+		return p.badExpression(fn.Token.Pos)
 	}
-	fn.Args = p.parseFunctionArguments()
 
 	if !p.expectPeek(token.LBRACE) {
-		return nil
+		return p.badExpression(fn.Token.Pos)
 	}
 	fn.Body = p.parseBlockStatement()
 
 	return fn
 }
 
-func (p *Parser) parseFunctionArguments() []*ast.Identifier {
+// parseFunctionArguments parses a parameter list such as (a, b = 10, ...rest)
+// into fn. Parameters with defaults must follow those without, and a rest
+// parameter may only come last.
+func (p *Parser) parseFunctionArguments(fn *ast.FunctionLiteral) bool {
+	fn.Args = []*ast.Identifier{}
+	fn.Defaults = []ast.Expression{}
 	if p.peekTokenIs(token.RPAREN) {
 		p.nextToken()
-		return []*ast.Identifier{}
+		return true
 	}
 
-	idents := []*ast.Identifier{}
 	for {
+		if p.peekTokenIs(token.ELLIPSIS) {
+			p.nextToken()
+			if !p.expectPeek(token.IDENT) {
+				return false
+			}
+			fn.Rest = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+			break
+		}
+
 		if !p.expectPeek(token.IDENT) {
-			return nil
+			return false
 		}
-		idents = append(idents, &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal})
+		ident := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+
+		var def ast.Expression
+		if p.peekTokenIs(token.ASSIGN) {
+			p.nextToken()
+			p.nextToken()
+			def = p.parseExpression(LOWEST)
+		} else if len(fn.Defaults) > 0 && fn.Defaults[len(fn.Defaults)-1] != nil {
+			p.errorf(ident.Token, `parameter %s without a default follows a parameter with a default`, ident.Value)
+		}
+		fn.Args = append(fn.Args, ident)
+		fn.Defaults = append(fn.Defaults, def)
+
 		if !p.peekTokenIs(token.COMMA) {
 			break
 		}
 		p.nextToken()
 	}
 
-	if !p.expectPeek(token.RPAREN) {
-		return nil
-	}
-
-	return idents
+	return p.expectPeek(token.RPAREN)
 }
 
 func (p *Parser) parseCallExpression(left ast.Expression) ast.Expression {
 	call := &ast.CallExpression{Token: p.curToken, Function: left}
-	call.Args = p.parseCallArguments()
+	call.Args = p.parseExpressionList(token.RPAREN)
+	if call.Args == nil {
+		return p.badExpression(left.Pos())
+	}
+	call.Rparen = p.curToken
 	return call
 }
 
-func (p *Parser) parseCallArguments() []ast.Expression {
-	if p.peekTokenIs(token.RPAREN) {
+func (p *Parser) parseArrayLiteral() ast.Expression {
+	arr := &ast.ArrayLiteral{Token: p.curToken}
+	arr.Elements = p.parseExpressionList(token.RBRACKET)
+	if arr.Elements == nil {
+		return p.badExpression(arr.Token.Pos)
+	}
+	arr.Rbracket = p.curToken
+	return arr
+}
+
+// parseHashLiteral parses a { in expression position. Blocks are only parsed
+// where the grammar requires one (after if, else and fn), so a { reached
+// through parseExpression is always a hash literal.
+func (p *Parser) parseHashLiteral() ast.Expression {
+	hash := &ast.HashLiteral{Token: p.curToken, Pairs: []ast.HashPair{}}
+
+	for !p.peekTokenIs(token.RBRACE) {
+		p.nextToken()
+		key := p.parseExpression(LOWEST)
+		if !p.expectPeek(token.COLON) {
+			return p.badExpression(hash.Token.Pos)
+		}
+		p.nextToken()
+		value := p.parseExpression(LOWEST)
+		hash.Pairs = append(hash.Pairs, ast.HashPair{Key: key, Value: value})
+
+		if p.peekTokenIs(token.COMMA) {
+			p.nextToken()
+		} else if !p.peekTokenIs(token.RBRACE) {
+			p.peekError(token.COMMA, token.RBRACE)
+			return p.badExpression(hash.Token.Pos)
+		}
+	}
+	p.nextToken()
+	hash.Rbrace = p.curToken
+
+	return hash
+}
+
+func (p *Parser) parseIndexExpression(left ast.Expression) ast.Expression {
+	expr := &ast.IndexExpression{Token: p.curToken, Left: left}
+	p.nextToken()
+	expr.Index = p.parseExpression(LOWEST)
+	if !p.expectPeek(token.RBRACKET) {
+		return p.badExpression(left.Pos())
+	}
+	expr.Rbracket = p.curToken
+	return expr
+}
+
+// parseExpressionList parses comma-separated expressions up to and including
+// the end token. It returns nil if the list could not be parsed.
+func (p *Parser) parseExpressionList(end token.Type) []ast.Expression {
+	if p.peekTokenIs(end) {
 		p.nextToken()
 		return []ast.Expression{}
 	}
@@ -291,8 +632,10 @@ func (p *Parser) parseCallArguments() []ast.Expression {
 		exprs = append(exprs, p.parseExpression(LOWEST))
 	}
 
-	if !p.expectPeek(token.RPAREN) {
+	if !p.peekTokenIs(end) {
+		p.peekError(token.COMMA, end)
 		return nil
 	}
+	p.nextToken()
 	return exprs
 }
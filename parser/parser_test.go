@@ -7,7 +7,9 @@ import (
 
 	"github.com/cszczepaniak/monkey/ast"
 	"github.com/cszczepaniak/monkey/lexer"
+	"github.com/cszczepaniak/monkey/token"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestLetStatements(t *testing.T) {
@@ -15,18 +17,27 @@ func TestLetStatements(t *testing.T) {
 		input    string
 		expIdent string
 		expValue interface{}
+		expConst bool
 	}{{
 		`let x = 5;`,
 		`x`,
 		5,
+		false,
+	}, {
+		`const x = 5;`,
+		`x`,
+		5,
+		true,
 	}, {
 		`let y = true;`,
 		`y`,
 		true,
+		false,
 	}, {
 		`let foobar = y;`,
 		`foobar`,
 		`y`,
+		false,
 	}}
 
 	for _, tc := range tests {
@@ -35,9 +46,52 @@ func TestLetStatements(t *testing.T) {
 		letStmt := program.Statements[0].(*ast.LetStatement)
 		assertIdentifier(t, letStmt.Name, tc.expIdent)
 		assertLiteralExpression(t, letStmt.Value, tc.expValue)
+		assert.Equal(t, tc.expConst, letStmt.IsConst())
 	}
 }
 
+func TestDocComments(t *testing.T) {
+	input := `
+// double returns twice x.
+//
+// It works on integers.
+let double = fn(x) { x * 2 };
+
+/* not a doc comment */
+
+let a = 1; // trailing
+let b = 2;
+/*
+ * A block comment.
+ *
+ * With two paragraphs.
+ */
+let c = /* inside */ 3;
+/** Opened with
+ * two stars. */
+let d = 4;`
+
+	program := assertProgram(t, input, 5)
+
+	tests := []string{
+		"double returns twice x.\n\nIt works on integers.",
+		``,
+		``,
+		"A block comment.\n\nWith two paragraphs.",
+		"Opened with\ntwo stars.",
+	}
+	for i, exp := range tests {
+		stmt := program.Statements[i].(*ast.LetStatement)
+		assert.Equal(t, exp, stmt.Doc.Text(), stmt.Name.Value)
+	}
+	assert.Equal(t, `3`, program.Statements[3].(*ast.LetStatement).Value.String())
+
+	// The parser reads comments without changing the lexer's mode.
+	l := lexer.New(input)
+	New(l).ParseProgram()
+	assert.Equal(t, lexer.Mode(0), l.Mode())
+}
+
 func TestReturnStatements(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -78,6 +132,45 @@ func TestIntLiteralExpression(t *testing.T) {
 	assertIntegerLiteral(t, stmt.Expression, 5)
 }
 
+func TestNumberLiteralExpressions(t *testing.T) {
+	program := assertProgram(t, `0xff; 0b1_010; 1_000; 2.5; 1e-3;`, 5)
+	for i, exp := range []int64{255, 10, 1000} {
+		expr := program.Statements[i].(*ast.ExpressionStatement).Expression
+		assert.IsType(t, &ast.IntegerLiteral{}, expr)
+		assert.Equal(t, exp, expr.(*ast.IntegerLiteral).Value)
+	}
+	for i, exp := range []float64{2.5, 0.001} {
+		expr := program.Statements[3+i].(*ast.ExpressionStatement).Expression
+		assert.IsType(t, &ast.FloatLiteral{}, expr)
+		assert.Equal(t, exp, expr.(*ast.FloatLiteral).Value)
+	}
+
+	program = assertProgram(t, `0x1_0000_0000_0000_0000;`, 1)
+	expr := program.Statements[0].(*ast.ExpressionStatement).Expression
+	require.IsType(t, &ast.BigIntegerLiteral{}, expr)
+	assert.Equal(t, `18446744073709551616`, expr.(*ast.BigIntegerLiteral).Value.String())
+
+	p := New(lexer.New(`1e400`))
+	p.ParseProgram()
+	assert.Equal(t, []string{`1:1: could not parse "1e400" as float`}, p.Errors())
+}
+
+func TestStringLiteralExpression(t *testing.T) {
+	input := `"hello\tworld\u{7f}";`
+	program := assertProgram(t, input, 1, &ast.ExpressionStatement{})
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	assert.IsType(t, &ast.StringLiteral{}, stmt.Expression)
+	str := stmt.Expression.(*ast.StringLiteral)
+	assert.Equal(t, "hello\tworld\x7f", str.Value)
+	assert.Equal(t, `"hello\tworld\u{7f}"`, str.String())
+}
+
+func TestIllegalTokens(t *testing.T) {
+	p := New(lexer.New(`let x = "abc`))
+	p.ParseProgram()
+	assert.Equal(t, []string{`1:13: unterminated string literal`}, p.Errors())
+}
+
 func TestBoolLiteralExpression(t *testing.T) {
 	tests := []struct {
 		input  string
@@ -105,6 +198,7 @@ func TestPrefixExpressions(t *testing.T) {
 	}{
 		{`!5;`, `!`, 5},
 		{`-15;`, `-`, 15},
+		{`~15;`, `~`, 15},
 		{`!true;`, `!`, true},
 		{`!false;`, `!`, false},
 	}
@@ -130,10 +224,20 @@ func TestInfixExpressions(t *testing.T) {
 		{`5 - 5`, 5, `-`, 5},
 		{`5 * 5`, 5, `*`, 5},
 		{`5 / 5`, 5, `/`, 5},
+		{`5 % 5`, 5, `%`, 5},
 		{`5 > 5`, 5, `>`, 5},
 		{`5 < 5`, 5, `<`, 5},
 		{`5 == 5`, 5, `==`, 5},
 		{`5 != 5`, 5, `!=`, 5},
+		{`5 <= 5`, 5, `<=`, 5},
+		{`5 >= 5`, 5, `>=`, 5},
+		{`true && false`, true, `&&`, false},
+		{`true || false`, true, `||`, false},
+		{`5 & 5`, 5, `&`, 5},
+		{`5 | 5`, 5, `|`, 5},
+		{`5 ^ 5`, 5, `^`, 5},
+		{`5 << 5`, 5, `<<`, 5},
+		{`5 >> 5`, 5, `>>`, 5},
 		{`true == true`, true, `==`, true},
 		{`false != true`, false, `!=`, true},
 		{`false == false`, false, `==`, false},
@@ -184,6 +288,96 @@ func TestIfElseExpression(t *testing.T) {
 	assertIdentifier(t, alternative.Expression, `y`)
 }
 
+func TestWhileExpression(t *testing.T) {
+	program := assertProgram(t, `while (x < y) { x; break; continue }`, 1, &ast.ExpressionStatement{})
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	assert.IsType(t, &ast.WhileExpression{}, stmt.Expression)
+	expr := stmt.Expression.(*ast.WhileExpression)
+
+	assertInfixExpression(t, expr.Condition, `x`, `<`, `y`)
+	require.Len(t, expr.Body.Statements, 3)
+	assert.IsType(t, &ast.BranchStatement{}, expr.Body.Statements[1])
+	assert.Equal(t, `break;`, expr.Body.Statements[1].String())
+	assert.Equal(t, `continue;`, expr.Body.Statements[2].String())
+}
+
+func TestForExpression(t *testing.T) {
+	program := assertProgram(t, `for (x in range(10)) { if (x > 5) { break } }`, 1, &ast.ExpressionStatement{})
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	assert.IsType(t, &ast.ForExpression{}, stmt.Expression)
+	expr := stmt.Expression.(*ast.ForExpression)
+
+	assertIdentifier(t, expr.Variable, `x`)
+	assert.Equal(t, `range(10)`, expr.Iterable.String())
+	assert.Len(t, expr.Body.Statements, 1)
+	assert.Equal(t, `for (x in range(10)) { if(x > 5) { break;; }; }`, expr.String())
+}
+
+func TestBranchStatementErrors(t *testing.T) {
+	tests := []struct {
+		input  string
+		expErr string
+	}{
+		{`break`, `1:1: break outside loop`},
+		{`if (true) { continue; }`, `1:13: continue outside loop`},
+		{`while (true) { fn() { break } }`, `1:23: break outside loop`},
+		{`for (x in xs) { fn(a = if (x) { continue }) {} }`, `1:33: continue outside loop`},
+	}
+
+	for _, tc := range tests {
+		p := New(lexer.New(tc.input))
+		p.ParseProgram()
+		assert.Equal(t, []string{tc.expErr}, p.Errors(), tc.input)
+	}
+}
+
+func TestTryExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`try { f() } catch (e) { e }`, `try { f(); } catch (e) { e; }`},
+		{`try { f() } finally { g() }`, `try { f(); } finally { g(); }`},
+		{`try { throw 1 } catch (e) { throw e } finally { g() }`, `try { throw 1;; } catch (e) { throw e;; } finally { g(); }`},
+	}
+
+	for _, tc := range tests {
+		program := assertProgram(t, tc.input, 1, &ast.ExpressionStatement{})
+		expr := program.Statements[0].(*ast.ExpressionStatement).Expression
+		require.IsType(t, &ast.TryExpression{}, expr, tc.input)
+		assert.Equal(t, tc.expected, expr.String(), tc.input)
+	}
+
+	program := assertProgram(t, `try { 1 } catch (err) { 2 }`, 1, &ast.ExpressionStatement{})
+	expr := program.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.TryExpression)
+	assertIdentifier(t, expr.CatchVar, `err`)
+	assert.Nil(t, expr.Finally)
+}
+
+func TestThrowStatement(t *testing.T) {
+	program := assertProgram(t, `throw x + y;`, 1, &ast.ThrowStatement{})
+	stmt := program.Statements[0].(*ast.ThrowStatement)
+	assertInfixExpression(t, stmt.Value, `x`, `+`, `y`)
+}
+
+func TestTryExpressionErrors(t *testing.T) {
+	tests := []struct {
+		input  string
+		expErr string
+	}{
+		{`try { 1 }`, `1:10: expected catch or finally after try block, got EOF`},
+		{`try { 1 } catch { 2 }`, `1:17: Expected next token to be (, got { instead`},
+		{`try { 1 } catch () { 2 }`, `1:18: Expected next token to be IDENT, got ) instead`},
+	}
+
+	for _, tc := range tests {
+		p := New(lexer.New(tc.input))
+		p.ParseProgram()
+		require.NotEmpty(t, p.Errors(), tc.input)
+		assert.Equal(t, tc.expErr, p.Errors()[0], tc.input)
+	}
+}
+
 func TestFuncLiteral(t *testing.T) {
 	tests := []struct {
 		input                string
@@ -222,6 +416,87 @@ func TestFuncLiteral(t *testing.T) {
 	}
 }
 
+func TestFuncLiteralParams(t *testing.T) {
+	tests := []struct {
+		input       string
+		expArgs     []string
+		expDefaults []string
+		expRest     string
+		expString   string
+	}{{
+		`fn(x, y = 10) { x }`,
+		[]string{`x`, `y`},
+		[]string{``, `10`},
+		``,
+		`fn(x, y = 10) { x; }`,
+	}, {
+		`fn(first, ...rest) { rest }`,
+		[]string{`first`},
+		[]string{``},
+		`rest`,
+		`fn(first, ...rest) { rest; }`,
+	}, {
+		`fn(...args) { args }`,
+		[]string{},
+		[]string{},
+		`args`,
+		`fn(...args) { args; }`,
+	}, {
+		`fn(a, b = a * 2, c = [], ...more) { a }`,
+		[]string{`a`, `b`, `c`},
+		[]string{``, `(a * 2)`, `[]`},
+		`more`,
+		`fn(a, b = (a * 2), c = [], ...more) { a; }`,
+	}}
+
+	for _, tc := range tests {
+		program := assertProgram(t, tc.input, 1, &ast.ExpressionStatement{})
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		assert.IsType(t, &ast.FunctionLiteral{}, stmt.Expression)
+		fn := stmt.Expression.(*ast.FunctionLiteral)
+
+		assert.Len(t, fn.Args, len(tc.expArgs))
+		assert.Len(t, fn.Defaults, len(tc.expDefaults))
+		for i, exp := range tc.expArgs {
+			assertIdentifier(t, fn.Args[i], exp)
+			if tc.expDefaults[i] == `` {
+				assert.Nil(t, fn.Defaults[i])
+			} else {
+				assert.Equal(t, tc.expDefaults[i], fn.Defaults[i].String())
+			}
+		}
+		if tc.expRest == `` {
+			assert.Nil(t, fn.Rest)
+		} else {
+			assertIdentifier(t, fn.Rest, tc.expRest)
+		}
+		assert.Equal(t, tc.expString, fn.String())
+	}
+}
+
+func TestFuncLiteralParamErrors(t *testing.T) {
+	tests := []struct {
+		input  string
+		expErr string
+	}{{
+		`fn(x = 1, y) { x }`,
+		`1:11: parameter y without a default follows a parameter with a default`,
+	}, {
+		`fn(...rest, x) { x }`,
+		`1:11: Expected next token to be ), got , instead`,
+	}, {
+		`fn(...) { x }`,
+		`1:7: Expected next token to be IDENT, got ) instead`,
+	}}
+
+	for _, tc := range tests {
+		p := New(lexer.New(tc.input))
+		p.ParseProgram()
+		assert.NotEmpty(t, p.Errors())
+		assert.Equal(t, tc.expErr, p.Errors()[0])
+	}
+}
+
 func TestCallExpression(t *testing.T) {
 	tests := []struct {
 		input       string
@@ -262,6 +537,83 @@ func TestCallExpression(t *testing.T) {
 	}
 }
 
+func TestArrayLiteral(t *testing.T) {
+	input := `[1, 2 * 2, 3 + 3]`
+	program := assertProgram(t, input, 1, &ast.ExpressionStatement{})
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	assert.IsType(t, &ast.ArrayLiteral{}, stmt.Expression)
+	arr := stmt.Expression.(*ast.ArrayLiteral)
+	assert.Len(t, arr.Elements, 3)
+	assertIntegerLiteral(t, arr.Elements[0], 1)
+	assertInfixExpression(t, arr.Elements[1], 2, `*`, 2)
+	assertInfixExpression(t, arr.Elements[2], 3, `+`, 3)
+	assert.Equal(t, 17, arr.End().Offset)
+}
+
+func TestEmptyArrayLiteral(t *testing.T) {
+	program := assertProgram(t, `[]`, 1, &ast.ExpressionStatement{})
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	assert.IsType(t, &ast.ArrayLiteral{}, stmt.Expression)
+	assert.Empty(t, stmt.Expression.(*ast.ArrayLiteral).Elements)
+}
+
+func TestIndexExpression(t *testing.T) {
+	input := `myArray[1 + 1]`
+	program := assertProgram(t, input, 1, &ast.ExpressionStatement{})
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	assert.IsType(t, &ast.IndexExpression{}, stmt.Expression)
+	idx := stmt.Expression.(*ast.IndexExpression)
+	assertIdentifier(t, idx.Left, `myArray`)
+	assertInfixExpression(t, idx.Index, 1, `+`, 1)
+}
+
+func TestHashLiterals(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{{
+		`{"one": 1, "two": 2, "three": 3}`,
+		`{"one": 1, "two": 2, "three": 3}`,
+	}, {
+		`{}`,
+		`{}`,
+	}, {
+		`{"one": 0 + 1, true: 10 - 8, 3: 15 / 5}`,
+		`{"one": (0 + 1), true: (10 - 8), 3: (15 / 5)}`,
+	}, {
+		`{"a": {"b": [1]}}["a"]`,
+		`({"a": {"b": [1]}}["a"])`,
+	}, {
+		`if (x) { {"a": 1} }`,
+		`ifx { {"a": 1}; }`,
+	}}
+
+	for _, tc := range tests {
+		program := assertProgram(t, tc.input, 1, &ast.ExpressionStatement{})
+		assert.Equal(t, tc.expected, program.String())
+	}
+}
+
+func TestHashLiteralErrors(t *testing.T) {
+	tests := []struct {
+		input  string
+		expErr string
+	}{{
+		`{"a" 1}`,
+		`1:6: Expected next token to be :, got INT instead`,
+	}, {
+		`{"a": 1 "b": 2}`,
+		`1:9: Expected next token to be , or }, got STRING instead`,
+	}}
+
+	for _, tc := range tests {
+		p := New(lexer.New(tc.input))
+		p.ParseProgram()
+		assert.NotEmpty(t, p.Errors())
+		assert.Equal(t, tc.expErr, p.Errors()[0])
+	}
+}
+
 func TestOperatorPrecedenceParsing(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -338,6 +690,48 @@ func TestOperatorPrecedenceParsing(t *testing.T) {
 	}, {
 		`3 + 4 * 5 == 3 * 1 + 4 * 5`,
 		`((3 + (4 * 5)) == ((3 * 1) + (4 * 5)))`,
+	}, {
+		`a * [1, 2, 3, 4][b * c] * d`,
+		`((a * ([1, 2, 3, 4][(b * c)])) * d)`,
+	}, {
+		`add(a * b[2], b[1], 2 * [1, 2][1])`,
+		`add((a * (b[2])), (b[1]), (2 * ([1, 2][1])))`,
+	}, {
+		`a + b % c * d`,
+		`(a + ((b % c) * d))`,
+	}, {
+		`fns[0](x)`,
+		`(fns[0])(x)`,
+	}, {
+		`a || b && c || d`,
+		`((a || (b && c)) || d)`,
+	}, {
+		`a == b && c != d`,
+		`((a == b) && (c != d))`,
+	}, {
+		`a <= b == c >= d`,
+		`((a <= b) == (c >= d))`,
+	}, {
+		`a + b | c * d & e`,
+		`((a + b) | ((c * d) & e))`,
+	}, {
+		`a ^ b << c >> d < e`,
+		`((a ^ ((b << c) >> d)) < e)`,
+	}, {
+		`~a & -b`,
+		`((~a) & (-b))`,
+	}, {
+		`!a || ~b[0]`,
+		`((!a) || (~(b[0])))`,
+	}, {
+		`a = b = c || d`,
+		`(a = (b = (c || d)))`,
+	}, {
+		`a[i + 1] += b * 2`,
+		`((a[(i + 1)]) += (b * 2))`,
+	}, {
+		`x -= y /= 2`,
+		`(x -= (y /= 2))`,
 	}}
 	for _, tc := range tests {
 		program := assertProgram(t, tc.input, 1)
@@ -345,6 +739,188 @@ func TestOperatorPrecedenceParsing(t *testing.T) {
 	}
 }
 
+func TestAssignExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expOp    string
+		expValue interface{}
+	}{
+		{`x = 5`, `=`, 5},
+		{`x += y`, `+=`, `y`},
+		{`x -= true`, `-=`, true},
+		{`x *= 2`, `*=`, 2},
+		{`x /= 2`, `/=`, 2},
+	}
+
+	for _, tc := range tests {
+		program := assertProgram(t, tc.input, 1)
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		assert.IsType(t, &ast.AssignExpression{}, stmt.Expression)
+		assign := stmt.Expression.(*ast.AssignExpression)
+		assertIdentifier(t, assign.Target, `x`)
+		assert.Equal(t, tc.expOp, assign.Operator)
+		assertLiteralExpression(t, assign.Value, tc.expValue)
+	}
+
+	program := assertProgram(t, `h["a"] = 1`, 1)
+	assign := program.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.AssignExpression)
+	assert.IsType(t, &ast.IndexExpression{}, assign.Target)
+}
+
+func TestErrorPositions(t *testing.T) {
+	tests := []struct {
+		input  string
+		expErr string
+	}{{
+		`let x 5;`,
+		`1:7: Expected next token to be =, got INT instead`,
+	}, {
+		"add(1,\n  2",
+		`2:4: Expected next token to be , or ), got EOF instead`,
+	}, {
+		"let y = 1;\n;",
+		`2:1: no prefix parse function for ; found`,
+	}, {
+		`1 = 2`,
+		`1:3: cannot assign to 1`,
+	}, {
+		`f() += 1`,
+		`1:5: cannot assign to f()`,
+	}}
+
+	for _, tc := range tests {
+		p := New(lexer.New(tc.input))
+		p.ParseProgram()
+		assert.NotEmpty(t, p.Errors())
+		assert.Equal(t, tc.expErr, p.Errors()[0])
+	}
+}
+
+func TestErrorRecovery(t *testing.T) {
+	tests := []struct {
+		input     string
+		expErrs   []string
+		expString string
+	}{{
+		`let x = (1 + 2; let y = 3;`,
+		[]string{`1:15: Expected next token to be ), got ; instead`},
+		`<bad statement>let y = 3;`,
+	}, {
+		`let let x = 1; let y 5; x +`,
+		[]string{
+			`1:5: Expected next token to be IDENT, got LET instead`,
+			`1:22: Expected next token to be =, got INT instead`,
+			`1:28: no prefix parse function for EOF found`,
+		},
+		`<bad statement>let x = 1;<bad statement><bad statement>`,
+	}, {
+		`let h = {"a" 1, "b": 2}; h`,
+		[]string{`1:14: Expected next token to be :, got INT instead`},
+		`<bad statement>h`,
+	}, {
+		`if (x) { let = 1 } let y = 2;`,
+		[]string{`1:14: Expected next token to be IDENT, got = instead`},
+		`ifx { <bad statement>; }let y = 2;`,
+	}, {
+		`fn() { if (x) { return } }; f(1 = 2, 3)`,
+		[]string{
+			`1:24: no prefix parse function for } found`,
+			`1:33: cannot assign to 1`,
+		},
+		`fn() { ifx { <bad statement>; }; }f(<bad expression>, 3)`,
+	}, {
+		"break\nlet x = 1 @ 2;",
+		[]string{
+			`1:1: break outside loop`,
+			`2:11: illegal character '@'`,
+		},
+		`<bad statement>let x = 1;<bad expression>2`,
+	}}
+
+	for _, tc := range tests {
+		p := New(lexer.New(tc.input))
+		program := p.ParseProgram()
+		assert.Equal(t, tc.expErrs, p.Errors(), tc.input)
+		assert.Equal(t, tc.expString, program.String(), tc.input)
+	}
+}
+
+func TestBadNodes(t *testing.T) {
+	p := New(lexer.New(`let x = 1 = 2; let y = (3; z`))
+	program := p.ParseProgram()
+	require.Len(t, program.Statements, 3)
+
+	let := program.Statements[0].(*ast.LetStatement)
+	require.IsType(t, &ast.BadExpression{}, let.Value)
+	assert.Equal(t, `1:9`, let.Value.Pos().String())
+	assert.Equal(t, `1:14`, let.Value.End().String())
+
+	require.IsType(t, &ast.BadStatement{}, program.Statements[1])
+	assert.Equal(t, `1:16`, program.Statements[1].Pos().String())
+	assert.Equal(t, `1:27`, program.Statements[1].End().String())
+	assertIdentifier(t, program.Statements[2].(*ast.ExpressionStatement).Expression, `z`)
+}
+
+func TestErrorList(t *testing.T) {
+	p := New(lexer.New("let = 1;\nlet x = \"a"))
+	p.ParseProgram()
+	errs := p.ErrorList()
+	require.Len(t, errs, 2)
+
+	assert.Equal(t, `1:5: Expected next token to be IDENT, got = instead`, errs[0].Error())
+	assert.Equal(t, `2:11: unterminated string literal`, errs[1].Error())
+	assert.Empty(t, errs[1].Expected)
+	assert.Empty(t, errs[1].Found.Type)
+
+	p = New(lexer.New(`try { 1 } f(`))
+	p.ParseProgram()
+	errs = p.ErrorList()
+	require.Len(t, errs, 2)
+
+	assert.Equal(t, `expected catch or finally after try block, got IDENT`, errs[0].Msg)
+	assert.Equal(t, []token.Type{token.CATCH, token.FINALLY}, errs[0].Expected)
+	assert.Equal(t, `f`, errs[0].Found.Literal)
+	assert.Equal(t, `1:11`, errs[0].Pos.String())
+
+	assert.Equal(t, `1:13`, errs[1].Pos.String())
+	assert.Equal(t, token.Type(token.EOF), errs[1].Found.Type)
+	assert.Contains(t, errs[1].Expected, token.Type(token.IDENT))
+	assert.NotContains(t, errs[1].Expected, token.Type(token.ILLEGAL))
+}
+
+func TestNodePositions(t *testing.T) {
+	tests := []struct {
+		input  string
+		expPos string
+		expEnd string
+	}{{
+		`let x = 5 * y;`,
+		`main.mk:1:1`,
+		`main.mk:1:14`,
+	}, {
+		`  add(1, 2)`,
+		`main.mk:1:3`,
+		`main.mk:1:12`,
+	}, {
+		"if (x) {\n  1\n} else {\n  2\n}",
+		`main.mk:1:1`,
+		`main.mk:5:2`,
+	}, {
+		`-a + fn(x) { x }`,
+		`main.mk:1:1`,
+		`main.mk:1:17`,
+	}}
+
+	for _, tc := range tests {
+		p := New(lexer.NewFile(`main.mk`, tc.input))
+		program := p.ParseProgram()
+		checkErrors(t, p)
+		assert.Len(t, program.Statements, 1)
+		assert.Equal(t, tc.expPos, program.Statements[0].Pos().String())
+		assert.Equal(t, tc.expEnd, program.Statements[0].End().String())
+	}
+}
+
 func assertProgram(t *testing.T, input string, expNumStatements int, expStatementTypes ...interface{}) *ast.Program {
 	l := lexer.New(input)
 	p := New(l)
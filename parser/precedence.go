@@ -2,26 +2,49 @@ package parser
 
 import "github.com/cszczepaniak/monkey/token"
 
+// Operator precedences, from loosest to tightest binding. As in Go, the
+// bitwise operators bind like the arithmetic ones: | and ^ like +, and &, <<
+// and >> like *. Assignment binds loosest of all, and to the right.
 const (
 	LOWEST int = iota
+	ASSIGN
+	LOGICALOR
+	LOGICALAND
 	EQUALS
 	LESSGREATER
 	SUM
 	PRODUCT
 	PREFIX
 	CALL
+	INDEX
 )
 
 var precedences = map[token.Type]int{
-	token.EQ:       EQUALS,
-	token.NEQ:      EQUALS,
-	token.LT:       LESSGREATER,
-	token.GT:       LESSGREATER,
-	token.PLUS:     SUM,
-	token.MINUS:    SUM,
-	token.ASTERISK: PRODUCT,
-	token.SLASH:    PRODUCT,
-	token.LPAREN:   CALL,
+	token.ASSIGN:          ASSIGN,
+	token.PLUS_ASSIGN:     ASSIGN,
+	token.MINUS_ASSIGN:    ASSIGN,
+	token.ASTERISK_ASSIGN: ASSIGN,
+	token.SLASH_ASSIGN:    ASSIGN,
+	token.OR:              LOGICALOR,
+	token.AND:             LOGICALAND,
+	token.EQ:              EQUALS,
+	token.NEQ:             EQUALS,
+	token.LT:              LESSGREATER,
+	token.GT:              LESSGREATER,
+	token.LTE:             LESSGREATER,
+	token.GTE:             LESSGREATER,
+	token.PLUS:            SUM,
+	token.MINUS:           SUM,
+	token.PIPE:            SUM,
+	token.CARET:           SUM,
+	token.ASTERISK:        PRODUCT,
+	token.SLASH:           PRODUCT,
+	token.PERCENT:         PRODUCT,
+	token.AMPERSAND:       PRODUCT,
+	token.LSHIFT:          PRODUCT,
+	token.RSHIFT:          PRODUCT,
+	token.LPAREN:          CALL,
+	token.LBRACKET:        INDEX,
 }
 
 func (p *Parser) peekPrecedence() int {
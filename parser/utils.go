@@ -2,7 +2,11 @@ package parser
 
 import (
 	"fmt"
+	"sort"
+	"strings"
 
+	"github.com/cszczepaniak/monkey/ast"
+	"github.com/cszczepaniak/monkey/lexer"
 	"github.com/cszczepaniak/monkey/token"
 )
 
@@ -15,8 +19,46 @@ func (p *Parser) registerInfix(tokenType token.Type, fn infixParseFn) {
 }
 
 func (p *Parser) nextToken() {
-	p.curToken = p.peekToken
-	p.peekToken = p.l.NextToken()
+	p.depth = p.level()
+	p.curToken, p.curDoc = p.peekToken, p.peekDoc
+	p.peekToken, p.peekDoc = p.readToken()
+}
+
+// level returns the number of braces left open up to and including the
+// current token.
+func (p *Parser) level() int {
+	switch p.curToken.Type {
+	case token.LBRACE:
+		return p.depth + 1
+	case token.RBRACE:
+		return p.depth - 1
+	}
+	return p.depth
+}
+
+// readToken reads the next token that is not a comment. It also returns the
+// group of comments ending on the line above the token, unless the group
+// starts on the line of the token before it.
+func (p *Parser) readToken() (token.Token, *ast.CommentGroup) {
+	var group *ast.CommentGroup
+	for {
+		tok := p.l.NextTokenMode(p.l.Mode() | lexer.ScanComments)
+		if tok.Type != token.COMMENT {
+			if group != nil && group.End().Line != tok.Pos.Line-1 {
+				group = nil
+			}
+			return tok, group
+		}
+		c := &ast.Comment{Token: tok}
+		switch {
+		case group != nil && tok.Pos.Line <= group.End().Line+1:
+			group.List = append(group.List, c)
+		case tok.Pos.Line == p.curToken.End.Line && p.curToken.End.IsValid() && group == nil:
+			// A comment trailing a token belongs to that line.
+		default:
+			group = &ast.CommentGroup{List: []*ast.Comment{c}}
+		}
+	}
 }
 
 func (p *Parser) curTokenIs(t token.Type) bool {
@@ -36,12 +78,78 @@ func (p *Parser) expectPeek(t token.Type) bool {
 	return false
 }
 
-func (p *Parser) peekError(t token.Type) {
-	msg := fmt.Sprintf(`Expected next token to be %s, got %s instead`, t, p.peekToken.Type)
-	p.errors = append(p.errors, msg)
+// peekError reports that the next token is none of those expected. The
+// parser cannot make sense of the rest of the statement after this.
+func (p *Parser) peekError(expected ...token.Type) {
+	names := make([]string, len(expected))
+	for i, t := range expected {
+		names[i] = string(t)
+	}
+	msg := fmt.Sprintf(`Expected next token to be %s, got %s instead`, strings.Join(names, ` or `), p.peekToken.Type)
+	p.report(p.peekToken, expected, msg)
+	p.lost = true
+}
+
+// noPrefixParseFnError reports that the current token cannot start an
+// expression. The parser cannot make sense of the rest of the statement after
+// this.
+func (p *Parser) noPrefixParseFnError() {
+	var starts []token.Type
+	for t := range p.prefixParseFns {
+		if t != token.ILLEGAL {
+			starts = append(starts, t)
+		}
+	}
+	sort.Slice(starts, func(i, j int) bool { return starts[i] < starts[j] })
+
+	msg := fmt.Sprintf(`no prefix parse function for %s found`, p.curToken.Type)
+	p.report(p.curToken, starts, msg)
+	p.lost = true
+}
+
+// errorf reports an error at the token found, after which parsing carries on
+// as normal.
+func (p *Parser) errorf(found token.Token, format string, a ...interface{}) {
+	p.report(found, nil, fmt.Sprintf(format, a...))
+}
+
+// report records an error, unless the parser is skipping over a statement it
+// has already reported an error in.
+func (p *Parser) report(found token.Token, expected []token.Type, msg string) {
+	if p.lost {
+		return
+	}
+	p.errors = append(p.errors, &Error{Pos: found.Pos, Msg: msg, Expected: expected, Found: found})
+}
+
+// synchronize skips ahead, after an error, to the end of the statement
+// starting at brace depth depth: to its semicolon, or to just before a
+// closing brace or a keyword that starts the next statement.
+func (p *Parser) synchronize(depth int) {
+	for !p.peekTokenIs(token.EOF) {
+		level := p.level()
+		if level < depth {
+			break
+		}
+		if level == depth && (p.curTokenIs(token.SEMICOLON) || p.peekTokenIs(token.RBRACE) || startsStatement[p.peekToken.Type]) {
+			break
+		}
+		p.nextToken()
+	}
+	p.lost = false
 }
 
-func (p *Parser) noPrefixParseFnError(t token.Type) {
-	msg := fmt.Sprintf(`no prefix parse function for %s found`, t)
-	p.errors = append(p.errors, msg)
+// startsStatement holds the keywords which can only start a statement, or
+// usually do.
+var startsStatement = map[token.Type]bool{
+	token.LET:      true,
+	token.CONST:    true,
+	token.RETURN:   true,
+	token.THROW:    true,
+	token.BREAK:    true,
+	token.CONTINUE: true,
+	token.IF:       true,
+	token.WHILE:    true,
+	token.FOR:      true,
+	token.TRY:      true,
 }
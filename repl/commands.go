@@ -0,0 +1,163 @@
+package repl
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/cszczepaniak/monkey/ast"
+	"github.com/cszczepaniak/monkey/diagnostics"
+	"github.com/cszczepaniak/monkey/lexer"
+	"github.com/cszczepaniak/monkey/parser"
+	"github.com/cszczepaniak/monkey/token"
+)
+
+// Command is a REPL meta-command, run by entering its name after a colon,
+// followed by its arguments.
+type Command struct {
+	Name string
+	// Args describes the arguments for :help. If it is set, the command
+	// requires arguments.
+	Args string
+	Help string
+	Run  func(s *Session, args string) error
+}
+
+var commands = make(map[string]Command)
+
+// RegisterCommand makes c available in every session, replacing any command
+// with the same name.
+func RegisterCommand(c Command) {
+	commands[c.Name] = c
+}
+
+func init() {
+	for _, c := range []Command{
+		{Name: `help`, Help: `list the commands`, Run: helpCommand},
+		{Name: `paste`, Help: `enter several lines at once, ending with :end`, Run: pasteCommand},
+		{Name: `tokens`, Args: `<src>`, Help: `show the tokens src lexes to`, Run: tokensCommand},
+		{Name: `ast`, Args: `<src>`, Help: `show the syntax tree src parses to`, Run: astCommand},
+		{Name: `env`, Help: `list the bindings in the session`, Run: envCommand},
+		{Name: `type`, Args: `<expr>`, Help: `show the type of the value of expr`, Run: typeCommand},
+		{Name: `load`, Args: `<file>`, Help: `run a file in the session`, Run: loadCommand},
+		{Name: `reset`, Help: `discard all bindings`, Run: resetCommand},
+		{Name: `time`, Args: `<expr>`, Help: `run expr and report the time and memory it took`, Run: timeCommand},
+	} {
+		RegisterCommand(c)
+	}
+}
+
+// runCommand runs the command line, which starts with a colon.
+func (s *Session) runCommand(line string) {
+	name, args := line[1:], ``
+	if i := strings.IndexAny(name, " \t\n"); i >= 0 {
+		name, args = name[:i], strings.TrimSpace(name[i:])
+	}
+	c, ok := commands[name]
+	if !ok {
+		fmt.Fprintf(s.Out, "unknown command :%s; try :help\n", name)
+		return
+	}
+	if c.Args != `` && args == `` {
+		fmt.Fprintf(s.Out, "usage: :%s %s\n", c.Name, c.Args)
+		return
+	}
+	if err := c.Run(s, args); err != nil {
+		fmt.Fprintf(s.Out, "%s\n", err)
+	}
+}
+
+func helpCommand(s *Session, _ string) error {
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		c := commands[name]
+		fmt.Fprintf(s.Out, "%-16s %s\n", strings.TrimSpace(`:`+c.Name+` `+c.Args), c.Help)
+	}
+	return nil
+}
+
+func pasteCommand(s *Session, _ string) error {
+	s.Print(s.Run(``, readPaste(s.lines, s.Out)))
+	return nil
+}
+
+func tokensCommand(s *Session, src string) error {
+	l := lexer.New(src)
+	l.SetMode(lexer.ScanComments)
+	for tok := l.NextToken(); ; tok = l.NextToken() {
+		fmt.Fprintf(s.Out, "%-6s %-10s %q\n", tok.Pos, tok.Type, tok.Literal)
+		if tok.Type == token.EOF {
+			break
+		}
+	}
+	for _, e := range l.ErrorList() {
+		s.printDiagnostic(``, src, &diagnostics.Diagnostic{Message: e.Msg, Pos: e.Pos})
+	}
+	return nil
+}
+
+func astCommand(s *Session, src string) error {
+	p := parser.New(lexer.New(src))
+	program := p.ParseProgram()
+	if errs := p.ErrorList(); len(errs) > 0 {
+		for _, d := range diagnostics.FromParseErrors(errs) {
+			s.printDiagnostic(``, src, d)
+		}
+		return nil
+	}
+	return ast.Fprint(s.Out, program)
+}
+
+func envCommand(s *Session, _ string) error {
+	for _, name := range s.Names() {
+		val, _ := s.Get(name)
+		fmt.Fprintf(s.Out, "%s = %s\n", name, val.Inspect())
+	}
+	return nil
+}
+
+func typeCommand(s *Session, src string) error {
+	res, err := s.Run(``, src)
+	if err != nil || res == nil {
+		s.Print(res, err)
+		return nil
+	}
+	fmt.Fprintf(s.Out, "%s\n", res.Type())
+	return nil
+}
+
+func loadCommand(s *Session, filename string) error {
+	src, err := os.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+	if _, err := s.Run(filename, string(src)); err != nil {
+		s.Print(nil, err)
+	}
+	return nil
+}
+
+func resetCommand(s *Session, _ string) error {
+	return s.Reset()
+}
+
+func timeCommand(s *Session, src string) error {
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	start := time.Now()
+	res, err := s.Run(``, src)
+	elapsed := time.Since(start)
+	runtime.ReadMemStats(&after)
+
+	s.Print(res, err)
+	fmt.Fprintf(s.Out, "took %s, %d allocations, %d bytes\n",
+		elapsed, after.Mallocs-before.Mallocs, after.TotalAlloc-before.TotalAlloc)
+	return nil
+}
@@ -0,0 +1,116 @@
+package repl
+
+import (
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/cszczepaniak/monkey"
+	"github.com/cszczepaniak/monkey/compiler"
+	"github.com/cszczepaniak/monkey/evaluator"
+	"github.com/cszczepaniak/monkey/lexer"
+	"github.com/cszczepaniak/monkey/object"
+	"github.com/cszczepaniak/monkey/parser"
+	"github.com/cszczepaniak/monkey/vm"
+)
+
+// Engine selects the backend the REPL runs programs on.
+type Engine string
+
+const (
+	// EngineEval walks the syntax tree with the evaluator package.
+	EngineEval Engine = "eval"
+	// EngineVM compiles to bytecode and runs it on the vm package. Unlike
+	// the evaluator, the vm does not optimize tail calls.
+	EngineVM Engine = "vm"
+)
+
+// runner runs one input, keeping bindings from earlier inputs.
+type runner interface {
+	RunFile(filename, src string) (object.Object, error)
+	// Names returns the names currently bound, in sorted order.
+	Names() []string
+	Get(name string) (object.Object, bool)
+}
+
+type evalRunner struct {
+	*monkey.Interpreter
+}
+
+func (r evalRunner) Names() []string {
+	return r.Env().Names()
+}
+
+func (r evalRunner) Get(name string) (object.Object, bool) {
+	return r.Env().Get(name)
+}
+
+// newRunner returns a runner for engine whose programs write their output to
+// out.
+func newRunner(engine Engine, out io.Writer) (runner, error) {
+	config := evaluator.Config{Out: out}
+	switch engine {
+	case EngineEval, ``:
+		return evalRunner{monkey.NewWithConfig(config)}, nil
+	case EngineVM:
+		return newVMRunner(config), nil
+	default:
+		return nil, fmt.Errorf(`unknown engine %q`, engine)
+	}
+}
+
+// vmRunner compiles each line with the symbol table and constants of the
+// lines before it, and runs it against the same globals.
+type vmRunner struct {
+	config    evaluator.Config
+	symbols   *compiler.SymbolTable
+	constants []object.Object
+	globals   []object.Object
+}
+
+func newVMRunner(config evaluator.Config) *vmRunner {
+	return &vmRunner{
+		config:  config,
+		symbols: compiler.NewSymbolTable(),
+		globals: make([]object.Object, vm.GlobalsSize),
+	}
+}
+
+func (r *vmRunner) RunFile(filename, src string) (object.Object, error) {
+	p := parser.New(lexer.NewFile(filename, src))
+	program := p.ParseProgram()
+	if errs := p.Errors(); len(errs) > 0 {
+		return nil, &monkey.ParseError{Errors: errs, ErrorList: p.ErrorList()}
+	}
+
+	c := compiler.NewWithState(r.symbols, r.constants)
+	if err := c.Compile(program); err != nil {
+		return nil, err
+	}
+	r.constants = c.Constants()
+
+	m := vm.NewWithGlobals(c.Bytecode(), r.config, r.globals)
+	if err := m.Run(); err != nil {
+		return nil, err
+	}
+	return m.LastPoppedStackElem(), nil
+}
+
+func (r *vmRunner) Names() []string {
+	var names []string
+	for i, name := range r.symbols.Names() {
+		if r.globals[i] != nil {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (r *vmRunner) Get(name string) (object.Object, bool) {
+	sym, ok := r.symbols.Resolve(name)
+	if !ok || r.globals[sym.Index] == nil {
+		return nil, false
+	}
+	return r.globals[sym.Index], true
+}
@@ -0,0 +1,192 @@
+package repl
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/cszczepaniak/monkey/lexer"
+	"github.com/cszczepaniak/monkey/parser"
+	"github.com/cszczepaniak/monkey/repl/lineedit"
+	"github.com/cszczepaniak/monkey/token"
+)
+
+// HISTORY_FILE is the file in the user's home directory that the line editor
+// keeps its history in.
+const HISTORY_FILE = ".monkey_history"
+
+// lineReader reads lines of input, showing a prompt before each.
+type lineReader interface {
+	ReadLine(prompt string) (string, error)
+}
+
+// scannerReader reads plain lines, for when the input is not a terminal.
+type scannerReader struct {
+	scanner *bufio.Scanner
+	out     io.Writer
+}
+
+func (r *scannerReader) ReadLine(prompt string) (string, error) {
+	fmt.Fprint(r.out, prompt)
+	if !r.scanner.Scan() {
+		if err := r.scanner.Err(); err != nil {
+			return ``, err
+		}
+		return ``, io.EOF
+	}
+	return r.scanner.Text(), nil
+}
+
+// editorReader reads lines with the line editor, saving each one to the
+// history file.
+type editorReader struct {
+	editor      *lineedit.Editor
+	historyFile string
+}
+
+func newEditorReader(in *os.File, out io.Writer, names func() []string) *editorReader {
+	r := &editorReader{editor: lineedit.New(in, out)}
+	r.editor.Complete = func(word string) []string {
+		var candidates []string
+		for _, c := range append(token.Keywords(), names()...) {
+			if strings.HasPrefix(c, word) && c != word {
+				candidates = append(candidates, c)
+			}
+		}
+		sort.Strings(candidates)
+		return candidates
+	}
+	if home, err := os.UserHomeDir(); err == nil {
+		r.historyFile = filepath.Join(home, HISTORY_FILE)
+		if f, err := os.Open(r.historyFile); err == nil {
+			r.editor.ReadHistory(f)
+			f.Close()
+		}
+	}
+	return r
+}
+
+func (r *editorReader) ReadLine(prompt string) (string, error) {
+	line, err := r.editor.Prompt(prompt)
+	if err != nil || strings.TrimSpace(line) == `` {
+		return line, err
+	}
+	r.editor.AddHistory(line)
+	r.saveHistory()
+	return line, nil
+}
+
+// saveHistory rewrites the history file with the editor's history, which
+// holds at most lineedit.MaxHistory entries, so the file never grows past
+// that. It writes a temporary file and renames it over the history file, so
+// that an interrupted write cannot lose the history. Failures are ignored:
+// the REPL works the same without a history file.
+func (r *editorReader) saveHistory() {
+	if r.historyFile == `` {
+		return
+	}
+	f, err := ioutil.TempFile(filepath.Dir(r.historyFile), HISTORY_FILE)
+	if err != nil {
+		return
+	}
+	err = r.editor.WriteHistory(f)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), r.historyFile)
+	}
+	if err != nil {
+		os.Remove(f.Name())
+	}
+}
+
+// newLineReader uses the line editor if both in and out are terminals, and
+// reads plain lines otherwise.
+func newLineReader(in io.Reader, out io.Writer, names func() []string) lineReader {
+	inFile, ok := in.(*os.File)
+	if !ok || !lineedit.IsTerminal(inFile) {
+		return &scannerReader{scanner: bufio.NewScanner(in), out: out}
+	}
+	if outFile, ok := out.(*os.File); !ok || !lineedit.IsTerminal(outFile) {
+		return &scannerReader{scanner: bufio.NewScanner(in), out: out}
+	}
+	return newEditorReader(inFile, out, names)
+}
+
+// incomplete reports whether src looks like the start of a longer input: it
+// has unclosed brackets or comments, or it fails to parse only because it
+// ended too soon.
+func incomplete(src string) bool {
+	l := lexer.New(src)
+	depth := 0
+	for tok := l.NextToken(); ; tok = l.NextToken() {
+		switch tok.Type {
+		case token.LPAREN, token.LBRACE, token.LBRACKET:
+			depth++
+		case token.RPAREN, token.RBRACE, token.RBRACKET:
+			depth--
+		}
+		if tok.Type == token.EOF {
+			break
+		}
+	}
+	if depth > 0 {
+		return true
+	}
+
+	for _, e := range l.ErrorList() {
+		if e.Msg == `unterminated block comment` {
+			return true
+		}
+	}
+	p := parser.New(lexer.New(src))
+	p.ParseProgram()
+	for _, e := range p.ErrorList() {
+		if e.Found.Type == token.EOF {
+			return true
+		}
+	}
+	return false
+}
+
+// readInput reads lines until they form a complete input, prompting for each
+// continuation line. A blank continuation line submits the input as it is,
+// and an interrupt discards it. Meta-commands are always a single line.
+func readInput(r lineReader) (string, error) {
+	src, err := r.ReadLine(PROMPT)
+	if err != nil {
+		return ``, err
+	}
+	for !strings.HasPrefix(strings.TrimSpace(src), `:`) && incomplete(src) {
+		line, err := r.ReadLine(CONTINUATION_PROMPT)
+		if err == lineedit.ErrInterrupted {
+			return ``, nil
+		}
+		if err != nil || strings.TrimSpace(line) == `` {
+			break
+		}
+		src += "\n" + line
+	}
+	return src, nil
+}
+
+// readPaste reads lines verbatim until a line containing only :end, or the
+// end of the input.
+func readPaste(r lineReader, out io.Writer) string {
+	fmt.Fprintln(out, `// Entering paste mode; end with :end on its own line.`)
+	var lines []string
+	for {
+		line, err := r.ReadLine(``)
+		if err != nil || strings.TrimSpace(line) == `:end` {
+			break
+		}
+		lines = append(lines, line)
+	}
+	return strings.Join(lines, "\n")
+}
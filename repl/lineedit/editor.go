@@ -0,0 +1,384 @@
+// Package lineedit reads lines from a terminal with Emacs-style editing keys,
+// history with reverse search, and tab completion.
+package lineedit
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// ErrInterrupted is returned by Prompt when the user presses Ctrl-C.
+var ErrInterrupted = errors.New(`interrupted`)
+
+// Completer returns the candidates for completing word, the identifier
+// immediately before the cursor.
+type Completer func(word string) []string
+
+// Editor reads lines from a terminal. An Editor is not safe for concurrent
+// use.
+type Editor struct {
+	in  *bufio.Reader
+	out io.Writer
+
+	// makeRaw puts the terminal into raw mode and returns a function that
+	// restores it.
+	makeRaw func() (func(), error)
+
+	history  []string
+	Complete Completer
+}
+
+// New returns an editor reading keys from the terminal in and echoing to
+// out. Use IsTerminal to check that in is a terminal first.
+func New(in *os.File, out io.Writer) *Editor {
+	e := newEditor(in, out)
+	e.makeRaw = func() (func(), error) { return makeRaw(int(in.Fd())) }
+	return e
+}
+
+func newEditor(in io.Reader, out io.Writer) *Editor {
+	return &Editor{
+		in:      bufio.NewReader(in),
+		out:     out,
+		makeRaw: func() (func(), error) { return func() {}, nil },
+	}
+}
+
+// IsTerminal reports whether f is a terminal that an Editor can use.
+func IsTerminal(f *os.File) bool {
+	return isTerminal(int(f.Fd()))
+}
+
+// History returns the lines added to the history, oldest first.
+func (e *Editor) History() []string {
+	return e.history
+}
+
+// AddHistory appends line to the history, unless it is blank or repeats the
+// most recent entry. Once the history holds MaxHistory entries, the oldest
+// is dropped for each one added.
+func (e *Editor) AddHistory(line string) {
+	if strings.TrimSpace(line) == `` {
+		return
+	}
+	if n := len(e.history); n > 0 && e.history[n-1] == line {
+		return
+	}
+	if len(e.history) >= MaxHistory {
+		e.history = e.history[len(e.history)-MaxHistory+1:]
+	}
+	e.history = append(e.history, line)
+}
+
+// line is the state of the line being edited.
+type line struct {
+	prompt string
+	buf    []rune
+	pos    int
+}
+
+// Prompt shows prompt and reads a line. It returns io.EOF if the user
+// presses Ctrl-D on an empty line, and ErrInterrupted on Ctrl-C.
+func (e *Editor) Prompt(prompt string) (string, error) {
+	restore, err := e.makeRaw()
+	if err != nil {
+		return ``, err
+	}
+	defer restore()
+
+	l := &line{prompt: prompt}
+	// histIdx is the history entry being shown; len(history) is the line
+	// being typed, which is saved in pending while browsing.
+	histIdx := len(e.history)
+	var pending []rune
+
+	e.refresh(l)
+	for {
+		r, _, err := e.in.ReadRune()
+		if err != nil {
+			return ``, err
+		}
+		switch r {
+		case '\r', '\n':
+			fmt.Fprint(e.out, "\r\n")
+			return string(l.buf), nil
+		case ctrl('C'):
+			fmt.Fprint(e.out, "^C\r\n")
+			return ``, ErrInterrupted
+		case ctrl('D'):
+			if len(l.buf) == 0 {
+				fmt.Fprint(e.out, "\r\n")
+				return ``, io.EOF
+			}
+			l.delete()
+		case ctrl('A'):
+			l.pos = 0
+		case ctrl('E'):
+			l.pos = len(l.buf)
+		case ctrl('B'):
+			l.left()
+		case ctrl('F'):
+			l.right()
+		case ctrl('H'), 127:
+			l.backspace()
+		case ctrl('K'):
+			l.buf = l.buf[:l.pos]
+		case ctrl('U'):
+			l.buf = l.buf[l.pos:]
+			l.pos = 0
+		case ctrl('W'):
+			l.deleteWord()
+		case ctrl('L'):
+			fmt.Fprint(e.out, "\x1b[H\x1b[2J")
+		case ctrl('P'):
+			histIdx, pending = e.browse(l, histIdx, histIdx-1, pending)
+		case ctrl('N'):
+			histIdx, pending = e.browse(l, histIdx, histIdx+1, pending)
+		case ctrl('R'):
+			done, err := e.search(l)
+			if err != nil {
+				return ``, err
+			}
+			if done {
+				fmt.Fprint(e.out, "\r\n")
+				return string(l.buf), nil
+			}
+		case '\t':
+			e.complete(l)
+		case 27:
+			switch e.readEscape() {
+			case `[A`, `OA`:
+				histIdx, pending = e.browse(l, histIdx, histIdx-1, pending)
+			case `[B`, `OB`:
+				histIdx, pending = e.browse(l, histIdx, histIdx+1, pending)
+			case `[C`, `OC`:
+				l.right()
+			case `[D`, `OD`:
+				l.left()
+			case `[H`, `OH`, `[1~`, `[7~`:
+				l.pos = 0
+			case `[F`, `OF`, `[4~`, `[8~`:
+				l.pos = len(l.buf)
+			case `[3~`:
+				l.delete()
+			}
+		default:
+			if unicode.IsPrint(r) {
+				l.insert(r)
+			}
+		}
+		e.refresh(l)
+	}
+}
+
+func ctrl(r rune) rune {
+	return r & 0x1f
+}
+
+// readEscape reads the rest of an escape sequence after ESC, such as "[A"
+// for the up arrow.
+func (e *Editor) readEscape() string {
+	r, _, err := e.in.ReadRune()
+	if err != nil || r != '[' && r != 'O' {
+		return ``
+	}
+	seq := []rune{r}
+	for {
+		r, _, err := e.in.ReadRune()
+		if err != nil {
+			return ``
+		}
+		seq = append(seq, r)
+		if r >= 0x40 && r <= 0x7e {
+			return string(seq)
+		}
+	}
+}
+
+// browse replaces the line with history entry to, if it exists.
+func (e *Editor) browse(l *line, from, to int, pending []rune) (int, []rune) {
+	if to < 0 || to > len(e.history) {
+		return from, pending
+	}
+	if from == len(e.history) {
+		pending = append([]rune(nil), l.buf...)
+	}
+	if to == len(e.history) {
+		l.buf = pending
+	} else {
+		l.buf = []rune(e.history[to])
+	}
+	l.pos = len(l.buf)
+	return to, pending
+}
+
+// search runs a reverse incremental search of the history, leaving the match
+// in l. It reports whether the user pressed Enter to accept the match as the
+// whole input.
+func (e *Editor) search(l *line) (bool, error) {
+	orig := *l
+	var query []rune
+	idx := len(e.history)
+	match := ``
+
+	// find searches backwards from history entry start, which may be the
+	// current match so that it is kept while it still matches.
+	find := func(start int) {
+		if start >= len(e.history) {
+			start = len(e.history) - 1
+		}
+		for i := start; i >= 0; i-- {
+			if strings.Contains(e.history[i], string(query)) {
+				idx, match = i, e.history[i]
+				return
+			}
+		}
+	}
+
+	for {
+		fmt.Fprintf(e.out, "\r(reverse-i-search)`%s': %s\x1b[K", string(query), match)
+		r, _, err := e.in.ReadRune()
+		if err != nil {
+			return false, err
+		}
+		switch {
+		case r == ctrl('R'):
+			find(idx - 1)
+			continue
+		case r == ctrl('C') || r == ctrl('G'):
+			*l = orig
+			return false, nil
+		case r == ctrl('H') || r == 127:
+			if len(query) > 0 {
+				query = query[:len(query)-1]
+				idx, match = len(e.history), ``
+				find(idx - 1)
+			}
+			continue
+		case unicode.IsPrint(r):
+			query = append(query, r)
+			find(idx)
+			continue
+		}
+
+		l.buf = []rune(match)
+		l.pos = len(l.buf)
+		switch r {
+		case '\r', '\n':
+			return true, nil
+		case 27:
+			e.readEscape()
+		}
+		return false, nil
+	}
+}
+
+// complete completes the identifier before the cursor. A single candidate is
+// inserted; otherwise the longest common prefix is inserted, or the
+// candidates are listed if there is none to insert.
+func (e *Editor) complete(l *line) {
+	if e.Complete == nil {
+		return
+	}
+	start := l.pos
+	for start > 0 && isIdentRune(l.buf[start-1]) {
+		start--
+	}
+	word := string(l.buf[start:l.pos])
+	if word == `` {
+		return
+	}
+
+	candidates := e.Complete(word)
+	switch len(candidates) {
+	case 0:
+		return
+	case 1:
+		l.insertString(strings.TrimPrefix(candidates[0], word))
+		return
+	}
+	if prefix := commonPrefix(candidates); len(prefix) > len(word) {
+		l.insertString(strings.TrimPrefix(prefix, word))
+		return
+	}
+	sort.Strings(candidates)
+	fmt.Fprintf(e.out, "\r\n%s\r\n", strings.Join(candidates, `  `))
+}
+
+func isIdentRune(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+func commonPrefix(words []string) string {
+	prefix := []rune(words[0])
+	for _, w := range words[1:] {
+		for !strings.HasPrefix(w, string(prefix)) {
+			prefix = prefix[:len(prefix)-1]
+		}
+	}
+	return string(prefix)
+}
+
+// refresh redraws the line and places the cursor.
+func (e *Editor) refresh(l *line) {
+	fmt.Fprintf(e.out, "\r%s%s\x1b[K\r", l.prompt, string(l.buf))
+	if n := len([]rune(l.prompt)) + l.pos; n > 0 {
+		fmt.Fprintf(e.out, "\x1b[%dC", n)
+	}
+}
+
+func (l *line) insert(r rune) {
+	l.buf = append(l.buf, 0)
+	copy(l.buf[l.pos+1:], l.buf[l.pos:])
+	l.buf[l.pos] = r
+	l.pos++
+}
+
+func (l *line) insertString(s string) {
+	for _, r := range s {
+		l.insert(r)
+	}
+}
+
+func (l *line) left() {
+	if l.pos > 0 {
+		l.pos--
+	}
+}
+
+func (l *line) right() {
+	if l.pos < len(l.buf) {
+		l.pos++
+	}
+}
+
+func (l *line) backspace() {
+	if l.pos > 0 {
+		l.buf = append(l.buf[:l.pos-1], l.buf[l.pos:]...)
+		l.pos--
+	}
+}
+
+func (l *line) delete() {
+	if l.pos < len(l.buf) {
+		l.buf = append(l.buf[:l.pos], l.buf[l.pos+1:]...)
+	}
+}
+
+func (l *line) deleteWord() {
+	start := l.pos
+	for start > 0 && l.buf[start-1] == ' ' {
+		start--
+	}
+	for start > 0 && l.buf[start-1] != ' ' {
+		start--
+	}
+	l.buf = append(l.buf[:start], l.buf[l.pos:]...)
+	l.pos = start
+}
@@ -0,0 +1,122 @@
+package lineedit
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPrompt(t *testing.T) {
+	tests := []struct {
+		name    string
+		history []string
+		keys    string
+		exp     string
+	}{
+		{`plain`, nil, "let x = 1;\r", `let x = 1;`},
+		{`backspace`, nil, "abx\x7fc\r", `abc`},
+		{`left and insert`, nil, "ac\x1b[Db\r", `abc`},
+		{`home and end`, nil, "bc\x01a\x05d\r", `abcd`},
+		{`delete`, nil, "abc\x01\x1b[3~\r", `bc`},
+		{`kill to end`, nil, "abc\x02\x02\x0b\r", `a`},
+		{`kill to start`, nil, "abc\x02\x15\r", `c`},
+		{`delete word`, nil, "let x\x17y\r", `let y`},
+		{`unicode`, nil, "héllo\x7f\x7f\r", `hél`},
+		{`history up`, []string{`one`, `two`}, "\x1b[A\x1b[A\r", `one`},
+		{`history down`, []string{`one`, `two`}, "new\x1b[A\x1b[B\r", `new`},
+		{`history past oldest`, []string{`one`}, "\x1b[A\x1b[A\r", `one`},
+		{`search`, []string{`let a = 1`, `puts(a)`, `let b = 2`}, "\x12let\r", `let b = 2`},
+		{`search older`, []string{`let a = 1`, `puts(a)`, `let b = 2`}, "\x12let\x12\r", `let a = 1`},
+		{`search then edit`, []string{`let a = 1`}, "\x12a =\x1b[C2\r", `let a = 12`},
+		{`search cancelled`, []string{`let a = 1`}, "x\x12let\x07\r", `x`},
+	}
+
+	for _, tc := range tests {
+		e := newEditor(strings.NewReader(tc.keys), ioutil.Discard)
+		for _, h := range tc.history {
+			e.AddHistory(h)
+		}
+		line, err := e.Prompt(`$ `)
+		assert.NoError(t, err, tc.name)
+		assert.Equal(t, tc.exp, line, tc.name)
+	}
+}
+
+func TestPromptEndings(t *testing.T) {
+	e := newEditor(strings.NewReader("\x04"), ioutil.Discard)
+	_, err := e.Prompt(`$ `)
+	assert.Equal(t, io.EOF, err)
+
+	e = newEditor(strings.NewReader("abc\x03"), ioutil.Discard)
+	_, err = e.Prompt(`$ `)
+	assert.Equal(t, ErrInterrupted, err)
+}
+
+func TestComplete(t *testing.T) {
+	names := []string{`let`, `len`, `length`, `if`}
+	complete := func(word string) []string {
+		var out []string
+		for _, n := range names {
+			if strings.HasPrefix(n, word) && n != word {
+				out = append(out, n)
+			}
+		}
+		return out
+	}
+
+	tests := []struct {
+		keys string
+		exp  string
+	}{
+		{"i\t(x)\r", `if(x)`},
+		{"lengt\t\r", `length`},
+		{"le\t\r", `le`},
+		{"len\t\r", `length`},
+		{"x\t\r", `x`},
+	}
+	for _, tc := range tests {
+		var out bytes.Buffer
+		e := newEditor(strings.NewReader(tc.keys), &out)
+		e.Complete = complete
+		line, err := e.Prompt(`$ `)
+		assert.NoError(t, err, tc.keys)
+		assert.Equal(t, tc.exp, line, tc.keys)
+	}
+
+	var out bytes.Buffer
+	e := newEditor(strings.NewReader("le\t\r"), &out)
+	e.Complete = complete
+	e.Prompt(`$ `)
+	assert.Contains(t, out.String(), "\r\nlen  length  let\r\n")
+}
+
+func TestHistory(t *testing.T) {
+	e := newEditor(strings.NewReader(``), ioutil.Discard)
+	e.AddHistory(`a`)
+	e.AddHistory(`a`)
+	e.AddHistory(`  `)
+	e.AddHistory(`b`)
+	assert.Equal(t, []string{`a`, `b`}, e.History())
+
+	var buf bytes.Buffer
+	assert.NoError(t, e.WriteHistory(&buf))
+	assert.Equal(t, "a\nb\n", buf.String())
+
+	var lines []string
+	for i := 0; i < MaxHistory+5; i++ {
+		lines = append(lines, strings.Repeat(`x`, i+1))
+	}
+	e = newEditor(strings.NewReader(``), ioutil.Discard)
+	assert.NoError(t, e.ReadHistory(strings.NewReader(strings.Join(lines, "\n"))))
+	assert.Len(t, e.History(), MaxHistory)
+	assert.Equal(t, lines[len(lines)-1], e.History()[MaxHistory-1])
+
+	e.AddHistory(`y`)
+	assert.Len(t, e.History(), MaxHistory)
+	assert.Equal(t, lines[6], e.History()[0])
+	assert.Equal(t, `y`, e.History()[MaxHistory-1])
+}
@@ -0,0 +1,30 @@
+package lineedit
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+)
+
+// MaxHistory is the number of entries the history keeps.
+const MaxHistory = 1000
+
+// ReadHistory adds the lines read from r to the history, keeping at most
+// MaxHistory of the most recent entries.
+func (e *Editor) ReadHistory(r io.Reader) error {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		e.AddHistory(scanner.Text())
+	}
+	return scanner.Err()
+}
+
+// WriteHistory writes the history to w, one entry per line.
+func (e *Editor) WriteHistory(w io.Writer) error {
+	for _, h := range e.history {
+		if _, err := fmt.Fprintln(w, h); err != nil {
+			return err
+		}
+	}
+	return nil
+}
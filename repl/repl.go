@@ -1,47 +1,131 @@
 package repl
 
 import (
-	"bufio"
 	"fmt"
 	"io"
+	"os"
+	"strings"
 
-	"github.com/cszczepaniak/monkey/evaluator"
-	"github.com/cszczepaniak/monkey/lexer"
+	"github.com/cszczepaniak/monkey"
+	"github.com/cszczepaniak/monkey/diagnostics"
 	"github.com/cszczepaniak/monkey/object"
-	"github.com/cszczepaniak/monkey/parser"
+	"github.com/cszczepaniak/monkey/repl/lineedit"
 )
 
-const PROMPT = "$ "
+const (
+	PROMPT              = "$ "
+	CONTINUATION_PROMPT = "... "
+)
+
+// Session is the state of a running REPL, which meta-commands operate on.
+type Session struct {
+	// Out is where the REPL writes, along with the programs it runs.
+	Out io.Writer
 
+	engine Engine
+	runner runner
+	lines  lineReader
+	color  bool
+
+	// filename and src are the input last run, which Print quotes in error
+	// messages.
+	filename, src string
+}
+
+// Start runs the REPL on the evaluator.
 func Start(in io.Reader, out io.Writer) {
-	scanner := bufio.NewScanner(in)
-	env := object.NewEnvironment()
+	StartEngine(in, out, EngineEval)
+}
+
+// StartEngine runs the REPL on the given engine.
+func StartEngine(in io.Reader, out io.Writer, engine Engine) {
+	s := &Session{Out: out, engine: engine}
+	if f, ok := out.(*os.File); ok {
+		s.color = UseColor(f)
+	}
+	if err := s.Reset(); err != nil {
+		fmt.Fprintf(out, "%s\n", err)
+		return
+	}
+	s.lines = newLineReader(in, out, s.Names)
 
 	for {
-		fmt.Fprint(out, PROMPT)
-		scanned := scanner.Scan()
-		if !scanned {
+		src, err := readInput(s.lines)
+		if err == lineedit.ErrInterrupted {
+			continue
+		}
+		if err != nil {
 			return
 		}
-
-		line := scanner.Text()
-		l := lexer.New(line)
-		p := parser.New(l)
-		program := p.ParseProgram()
-		if len(p.Errors()) > 0 {
-			printParserErrors(out, p.Errors())
+		if strings.HasPrefix(strings.TrimSpace(src), `:`) {
+			s.runCommand(strings.TrimSpace(src))
 			continue
 		}
+		s.Print(s.Run(``, src))
+	}
+}
+
+// UseColor reports whether diagnostics written to f should be coloured: f
+// must be a terminal, and the NO_COLOR environment variable must be unset.
+func UseColor(f *os.File) bool {
+	return lineedit.IsTerminal(f) && os.Getenv(`NO_COLOR`) == ``
+}
 
-		result := evaluator.Eval(program, env)
+// Run runs src in the session. Errors report positions in filename.
+func (s *Session) Run(filename, src string) (object.Object, error) {
+	s.filename, s.src = filename, src
+	return s.runner.RunFile(filename, src)
+}
+
+// Print writes the result of Run: the value, or the errors, quoting the
+// input they are in.
+func (s *Session) Print(result object.Object, err error) {
+	switch e := err.(type) {
+	case nil:
 		if result != nil {
-			fmt.Fprintf(out, "%s\n", result.Inspect())
+			fmt.Fprintf(s.Out, "%s\n", result.Inspect())
+		}
+	case *monkey.ParseError:
+		for _, d := range diagnostics.FromParseErrors(e.ErrorList) {
+			s.printDiagnostic(s.filename, s.src, d)
+		}
+	case *object.Error:
+		// Positions in earlier inputs look like positions in this one, so an
+		// error in a function, which an earlier input may have defined, is
+		// not quoted, and the variables of the function it is in are not
+		// known.
+		src := s.src
+		if len(e.Stack) > 0 && s.filename == `` {
+			src = ``
 		}
+		s.printDiagnostic(s.filename, src, diagnostics.FromRuntimeError(e, src, s.Names()))
+	default:
+		fmt.Fprintf(s.Out, "%s\n", e)
 	}
 }
 
-func printParserErrors(out io.Writer, errs []string) {
-	for _, e := range errs {
-		fmt.Fprintf(out, "%s\n", e)
+// Names returns the names bound in the session, in sorted order.
+func (s *Session) Names() []string {
+	return s.runner.Names()
+}
+
+// Get returns the value bound to name in the session.
+func (s *Session) Get(name string) (object.Object, bool) {
+	return s.runner.Get(name)
+}
+
+// Reset discards all bindings made in the session.
+func (s *Session) Reset() error {
+	r, err := newRunner(s.engine, s.Out)
+	if err != nil {
+		return err
 	}
+	s.runner = r
+	return nil
+}
+
+// printDiagnostic writes d, quoting src, the input named filename.
+func (s *Session) printDiagnostic(filename, src string, d *diagnostics.Diagnostic) {
+	p := diagnostics.Printer{Filename: filename, Src: src, Color: s.color}
+	p.Fprint(s.Out, d)
 }
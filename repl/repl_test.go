@@ -0,0 +1,174 @@
+package repl
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/cszczepaniak/monkey/repl/lineedit"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIncomplete(t *testing.T) {
+	tests := []struct {
+		input string
+		exp   bool
+	}{
+		{`let x = 1;`, false},
+		{`let x = ;`, false},
+		{`"abc`, false},
+		{`foo)`, false},
+		{`let f = fn(x) {`, true},
+		{`f(1,`, true},
+		{`[1, 2`, true},
+		{`let x = 1 +`, true},
+		{`if (x) { 1 } else`, true},
+		{`1 /* comment`, true},
+		{`1 // comment`, false},
+		{"let f = fn(x) {\n  x\n}", false},
+	}
+
+	for _, tc := range tests {
+		assert.Equal(t, tc.exp, incomplete(tc.input), tc.input)
+	}
+}
+
+func TestMultilineInput(t *testing.T) {
+	tests := []struct {
+		input     string
+		expOutput string
+	}{{
+		"let add = fn(a, b) {\n  a + b\n};\nadd(1, 2)\n",
+		"$ ... ... fn(a, b) {\n{ (a + b); }\n}\n$ 3\n$ ",
+	}, {
+		"1 +\n\n2\n",
+		"$ ... 1:4: error: no prefix parse function for EOF found\n 1 | 1 +\n   |    ^\n$ 2\n$ ",
+	}, {
+		":paste\nlet x = 1\nlet y = 2\n:end\nx + y\n",
+		"$ // Entering paste mode; end with :end on its own line.\n2\n$ 3\n$ ",
+	}}
+
+	for _, tc := range tests {
+		var out bytes.Buffer
+		Start(strings.NewReader(tc.input), &out)
+		assert.Equal(t, tc.expOutput, out.String(), tc.input)
+	}
+}
+
+func TestVMEngine(t *testing.T) {
+	var out bytes.Buffer
+	StartEngine(strings.NewReader("let x = 2;\nx * 21\n"), &out, EngineVM)
+	assert.Equal(t, "$ 2\n$ 42\n$ ", out.String())
+}
+
+func TestPutsWritesToOut(t *testing.T) {
+	for _, engine := range []Engine{EngineEval, EngineVM} {
+		var out bytes.Buffer
+		StartEngine(strings.NewReader("puts(\"hi\")\n"), &out, engine)
+		assert.Equal(t, "$ hi\nnull\n$ ", out.String(), engine)
+	}
+}
+
+func TestCommands(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, `lib.mk`)
+	if err := os.WriteFile(file, []byte("let double = fn(x) { x * 2 };\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		input     string
+		expOutput string
+	}{{
+		":tokens let x\n",
+		"1:1    LET        \"let\"\n1:5    IDENT      \"x\"\n1:6    EOF        \"\"\n",
+	}, {
+		":ast -a\n",
+		"1:1 Program\n  Statements[0]: 1:1 ExpressionStatement\n    Expression: 1:1 PrefixExpression Operator=\"-\"\n      Right: 1:2 Identifier Value=\"a\"\n",
+	}, {
+		":ast let\n",
+		"1:4: error: Expected next token to be IDENT, got EOF instead\n 1 | let\n   |    ^\n",
+	}, {
+		"let b = 2; let a = \"x\";\n:env\n",
+		"\"x\"\na = \"x\"\nb = 2\n",
+	}, {
+		":type [1]\n:type foo\n",
+		"ARRAY\n" + "1:1: error: identifier not found: foo\n 1 | foo\n   | ^^^\n",
+	}, {
+		":load " + file + "\ndouble(4)\n",
+		"8\n",
+	}, {
+		":load " + filepath.Join(dir, `missing.mk`) + "\n",
+		"open " + filepath.Join(dir, `missing.mk`) + ": no such file or directory\n",
+	}, {
+		"let a = 1;\n:reset\na\n",
+		"1\n1:1: error: identifier not found: a\n 1 | a\n   | ^\n",
+	}, {
+		"let f = fn(x) { x / 0 }; f(1)\n",
+		"1:17: error: division by zero\n  = note: in f, called at 1:26\n",
+	}, {
+		"let count = 1; cont\n",
+		"1:16: error: identifier not found: cont\n 1 | let count = 1; cont\n   |                ^^^^\n   = help: did you mean count?\n",
+	}, {
+		":nope\n:type\n",
+		"unknown command :nope; try :help\nusage: :type <expr>\n",
+	}}
+
+	for _, tc := range tests {
+		for _, engine := range []Engine{EngineEval, EngineVM} {
+			var out bytes.Buffer
+			StartEngine(strings.NewReader(tc.input), &out, engine)
+			output := strings.ReplaceAll(out.String(), PROMPT, ``)
+			assert.Equal(t, tc.expOutput, output, tc.input)
+		}
+	}
+}
+
+func TestTimeCommand(t *testing.T) {
+	var out bytes.Buffer
+	Start(strings.NewReader(":time 1 + 2\n"), &out)
+	assert.Regexp(t, `^\$ 3\ntook .+, \d+ allocations, \d+ bytes\n\$ $`, out.String())
+}
+
+func TestRegisterCommand(t *testing.T) {
+	RegisterCommand(Command{Name: `names`, Help: `list bound names`, Run: func(s *Session, _ string) error {
+		fmt.Fprintln(s.Out, strings.Join(s.Names(), ` `))
+		return nil
+	}})
+	defer delete(commands, `names`)
+
+	var out bytes.Buffer
+	Start(strings.NewReader("let x = 1; let y = 2;\n:names\n:help\n"), &out)
+	assert.Contains(t, out.String(), "$ x y\n")
+	assert.Contains(t, out.String(), ":names           list bound names\n")
+	assert.Contains(t, out.String(), ":tokens <src>    show the tokens src lexes to\n")
+}
+
+func TestSaveHistory(t *testing.T) {
+	file := filepath.Join(t.TempDir(), HISTORY_FILE)
+	var lines []string
+	for i := 0; i < lineedit.MaxHistory+5; i++ {
+		lines = append(lines, fmt.Sprint(i))
+	}
+	require.NoError(t, ioutil.WriteFile(file, []byte(strings.Join(lines, "\n")+"\n"), 0o600))
+
+	r := &editorReader{editor: lineedit.New(os.Stdin, ioutil.Discard), historyFile: file}
+	f, err := os.Open(file)
+	require.NoError(t, err)
+	require.NoError(t, r.editor.ReadHistory(f))
+	f.Close()
+	r.editor.AddHistory(`let x = 1;`)
+	r.saveHistory()
+
+	saved, err := ioutil.ReadFile(file)
+	require.NoError(t, err)
+	savedLines := strings.Split(strings.TrimSuffix(string(saved), "\n"), "\n")
+	assert.Len(t, savedLines, lineedit.MaxHistory)
+	assert.Equal(t, `6`, savedLines[0])
+	assert.Equal(t, `let x = 1;`, savedLines[len(savedLines)-1])
+}
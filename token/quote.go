@@ -0,0 +1,35 @@
+package token
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// Quote returns s as a double-quoted string literal which lexes back to s.
+func Quote(s string) string {
+	var out strings.Builder
+	out.WriteByte('"')
+	for _, r := range s {
+		switch r {
+		case '"':
+			out.WriteString(`\"`)
+		case '\\':
+			out.WriteString(`\\`)
+		case '\n':
+			out.WriteString(`\n`)
+		case '\t':
+			out.WriteString(`\t`)
+		case '\r':
+			out.WriteString(`\r`)
+		default:
+			if unicode.IsPrint(r) {
+				out.WriteRune(r)
+			} else {
+				fmt.Fprintf(&out, `\u{%x}`, r)
+			}
+		}
+	}
+	out.WriteByte('"')
+	return out.String()
+}
@@ -1,13 +1,50 @@
 package token
 
+import (
+	"fmt"
+	"sort"
+)
+
 type Type string
 
 type Token struct {
 	Type    Type
 	Literal string
+	Pos     Position
+	End     Position
+}
+
+// Position is a location in the source. Offset is zero-based and counted in
+// bytes; Line and Column are one-based, and Column is counted in characters
+// (runes). A Position with a zero Line is invalid.
+type Position struct {
+	Filename string
+	Offset   int
+	Line     int
+	Column   int
 }
 
-func New(tokenType Type, ch byte) Token {
+func (p Position) IsValid() bool {
+	return p.Line > 0
+}
+
+// String returns the position formatted as file:line:col, omitting the file
+// name when there is none.
+func (p Position) String() string {
+	s := p.Filename
+	if p.IsValid() {
+		if s != `` {
+			s += `:`
+		}
+		s += fmt.Sprintf(`%d:%d`, p.Line, p.Column)
+	}
+	if s == `` {
+		s = `-`
+	}
+	return s
+}
+
+func New(tokenType Type, ch rune) Token {
 	return Token{
 		Type:    tokenType,
 		Literal: string(ch),
@@ -21,50 +58,104 @@ func LookupIdent(ident string) Type {
 	return IDENT
 }
 
+// Keywords returns the language's keywords in sorted order.
+func Keywords() []string {
+	kws := make([]string, 0, len(keywords))
+	for kw := range keywords {
+		kws = append(kws, kw)
+	}
+	sort.Strings(kws)
+	return kws
+}
+
 var keywords = map[string]Type{
-	"fn":     FUNCTION,
-	"let":    LET,
-	"if":     IF,
-	"else":   ELSE,
-	"true":   TRUE,
-	"false":  FALSE,
-	"return": RETURN,
+	"fn":       FUNCTION,
+	"let":      LET,
+	"const":    CONST,
+	"if":       IF,
+	"else":     ELSE,
+	"true":     TRUE,
+	"false":    FALSE,
+	"return":   RETURN,
+	"while":    WHILE,
+	"for":      FOR,
+	"in":       IN,
+	"break":    BREAK,
+	"continue": CONTINUE,
+	"try":      TRY,
+	"catch":    CATCH,
+	"finally":  FINALLY,
+	"throw":    THROW,
 }
 
 const (
 	ILLEGAL = "ILLEGAL"
 	EOF     = "EOF"
+	COMMENT = "COMMENT"
 
 	// identifiers and literals
-	IDENT = "IDENT"
-	INT   = "INT"
+	IDENT  = "IDENT"
+	INT    = "INT"
+	FLOAT  = "FLOAT"
+	STRING = "STRING"
 
 	// operators
-	ASSIGN   = "="
+	ASSIGN          = "="
+	PLUS_ASSIGN     = "+="
+	MINUS_ASSIGN    = "-="
+	ASTERISK_ASSIGN = "*="
+	SLASH_ASSIGN    = "/="
+
 	PLUS     = "+"
 	MINUS    = "-"
 	BANG     = "!"
 	ASTERISK = "*"
 	SLASH    = "/"
+	PERCENT  = "%"
 	LT       = "<"
 	GT       = ">"
+	LTE      = "<="
+	GTE      = ">="
 	EQ       = "=="
 	NEQ      = "!="
+	AND      = "&&"
+	OR       = "||"
+
+	AMPERSAND = "&"
+	PIPE      = "|"
+	CARET     = "^"
+	TILDE     = "~"
+	LSHIFT    = "<<"
+	RSHIFT    = ">>"
 
 	// delimiters
 	COMMA     = ","
 	SEMICOLON = ";"
+	COLON     = ":"
+	ELLIPSIS  = "..."
 	LPAREN    = "("
 	RPAREN    = ")"
 	LBRACE    = "{"
 	RBRACE    = "}"
+	LBRACKET  = "["
+	RBRACKET  = "]"
 
 	// keywords
 	FUNCTION = "FUNCTION"
 	LET      = "LET"
+	CONST    = "CONST"
 	IF       = "IF"
 	ELSE     = "ELSE"
 	TRUE     = "TRUE"
 	FALSE    = "FALSE"
 	RETURN   = "RETURN"
+	WHILE    = "WHILE"
+	FOR      = "FOR"
+	IN       = "IN"
+	BREAK    = "BREAK"
+	CONTINUE = "CONTINUE"
+	TRY      = "TRY"
+	CATCH    = "CATCH"
+	FINALLY  = "FINALLY"
+	THROW    = "THROW"
 )
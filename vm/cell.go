@@ -0,0 +1,36 @@
+package vm
+
+import (
+	"fmt"
+
+	"github.com/cszczepaniak/monkey/object"
+)
+
+// cell holds a variable which is captured by a closure and may change after
+// it is captured, so that the frame defining it and every closure capturing
+// it share one value.
+// A cell with a nil value is a variable which has not been set yet.
+type cell struct {
+	name  string
+	value object.Object
+}
+
+func (c *cell) Type() object.Type { return `CELL` }
+func (c *cell) Inspect() string   { return fmt.Sprintf(`cell(%s)`, c.name) }
+
+// get returns the value of the variable, or an error if it is not set.
+func (c *cell) get() object.Object {
+	if c.value == nil {
+		return &object.Error{Message: fmt.Sprintf(`identifier not found: %s`, c.name), Kind: object.KindName}
+	}
+	return c.value
+}
+
+// set assigns to the variable, returning an error if it is not set yet.
+func (c *cell) set(val object.Object) object.Object {
+	if c.value == nil {
+		return c.get()
+	}
+	c.value = val
+	return nil
+}
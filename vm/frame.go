@@ -0,0 +1,35 @@
+package vm
+
+import (
+	"github.com/cszczepaniak/monkey/code"
+	"github.com/cszczepaniak/monkey/object"
+	"github.com/cszczepaniak/monkey/token"
+)
+
+// Frame is the activation record of a closure being executed.
+type Frame struct {
+	cl          *object.Closure
+	ip          int
+	basePointer int
+	numArgs     int
+}
+
+func NewFrame(cl *object.Closure, basePointer, numArgs int) *Frame {
+	return &Frame{cl: cl, ip: -1, basePointer: basePointer, numArgs: numArgs}
+}
+
+func (f *Frame) Instructions() code.Instructions {
+	return f.cl.Fn.Instructions
+}
+
+// span returns the start and end of the source of the instruction being
+// executed.
+func (f *Frame) span() (pos, end token.Position) {
+	return f.cl.Fn.Positions[f.ip], f.cl.Fn.Ends[f.ip]
+}
+
+// callPos returns the source position of the call the frame is making. Its
+// ip is then on the operand of the OpCall.
+func (f *Frame) callPos() token.Position {
+	return f.cl.Fn.Positions[f.ip-1]
+}
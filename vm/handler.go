@@ -0,0 +1,30 @@
+package vm
+
+import "github.com/cszczepaniak/monkey/object"
+
+// handler is installed by OpTry to catch errors raised before the matching
+// OpEndTry. It records where to resume: the frame and the stack height at
+// the OpTry, and the instruction to jump to.
+type handler struct {
+	framesIndex int
+	sp          int
+	ip          int
+}
+
+// catch resumes at the innermost handler with err pushed, and reports
+// whether there was one. Errors for exceeding a limit are not caught.
+func (vm *VM) catch(err *object.Error) bool {
+	if len(vm.handlers) == 0 || err.Kind.IsLimit() {
+		return false
+	}
+	h := vm.handlers[len(vm.handlers)-1]
+	vm.handlers = vm.handlers[:len(vm.handlers)-1]
+
+	vm.unwindTo(err, h.framesIndex)
+	vm.framesIndex = h.framesIndex
+	// push refuses errors, which are results rather than values.
+	vm.stack[h.sp] = err
+	vm.sp = h.sp + 1
+	vm.currentFrame().ip = h.ip - 1
+	return true
+}
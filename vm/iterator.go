@@ -0,0 +1,14 @@
+package vm
+
+import (
+	"github.com/cszczepaniak/monkey/evaluator"
+	"github.com/cszczepaniak/monkey/object"
+)
+
+// iterator holds the state of a for loop on the stack while the loop runs.
+type iterator struct {
+	evaluator.Iterator
+}
+
+func (it *iterator) Type() object.Type { return `ITERATOR` }
+func (it *iterator) Inspect() string   { return `iterator` }
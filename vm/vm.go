@@ -0,0 +1,500 @@
+// Package vm executes bytecode produced by the compiler package. It shares the
+// evaluator's semantics for operators, indexing and builtins, so a program
+// behaves the same on either backend.
+package vm
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/cszczepaniak/monkey/code"
+	"github.com/cszczepaniak/monkey/compiler"
+	"github.com/cszczepaniak/monkey/evaluator"
+	"github.com/cszczepaniak/monkey/object"
+)
+
+const (
+	// StackSize and InitialFrames are the sizes the stack and the frame
+	// stack start at. Both grow as calls nest, up to the call depth limit
+	// of the VM's evaluator.Config.
+	StackSize     = 2048
+	InitialFrames = 1024
+	GlobalsSize   = 65536
+)
+
+type VM struct {
+	constants   []object.Object
+	globals     []object.Object
+	globalNames []string
+
+	ops    *evaluator.Evaluator
+	config evaluator.Config
+	budget *evaluator.Budget
+
+	stack []object.Object
+	sp    int // the top of the stack is stack[sp-1]
+
+	frames      []*Frame
+	framesIndex int
+
+	handlers []handler
+}
+
+func New(bytecode *compiler.Bytecode, config evaluator.Config) *VM {
+	return NewWithGlobals(bytecode, config, make([]object.Object, GlobalsSize))
+}
+
+// NewWithGlobals returns a VM that uses globals as its global store, so that
+// globals persist across runs, as they do in the REPL.
+func NewWithGlobals(bytecode *compiler.Bytecode, config evaluator.Config, globals []object.Object) *VM {
+	mainFn := &object.CompiledFunction{
+		Instructions: bytecode.Instructions,
+		Positions:    bytecode.Positions,
+		Ends:         bytecode.Ends,
+	}
+	frames := make([]*Frame, InitialFrames)
+	frames[0] = NewFrame(&object.Closure{Fn: mainFn}, 0, 0)
+
+	return &VM{
+		constants:   bytecode.Constants,
+		globals:     globals,
+		globalNames: bytecode.GlobalNames,
+		ops:         evaluator.New(config),
+		config:      config,
+		stack:       make([]object.Object, StackSize),
+		frames:      frames,
+		framesIndex: 1,
+	}
+}
+
+// LastPoppedStackElem returns the value of the last expression statement
+// run, which is the value of the program.
+func (vm *VM) LastPoppedStackElem() object.Object {
+	return vm.stack[vm.sp]
+}
+
+// Run executes the program. Runtime errors, including panics in host
+// functions, are returned as *object.Error.
+func (vm *VM) Run() error {
+	return vm.RunContext(context.Background())
+}
+
+// RunContext is like Run, but stops with a KindCanceled error once ctx is
+// done. The limits in the VM's Config apply to each run.
+func (vm *VM) RunContext(ctx context.Context) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = vm.errorf(object.KindRuntime, `internal error: %v`, r)
+		}
+	}()
+	vm.budget = evaluator.NewBudget(ctx, vm.config)
+	vm.handlers = vm.handlers[:0]
+
+	for vm.currentFrame().ip < len(vm.currentFrame().Instructions())-1 {
+		frame := vm.currentFrame()
+		frame.ip++
+		if err := vm.budget.Step(); err != nil {
+			err.Pos, err.End = frame.span()
+			vm.unwindTo(err, 1)
+			return err
+		}
+		ip := frame.ip
+		ins := frame.Instructions()
+		op := code.Opcode(ins[ip])
+
+		var res object.Object
+		switch op {
+		case code.OpConstant:
+			idx := code.ReadUint16(ins[ip+1:])
+			frame.ip += 2
+			if big, ok := vm.constants[idx].(*object.BigInteger); ok {
+				// Only integer literals too large for an int64 compile to
+				// big integer constants, and they depend on the overflow
+				// mode.
+				res = vm.push(vm.ops.BigIntegerLiteral(big.Value))
+			} else {
+				res = vm.push(vm.constants[idx])
+			}
+		case code.OpPop:
+			vm.pop()
+		case code.OpTrue:
+			res = vm.push(evaluator.TRUE)
+		case code.OpFalse:
+			res = vm.push(evaluator.FALSE)
+		case code.OpNull:
+			res = vm.push(evaluator.NULL)
+		case code.OpAdd, code.OpSub, code.OpMul, code.OpDiv, code.OpMod,
+			code.OpEqual, code.OpNotEqual, code.OpGreaterThan, code.OpLessThan,
+			code.OpGreaterEqual, code.OpLessEqual, code.OpBitAnd, code.OpBitOr,
+			code.OpBitXor, code.OpShiftLeft, code.OpShiftRight:
+			right := vm.pop()
+			left := vm.pop()
+			res = vm.pushNew(vm.ops.EvalInfix(infixOperators[op], left, right))
+		case code.OpMinus:
+			res = vm.pushNew(vm.ops.EvalPrefix(`-`, vm.pop()))
+		case code.OpBang:
+			res = vm.pushNew(vm.ops.EvalPrefix(`!`, vm.pop()))
+		case code.OpBitNot:
+			res = vm.pushNew(vm.ops.EvalPrefix(`~`, vm.pop()))
+		case code.OpJump:
+			frame.ip = int(code.ReadUint16(ins[ip+1:])) - 1
+		case code.OpJumpNotTruthy:
+			frame.ip += 2
+			if !isTruthy(vm.pop()) {
+				frame.ip = int(code.ReadUint16(ins[ip+1:])) - 1
+			}
+		case code.OpIter:
+			it, err := evaluator.Iterate(vm.pop())
+			if err != nil {
+				res = err
+				break
+			}
+			res = vm.push(&iterator{it})
+		case code.OpIterNext:
+			frame.ip += 2
+			val, ok := vm.stack[vm.sp-1].(*iterator).Next()
+			if !ok {
+				vm.pop()
+				frame.ip = int(code.ReadUint16(ins[ip+1:])) - 1
+				break
+			}
+			res = vm.push(val)
+		case code.OpTry:
+			frame.ip += 2
+			vm.handlers = append(vm.handlers, handler{
+				framesIndex: vm.framesIndex,
+				sp:          vm.sp,
+				ip:          int(code.ReadUint16(ins[ip+1:])),
+			})
+		case code.OpEndTry:
+			vm.handlers = vm.handlers[:len(vm.handlers)-1]
+		case code.OpCaught:
+			vm.stack[vm.sp-1] = evaluator.Caught(vm.stack[vm.sp-1].(*object.Error))
+		case code.OpThrow:
+			val := vm.pop()
+			if err, ok := val.(*object.Error); ok {
+				res = err
+			} else {
+				res = evaluator.Throw(val)
+			}
+		case code.OpJumpIfArg:
+			frame.ip += 3
+			if frame.numArgs > int(code.ReadUint8(ins[ip+1:])) {
+				frame.ip = int(code.ReadUint16(ins[ip+2:])) - 1
+			}
+		case code.OpSetGlobal:
+			idx := code.ReadUint16(ins[ip+1:])
+			frame.ip += 2
+			vm.globals[idx] = vm.pop()
+		case code.OpGetGlobal:
+			idx := code.ReadUint16(ins[ip+1:])
+			frame.ip += 2
+			res = vm.push(vm.getGlobal(int(idx)))
+		case code.OpSetLocal:
+			idx := code.ReadUint8(ins[ip+1:])
+			frame.ip++
+			vm.stack[frame.basePointer+int(idx)] = vm.pop()
+		case code.OpGetLocal:
+			idx := int(code.ReadUint8(ins[ip+1:]))
+			frame.ip++
+			val := vm.stack[frame.basePointer+idx]
+			if val == nil {
+				val = vm.errorf(object.KindName, `identifier not found: %s`, frame.cl.Fn.LocalNames[idx])
+			}
+			res = vm.push(val)
+		case code.OpGetFree:
+			idx := code.ReadUint8(ins[ip+1:])
+			frame.ip++
+			res = vm.push(frame.cl.Free[idx])
+		case code.OpAssignGlobal:
+			idx := int(code.ReadUint16(ins[ip+1:]))
+			frame.ip += 2
+			val := vm.pop()
+			if vm.globals[idx] == nil {
+				res = vm.errorf(object.KindName, `identifier not found: %s`, vm.globalNames[idx])
+				break
+			}
+			vm.globals[idx] = val
+		case code.OpAssignLocal:
+			idx := int(code.ReadUint8(ins[ip+1:]))
+			frame.ip++
+			val := vm.pop()
+			switch slot := vm.stack[frame.basePointer+idx].(type) {
+			case nil:
+				res = vm.errorf(object.KindName, `identifier not found: %s`, frame.cl.Fn.LocalNames[idx])
+			case *cell:
+				res = slot.set(val)
+			default:
+				vm.stack[frame.basePointer+idx] = val
+			}
+		case code.OpGetLocalCell:
+			idx := int(code.ReadUint8(ins[ip+1:]))
+			frame.ip++
+			c, ok := vm.stack[frame.basePointer+idx].(*cell)
+			if !ok {
+				res = vm.errorf(object.KindName, `identifier not found: %s`, frame.cl.Fn.LocalNames[idx])
+				break
+			}
+			res = vm.push(c.get())
+		case code.OpSetLocalCell:
+			idx := int(code.ReadUint8(ins[ip+1:]))
+			frame.ip++
+			val := vm.pop()
+			if c, ok := vm.stack[frame.basePointer+idx].(*cell); ok {
+				c.value = val
+			} else {
+				vm.stack[frame.basePointer+idx] = &cell{name: frame.cl.Fn.LocalNames[idx], value: val}
+			}
+		case code.OpCaptureLocal:
+			idx := int(code.ReadUint8(ins[ip+1:]))
+			frame.ip++
+			c, ok := vm.stack[frame.basePointer+idx].(*cell)
+			if !ok {
+				// The variable is not set yet, but the closure must see it
+				// once it is.
+				c = &cell{name: frame.cl.Fn.LocalNames[idx]}
+				vm.stack[frame.basePointer+idx] = c
+			}
+			res = vm.push(c)
+		case code.OpGetFreeCell:
+			idx := code.ReadUint8(ins[ip+1:])
+			frame.ip++
+			res = vm.push(frame.cl.Free[idx].(*cell).get())
+		case code.OpSetFreeCell:
+			idx := code.ReadUint8(ins[ip+1:])
+			frame.ip++
+			res = frame.cl.Free[idx].(*cell).set(vm.pop())
+		case code.OpCurrentClosure:
+			res = vm.push(frame.cl)
+		case code.OpArray:
+			n := int(code.ReadUint16(ins[ip+1:]))
+			frame.ip += 2
+			elements := make([]object.Object, n)
+			copy(elements, vm.stack[vm.sp-n:vm.sp])
+			vm.sp -= n
+			res = vm.pushNew(&object.Array{Elements: elements})
+		case code.OpHash:
+			n := int(code.ReadUint16(ins[ip+1:]))
+			frame.ip += 2
+			hash := vm.buildHash(vm.sp-n, vm.sp)
+			vm.sp -= n
+			res = vm.pushNew(hash)
+		case code.OpIndex:
+			index := vm.pop()
+			left := vm.pop()
+			res = vm.push(evaluator.EvalIndex(left, index))
+		case code.OpSetIndex:
+			val := vm.pop()
+			index := vm.pop()
+			left := vm.pop()
+			res = vm.push(evaluator.SetIndex(vm.budget, left, index, val))
+		case code.OpDupPair:
+			vm.push(vm.stack[vm.sp-2])
+			res = vm.push(vm.stack[vm.sp-2])
+		case code.OpClosure:
+			idx := code.ReadUint16(ins[ip+1:])
+			numFree := int(code.ReadUint8(ins[ip+3:]))
+			frame.ip += 3
+			free := make([]object.Object, numFree)
+			copy(free, vm.stack[vm.sp-numFree:vm.sp])
+			vm.sp -= numFree
+			res = vm.push(&object.Closure{Fn: vm.constants[idx].(*object.CompiledFunction), Free: free})
+		case code.OpCall:
+			numArgs := int(code.ReadUint8(ins[ip+1:]))
+			frame.ip++
+			res = vm.call(numArgs)
+		case code.OpReturnValue:
+			val := vm.pop()
+			if vm.framesIndex == 1 {
+				// A return at the top level ends the program.
+				vm.push(val)
+				vm.pop()
+				return nil
+			}
+			f := vm.popFrame()
+			vm.sp = f.basePointer - 1
+			res = vm.push(val)
+		default:
+			res = vm.errorf(object.KindRuntime, `unknown opcode %d`, op)
+		}
+
+		if err, ok := res.(*object.Error); ok {
+			if !err.Pos.IsValid() {
+				err.Pos, err.End = frame.cl.Fn.Positions[ip], frame.cl.Fn.Ends[ip]
+			}
+			if vm.catch(err) {
+				continue
+			}
+			vm.unwindTo(err, 1)
+			return err
+		}
+	}
+	return nil
+}
+
+var infixOperators = map[code.Opcode]string{
+	code.OpAdd:          `+`,
+	code.OpSub:          `-`,
+	code.OpMul:          `*`,
+	code.OpDiv:          `/`,
+	code.OpMod:          `%`,
+	code.OpEqual:        `==`,
+	code.OpNotEqual:     `!=`,
+	code.OpGreaterThan:  `>`,
+	code.OpLessThan:     `<`,
+	code.OpGreaterEqual: `>=`,
+	code.OpLessEqual:    `<=`,
+	code.OpBitAnd:       `&`,
+	code.OpBitOr:        `|`,
+	code.OpBitXor:       `^`,
+	code.OpShiftLeft:    `<<`,
+	code.OpShiftRight:   `>>`,
+}
+
+// getGlobal returns the global in slot idx. A slot that was never set holds
+// a builtin or is an undefined identifier.
+func (vm *VM) getGlobal(idx int) object.Object {
+	if val := vm.globals[idx]; val != nil {
+		return val
+	}
+	name := vm.globalNames[idx]
+	if builtin, ok := vm.ops.LookupBuiltin(name); ok {
+		return builtin
+	}
+	return vm.errorf(object.KindName, `identifier not found: %s`, name)
+}
+
+func (vm *VM) buildHash(start, end int) object.Object {
+	hash := object.NewHash()
+	for i := start; i < end; i += 2 {
+		key, ok := vm.stack[i].(object.Hashable)
+		if !ok {
+			return vm.errorf(object.KindType, `unusable as hash key: %s`, vm.stack[i].Type())
+		}
+		hash.Set(key, vm.stack[i+1])
+	}
+	return hash
+}
+
+func (vm *VM) call(numArgs int) object.Object {
+	switch callee := vm.stack[vm.sp-1-numArgs].(type) {
+	case *object.Closure:
+		return vm.callClosure(callee, numArgs)
+	case *object.Builtin:
+		args := make([]object.Object, numArgs)
+		copy(args, vm.stack[vm.sp-numArgs:vm.sp])
+		vm.sp -= numArgs + 1
+		return vm.pushNew(evaluator.CallBuiltin(callee, args))
+	default:
+		return vm.errorf(object.KindType, `not a function: %s`, callee.Type())
+	}
+}
+
+func (vm *VM) callClosure(cl *object.Closure, numArgs int) object.Object {
+	fn := cl.Fn
+	if numArgs < fn.NumRequired || !fn.Variadic && numArgs > fn.NumParams {
+		return evaluator.ArityError(fn.NumRequired, fn.NumParams, fn.Variadic, numArgs)
+	}
+	if err := vm.budget.Enter(vm.framesIndex - 1); err != nil {
+		return err
+	}
+	basePointer := vm.sp - numArgs
+	vm.growStack(basePointer + fn.NumLocals + 1)
+
+	firstUnset := numArgs
+	if fn.Variadic {
+		rest := []object.Object{}
+		if numArgs > fn.NumParams {
+			rest = append(rest, vm.stack[basePointer+fn.NumParams:vm.sp]...)
+		}
+		vm.stack[basePointer+fn.NumParams] = &object.Array{Elements: rest}
+		firstUnset = fn.NumParams + 1
+	}
+	// Clear the slots of parameters that were not passed and of locals, so
+	// that reading one before it is set reports an undefined identifier.
+	for i := basePointer + firstUnset; i < basePointer+fn.NumLocals; i++ {
+		vm.stack[i] = nil
+	}
+
+	vm.pushFrame(NewFrame(cl, basePointer, numArgs))
+	vm.sp = basePointer + fn.NumLocals
+	return nil
+}
+
+// unwindTo adds the calls active in the VM above the frame numbered
+// framesIndex to the stack of err, innermost first.
+func (vm *VM) unwindTo(err *object.Error, framesIndex int) {
+	for i := vm.framesIndex - 1; i >= framesIndex; i-- {
+		err.Stack = append(err.Stack, object.Frame{
+			Function: vm.frames[i].cl.Fn.Name,
+			Pos:      vm.frames[i-1].callPos(),
+		})
+	}
+}
+
+func (vm *VM) currentFrame() *Frame {
+	return vm.frames[vm.framesIndex-1]
+}
+
+func (vm *VM) pushFrame(f *Frame) {
+	if vm.framesIndex == len(vm.frames) {
+		vm.frames = append(vm.frames, f)
+	} else {
+		vm.frames[vm.framesIndex] = f
+	}
+	vm.framesIndex++
+}
+
+func (vm *VM) popFrame() *Frame {
+	vm.framesIndex--
+	return vm.frames[vm.framesIndex]
+}
+
+// push pushes obj and returns it, so that errors can be checked by the
+// caller. Errors are not pushed.
+func (vm *VM) push(obj object.Object) object.Object {
+	if _, ok := obj.(*object.Error); ok {
+		return obj
+	}
+	vm.growStack(vm.sp + 1)
+	vm.stack[vm.sp] = obj
+	vm.sp++
+	return nil
+}
+
+// pushNew is like push, but first charges the run for obj, which has just
+// been created.
+func (vm *VM) pushNew(obj object.Object) object.Object {
+	if err := vm.budget.Alloc(obj); err != nil {
+		return err
+	}
+	return vm.push(obj)
+}
+
+// growStack makes the stack hold at least size slots.
+func (vm *VM) growStack(size int) {
+	if size <= len(vm.stack) {
+		return
+	}
+	n := 2 * len(vm.stack)
+	if n < size {
+		n = size
+	}
+	stack := make([]object.Object, n)
+	copy(stack, vm.stack)
+	vm.stack = stack
+}
+
+func (vm *VM) pop() object.Object {
+	obj := vm.stack[vm.sp-1]
+	vm.sp--
+	return obj
+}
+
+func (vm *VM) errorf(kind object.ErrorKind, format string, a ...interface{}) *object.Error {
+	return &object.Error{Message: fmt.Sprintf(format, a...), Kind: kind}
+}
+
+func isTruthy(obj object.Object) bool {
+	return obj != evaluator.NULL && obj != evaluator.FALSE
+}
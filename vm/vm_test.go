@@ -0,0 +1,595 @@
+package vm
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/cszczepaniak/monkey/compiler"
+	"github.com/cszczepaniak/monkey/evaluator"
+	"github.com/cszczepaniak/monkey/lexer"
+	"github.com/cszczepaniak/monkey/object"
+	"github.com/cszczepaniak/monkey/parser"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestMatchesEvaluator runs the evaluator's test programs on the VM and
+// checks that both backends produce the same value or error, including its
+// position, in every overflow mode.
+func TestMatchesEvaluator(t *testing.T) {
+	inputs := []string{
+		`5`,
+		`10`,
+		`-5`,
+		`-10`,
+		`5 + 5 + 5 + 5 - 10`,
+		`2 * 2 * 2 * 2 * 2`,
+		`-50 + 100 + -50`,
+		`5 * 2 + 10`,
+		`5 + 2 * 10`,
+		`20 + 2 * -10`,
+		`50 / 2 * 2 + 10`,
+		`2 * (5 + 10)`,
+		`3 * 3 * 3 + 10`,
+		`3 * (3 * 3) + 10`,
+		`(5 + 10 * 2 + 15 / 3) * 2 + -10`,
+		`7 % 3`,
+		`-7 % 3`,
+		`2 + 7 % 3 * 2`,
+		`true`,
+		`false`,
+		`1 == 1`,
+		`1 != 1`,
+		`1 == 2`,
+		`1 != 2`,
+		`1 < 2`,
+		`1 > 2`,
+		`1 > 1`,
+		`1 < 1`,
+		`true == true`,
+		`false == false`,
+		`false == true`,
+		`false != true`,
+		`true != false`,
+		`(1 < 2) != false`,
+		`(1 < 2) == false`,
+		`true != (1 < 2)`,
+		`true == (1 < 2)`,
+		`"Hello World!"`,
+		`"Hello" + " " + "World!"`,
+		`let greet = fn(name) { "Hello, " + name }; greet("monkey")`,
+		`"a" == "a"`,
+		`"a" == "b"`,
+		`"a" != "b"`,
+		`"a" + "b" == "ab"`,
+		`[1, 4, 6]`,
+		`[]`,
+		`[1, 2, 3][0]`,
+		`[1, 2, 3][1]`,
+		`[1, 2, 3][2]`,
+		`let i = 0; [1][i];`,
+		`[1, 2, 3][1 + 1];`,
+		`let myArray = [1, 2, 3]; myArray[2];`,
+		`let myArray = [1, 2, 3]; myArray[0] + myArray[1] + myArray[2];`,
+		`let myArray = [1, 2, 3]; let i = myArray[0]; myArray[i]`,
+		`[1, 2, 3][-1]`,
+		`[1, 2, 3][-3]`,
+		`[1, 2, 3][3]`,
+		`[1, 2, 3][-4]`,
+		`[][0]`,
+		`{"one": 1, "two": 2, "three": 3, 4: 4, true: 5, false: 6}`,
+		`{"foo": 5}["foo"]`,
+		`{"foo": 5}["bar"]`,
+		`let key = "foo"; {"foo": 5}[key]`,
+		`{}["foo"]`,
+		`{5: 5}[5]`,
+		`{true: 5}[true]`,
+		`{false: 5}[false]`,
+		`{"a": 1, "a": 2}["a"]`,
+		`!true`,
+		`!false`,
+		`!5`,
+		`!0`,
+		`!!true`,
+		`!!false`,
+		`!!5`,
+		`if (true) { 10 }`,
+		`if (false) { 10 }`,
+		`if (1) { 10 }`,
+		`if (1 < 2) { 10 }`,
+		`if (1 > 2) { 10 }`,
+		`if (1 > 2) { 10 } else { 20 }`,
+		`if (1 < 2) { 10 } else { 20 }`,
+		`return 10;`,
+		`return 10; 9;`,
+		`return 2 * 5; 9;`,
+		`9; return 2 * 5; 9;`,
+		`
+		if (10 > 1) {
+			if (10 > 1) {
+				return 10;
+			}
+			return 1;
+		}`,
+		`5 + true;`,
+		`5 + true; 5;`,
+		`-true`,
+		`true + false`,
+		`5; true + false; 5;`,
+		`if (1 < 2) { true + false; }`,
+		`
+		if (10 > 1) {
+			if (10 > 1) {
+				return true + false;
+			}
+			return 1;
+		}`,
+		`foobar;`,
+		`"Hello" - "World"`,
+		`"Hello" + 1`,
+		`[1, 2]["a"]`,
+		`1[0]`,
+		`[1, foo]`,
+		`{"name": "Monkey"}[fn(x) { x }];`,
+		`{[1]: 2}`,
+		"let x = 1;\nlet y = x + true;",
+		"let f = fn() {\n  -true\n};\nf();",
+		`5(1)`,
+		`1 / 0`,
+		`1 % 0`,
+		`let f = fn(x) { 10 / x }; f(0)`,
+		`9223372036854775807 + 1`,
+		`-9223372036854775807 - 2`,
+		`4611686018427387904 * 4`,
+		`let min = -9223372036854775807 - 1; min / -1`,
+		`let min = -9223372036854775807 - 1; -min`,
+		`9223372036854775807 + 1 - 1`,
+		`18446744073709551617 * 2`,
+		`-9223372036854775808`,
+		`let f = fn() { 0xffff_ffff_ffff_ffff_ff }; f() + f()`,
+		`1 + 2`,
+		`3`,
+		`let big = 9223372036854775807 * 10; big / 10 == 9223372036854775807`,
+		`let big = 9223372036854775807 * 10; big > 9223372036854775807`,
+		`let big = 9223372036854775807 * 10; big % 7`,
+		`let big = 9223372036854775807 * 10; big - big`,
+		`let big = 9223372036854775807 * 10; -big`,
+		`let big = 9223372036854775807 * 10; type(big)`,
+		`let big = 9223372036854775807 * 10; {big: 1}[big * 1]`,
+		`let big = 9223372036854775807 * 10; [1][big]`,
+		`let big = 9223372036854775807 * 10; big / 0`,
+		`let x = ;`,
+		`if (true) {}`,
+		`return;`,
+		`let a = 5; a;`,
+		`let a = 5 * 5; a;`,
+		`let a = 5; let b = a; b;`,
+		`let a = 5; let b = 6 * a; b;`,
+		`let a = 5; let b = 6; let c = a * b; c;`,
+		`x`,
+		`{ (x + 2); }`,
+		`let f = fn(x) { x; }; f(5);`,
+		`let f = fn(x) { return x; }; f(5);`,
+		`let double = fn(x) { x * 2; }; double(5);`,
+		`let add = fn(x, y) { x + y; }; add(4, 5);`,
+		`let add = fn(x, y) { x + y; }; add(4, add(5, 5));`,
+		`fn(x) { x; }(5)`,
+		`let f = fn(x) {
+			if (x == 0) {
+				return 1;
+			}
+			return x * f(x-1);
+		};
+		f(5);
+		`,
+		`
+		let newAdder = fn(x) {
+			fn(y) { x + y; }
+		};
+		let addTwo = newAdder(2);
+		addTwo(3);
+		`,
+		`
+		let sub = fn(x, y) { x - y };
+		let applyFn = fn(f, x, y) { f(x, y); };
+		applyFn(sub, 3, 1);
+		`,
+		`let f = fn(x, y = 10) { x + y }; f(1)`,
+		`let f = fn(x, y = 10) { x + y }; f(1, 2)`,
+		`let f = fn(x, y = x * 2) { x + y }; f(3)`,
+		`let n = 5; let f = fn(x = n) { x }; let m = 6; f()`,
+		`let f = fn(first, ...rest) { len(rest) }; f(1, 2, 3)`,
+		`let f = fn(first, ...rest) { rest }; f(1)`,
+		`let f = fn(...all) { all }; f(1, "a", true)`,
+		"a",
+		`let f = fn(a, b = 2, ...rest) { [a, b, rest] }; f(1, 3, 5, 7)`,
+		`let f = fn(x, y) { x }; f(1)`,
+		`let f = fn(x) { x }; f(1, 2)`,
+		`fn() { 1 }(1)`,
+		`let f = fn(x, y = 1) { x }; f(1, 2, 3)`,
+		`let f = fn(x, y, ...z) { x }; f(1)`,
+		`let f = fn(x = oops) { x }; f()`,
+		`len("")`,
+		`len("four")`,
+		`len("héllo")`,
+		`len([1, 2, 3])`,
+		`len({"a": 1})`,
+		`len(1)`,
+		`len("one", "two")`,
+		`first([1, 2, 3])`,
+		`first([])`,
+		`first(1)`,
+		`last([1, 2, 3])`,
+		`last([])`,
+		`last(1)`,
+		`rest([1, 2, 3])`,
+		`rest([1])`,
+		`rest([])`,
+		`push([], 1)`,
+		`let a = [1]; push(a, 2); a`,
+		`push(1, 1)`,
+		`push([1])`,
+		`type("a")`,
+		`type(len)`,
+		`let len = fn(x) { 42 }; len("a")`,
+		`3.14`,
+		`1e-9`,
+		`2.0`,
+		`-2.5`,
+		`0.1 + 0.2`,
+		`1 + 0.5`,
+		`0.5 * 4`,
+		`7 / 2.0`,
+		`7 / 2`,
+		`5.5 % 2`,
+		`1 == 1.0`,
+		`1 < 1.5`,
+		`2.5 > 3`,
+		`1.0 / 0`,
+		`-1 / 0.0`,
+		`0 / 0.0`,
+		`let big = 9223372036854775807 * 10; big * 0.5`,
+		`0xff + 0b1 + 0o7 + 1_000`,
+		`type(1.5)`,
+		`"a" + 1.5`,
+		`{1.5: 1}`,
+		`1 <= 1`,
+		`2 <= 1`,
+		`1 >= 2`,
+		`1.5 >= 1`,
+		`true && true`,
+		`true && false`,
+		`false || true`,
+		`if (false) { 1 } || false`,
+		`1 && "a"`,
+		`false && missing`,
+		`true || 1 / 0`,
+		`true && missing`,
+		`1 < 2 && 2 < 3 || false`,
+		`6 & 3`,
+		`6 | 3`,
+		`6 ^ 3`,
+		`~5`,
+		`1 << 10`,
+		`-1024 >> 3`,
+		`1 >> 100`,
+		`1 << -1`,
+		`1 << 2000000`,
+		`1 | 2 ^ 3 & 4 << 1`,
+		`1.5 & 1`,
+		`~true`,
+		`"a" <= "b"`,
+		`let fail = fn() { 1 / 0 }; false && fail()`,
+		`1 << 63`,
+		`3 << 64`,
+		`let big = 1 << 100; big >> 98`,
+		`let big = 1 << 100; (big | 1) & 3`,
+		`let big = 1 << 100; ~big`,
+		`let x = 1; x = 2; x`,
+		`let x = 1; x = x + 1`,
+		`let a = 1; let b = 2; a = b = 7; [a, b]`,
+		`let x = 10; x += 5; x -= 3; x *= 2; x /= 4`,
+		`let s = "a"; s += "b"; s`,
+		`let f = 0.5; f *= 3; f`,
+		`let counter = fn() { let n = 0; fn() { n += 1; n } }; let c = counter(); c(); c(); c()`,
+		`let n = 0; let inc = fn() { n = n + 1 }; inc(); inc(); n`,
+		`let f = fn(p) { let g = fn() { p *= 2 }; g(); g(); p }; f(3)`,
+		`let x = 1; let f = fn() { let x = 5; x = 6; x }; [f(), x]`,
+		`let a = [1, 2, 3]; a[0] = 10; a[-1] += 7; a`,
+		`let h = {"a": 1}; h["a"] -= 3; h["b"] = 2; h`,
+		`let a = [[1], [2]]; a[1][0] = 5; a`,
+		`let a = [1]; let b = a; b[0] = 2; a`,
+		`let a = [1]; a[1] = 2`,
+		`let h = {}; h[fn() {}] = 1`,
+		`let s = "ab"; s[0] = "c"`,
+		`y = 1`,
+		`let x = 1; x += "a"`,
+		`let x = 5; x /= 0`,
+		`let f = fn() { let x = 1; let g = fn() { let h = fn() { x += 1 }; h(); h() }; g(); x }; f()`,
+		`let f = fn(a, b = a) { let g = fn() { b += a }; g(); b }; f(2)`,
+		`let f = fn(...r) { let g = fn() { r = len(r) }; g(); r }; f(1, 2)`,
+		`let x = 1; let f = fn() { x += 1 }; f(); f(); x`,
+		`let xs = [1, 2]; let f = fn() { xs[0] += 10 }; f(); xs`,
+		`let i = 0; while (i < 5) { i += 1 }; i`,
+		`while (false) { 1 }`,
+		`let s = 0; let i = 0; while (true) { i += 1; if (i > 10) { break }; if (i % 2 == 0) { continue }; s += i }; s`,
+		`let out = []; for (x in [1, 2, 3]) { out = push(out, x * 2) }; out`,
+		`let ks = []; for (k in {"b": 1, "a": 2}) { ks = push(ks, k) }; ks`,
+		`let h = {1: 1}; for (k in h) { h[k + 1] = 1 }; len(h)`,
+		`let a = [1, 2]; let n = 0; for (x in a) { n += 1; if (n < 5) { a[1] = 7 } }; a`,
+		`let s = 0; for (i in range(5)) { s += i }; s`,
+		`let r = []; for (i in range(1, 10, 4)) { r = push(r, i) }; r`,
+		`let r = []; for (i in range(3, 0, -1)) { r = push(r, i) }; r`,
+		`let r = []; for (i in range(0)) { r = push(r, i) }; r`,
+		`for (x in [1, 2]) {}; x`,
+		`let x = for (i in [1]) { i }; x`,
+		`let r = []; for (i in range(3)) { for (j in range(3)) { if (j > i) { break }; r = push(r, [i, j]) } }; r`,
+		`let f = fn(xs) { for (x in xs) { if (x > 1) { return x } }; 0 }; [f([1, 2, 3]), f([])]`,
+		`let n = 0; for (i in range(10)) { n = n + [1, if (i % 2 == 0) { continue } else { 2 }][1] }; n`,
+		`let t = 0; for (i in range(5)) { t += 1 + if (i == 3) { break } else { 0 } }; t`,
+		`let f = fn() { let fs = []; for (i in range(3)) { fs = push(fs, fn() { i }) }; fs[0]() }; f()`,
+		`let f = fn() { 1 + if (true) { return 5 } }; f()`,
+		`let g = fn(a = if (true) { return 7 }) { 1 }; [g(), g(2)]`,
+		`len(range(0, 10, 3))`,
+		`len(range(10, 0))`,
+		`len(range(-9223372036854775807 - 1, 9223372036854775807))`,
+		`range(5)`,
+		`type(range(1))`,
+		`for (x in 5) {}`,
+		`for (x in range(1)) { y }`,
+		`while (missing) {}`,
+		`range()`,
+		`range(1, 2.5)`,
+		`range(1, 2, 0)`,
+		`try { 1 } catch (e) { 2 }`,
+		`try { 1 + true } catch (e) { e }`,
+		`try { foo } catch (e) { e["kind"] }`,
+		`try { throw [1, 2] } catch (e) { e }`,
+		`try { throw "x" } catch (e) { e + "y" }`,
+		`let f = fn() { throw 1 }; try { f() } catch (e) { e + 1 }`,
+		`try { try { throw 1 } catch (e) { throw e + 1 } } catch (e) { e }`,
+		`let a = []; try { a = push(a, 1) } finally { a = push(a, 2) }; a`,
+		`let a = []; try { try { throw 1 } finally { a = push(a, 2) } } catch (e) { a = push(a, e) }; a`,
+		`let a = []; try { 1 } catch (e) { a = push(a, 1) } finally { a = push(a, 2) }; a`,
+		`let a = []; try { throw 1 } catch (e) { a = push(a, 1) } finally { a = push(a, 2) }; a`,
+		`try { 1 } finally { 2 }`,
+		`1 + try { throw 1 } catch (e) { 2 }`,
+		`let f = fn() { try { return 1 } finally { 2 } }; f()`,
+		`let f = fn() { try { return 1 } finally { return 2 } }; f()`,
+		`let f = fn() { try { throw 1 } finally { return 2 } }; f()`,
+		`let n = 0; for (i in range(5)) { try { if (i == 3) { break }; n += i } finally { n += 10 } }; n`,
+		`let n = 0; for (i in range(3)) { try { continue } finally { n += 1 } }; n`,
+		`let n = 0; while (true) { try { throw 1 } catch (e) { break } finally { n += 1 } }; n`,
+		`let g = fn() { throw "g" }; let f = fn() { try { return g() } catch (e) { e } }; f()`,
+		`let g = fn() { throw "g" }; let f = fn() { try { g() } catch (e) { e } }; f()`,
+		`let f = fn() { try { 1 } catch (e) { 2 }; f2() }; f()`,
+		`throw 1`,
+		`throw {"a": 1}`,
+		`try { 1 } finally { throw 2 }`,
+		`try { 1 } catch (e) { 2 } finally { 1 + true }`,
+		`let f = fn() { let fs = []; for (i in range(3)) { try { throw i } catch (e) { fs = push(fs, fn() { e }) } }; fs[1]() }; f()`,
+		`let f = fn(x) { try { if (x > 2) { throw x }; x } catch (e) { -e } }; [f(1), f(5)]`,
+		`let f = fn(n) { if (n == 0) { 0 } else { 1 + f(n - 1) } }; f(5000)`,
+		`let f = fn(n) { 1 + f(n + 1) }; f(0)`,
+		`let f = fn() { let g = fn() { y }; let y = 5; g() }; f()`,
+		`let f = fn() { let x = 1; let g = fn() { x }; let x = 2; g() }; f()`,
+		`let f = fn(n) { let even = fn(n) { if (n == 0) { true } else { odd(n - 1) } }; let odd = fn(n) { if (n == 0) { false } else { even(n - 1) } }; [even(n), odd(n)] }; f(7)`,
+		`let f = fn() { let fs = []; let i = 0; while (i < 3) { let x = i; fs = push(fs, fn() { x }); i += 1 }; fs[0]() }; f()`,
+		`let f = fn(c) { if (c) { let x = 1 }; fn() { x } }; f(true)()`,
+	}
+
+	modes := []evaluator.OverflowMode{evaluator.OverflowWrap, evaluator.OverflowError, evaluator.OverflowPromote}
+	for _, input := range inputs {
+		p := parser.New(lexer.New(input))
+		program := p.ParseProgram()
+		if len(p.Errors()) > 0 {
+			continue
+		}
+		for _, mode := range modes {
+			config := evaluator.Config{Overflow: mode}
+			exp := evaluator.New(config).Eval(program, object.NewEnvironment())
+			res := runInput(t, input, config)
+			assert.Equal(t, exp.Inspect(), res.Inspect(), input)
+			if expErr, ok := exp.(*object.Error); ok {
+				assert.Equal(t, expErr.Kind, res.(*object.Error).Kind, input)
+				assert.Equal(t, expErr.End, res.(*object.Error).End, input)
+			}
+		}
+	}
+}
+
+func TestConstants(t *testing.T) {
+	assert.Equal(t, `3`, runInput(t, `const a = 1; const b = 2; a + b`, evaluator.Config{}).Inspect())
+
+	inputs := map[string]string{
+		`const k = 1; k = 2`:                      `1:14: cannot assign to constant k`,
+		`const k = 1; let f = fn() { k += 1 }`:    `1:29: cannot assign to constant k`,
+		`const k = 1; let k = 2`:                  `1:14: cannot redeclare constant k`,
+		`let f = fn() { const k = 1; k = 2 }`:     `1:29: cannot assign to constant k`,
+		`let f = fn() { let g = fn() { g = 1 } }`: `1:31: cannot assign to g inside its own definition`,
+	}
+	for input, expErr := range inputs {
+		program := parser.New(lexer.New(input)).ParseProgram()
+		err := compiler.New().Compile(program)
+		require.Error(t, err, input)
+		assert.Equal(t, expErr, err.Error(), input)
+	}
+}
+
+func TestLoopStack(t *testing.T) {
+	// Each continue discards the operands pushed before it, so the stack
+	// does not grow with the number of iterations.
+	input := `
+	let n = 0;
+	for (i in range(100000)) { n = n + [i, if (i % 2 == 0) { continue } else { 1 }][1] };
+	let m = 0;
+	while (m < 100000) { m += 1; [m, if (true) { continue }] };
+	[n, m]`
+	assert.Equal(t, `[50000, 100000]`, runInput(t, input, evaluator.Config{}).Inspect())
+}
+
+func TestGlobalsPersist(t *testing.T) {
+	symbols := compiler.NewSymbolTable()
+	globals := make([]object.Object, GlobalsSize)
+	var constants []object.Object
+
+	run := func(input string) object.Object {
+		program := parser.New(lexer.New(input)).ParseProgram()
+		c := compiler.NewWithState(symbols, constants)
+		require.NoError(t, c.Compile(program))
+		constants = c.Constants()
+		m := NewWithGlobals(c.Bytecode(), evaluator.Config{}, globals)
+		require.NoError(t, m.Run())
+		return m.LastPoppedStackElem()
+	}
+
+	run(`let f = fn() { g() };`)
+	run(`let g = fn() { 42 };`)
+	assert.Equal(t, `42`, run(`f()`).Inspect())
+}
+
+func TestRecursion(t *testing.T) {
+	input := `
+	let fib = fn(n) { if (n < 2) { n } else { fib(n - 1) + fib(n - 2) } };
+	let wrapper = fn() {
+		let inner = fn(n) { if (n == 0) { 0 } else { inner(n - 1) } };
+		inner(100)
+	};
+	[fib(15), wrapper()]`
+	assert.Equal(t, `[610, 0]`, runInput(t, input, evaluator.Config{}).Inspect())
+}
+
+func TestPutsOutput(t *testing.T) {
+	var out bytes.Buffer
+	runInput(t, `let f = fn() { puts("a", [1, "b"]) }; f(); puts(2)`, evaluator.Config{Out: &out})
+	assert.Equal(t, "a\n[1, \"b\"]\n2\n", out.String())
+}
+
+func TestDeepRecursion(t *testing.T) {
+	// The VM allows calls as deep as the evaluator does.
+	input := `let f = fn(n) { if (n == 0) { 0 } else { 1 + f(n - 1) } }; f(5000)`
+	assert.Equal(t, `5000`, runInput(t, input, evaluator.Config{}).Inspect())
+
+	input = `let f = fn(n) { if (n == 0) { 0 } else { 1 + f(n - 1) } }; f(15000)`
+	assert.Equal(t, `15000`, runInput(t, input, evaluator.Config{MaxDepth: 20000}).Inspect())
+	res := runInput(t, input, evaluator.Config{})
+	require.IsType(t, &object.Error{}, res)
+	assert.Equal(t, object.KindCallDepth, res.(*object.Error).Kind)
+}
+
+func TestStackOverflow(t *testing.T) {
+	res := runInput(t, `let f = fn(n) { f(n + 1) + 1 }; f(0)`, evaluator.Config{})
+	require.IsType(t, &object.Error{}, res)
+	assert.Equal(t, `stack overflow`, res.(*object.Error).Message)
+}
+
+func TestErrorStack(t *testing.T) {
+	inputs := []string{
+		"let g = fn(x) { x + true };\nlet f = fn(x) { let y = g(x); y };\nf(1)",
+		`let f = fn() { fn() { len(1) }() + 1 }; [f()]`,
+		`let f = fn(a) { a }; let g = fn() { f() + 1 }; g()`,
+		`1 / 0`,
+		"let f = fn() { try { 1 + true } finally { 2 } };\nlet g = fn() { f() + 1 };\ng()",
+		`let f = fn() { throw "f" }; let g = fn() { try { f() } catch (e) { 1 + true } }; g()`,
+	}
+
+	for _, input := range inputs {
+		program := parser.New(lexer.New(input)).ParseProgram()
+		exp := evaluator.Eval(program, object.NewEnvironment())
+		require.IsType(t, &object.Error{}, exp, input)
+		res := runInput(t, input, evaluator.Config{})
+		require.IsType(t, &object.Error{}, res, input)
+		assert.Equal(t, exp.(*object.Error).Traceback(), res.(*object.Error).Traceback(), input)
+	}
+}
+
+func TestLimits(t *testing.T) {
+	tests := []struct {
+		config  evaluator.Config
+		input   string
+		expKind object.ErrorKind
+		expErr  string
+	}{{
+		evaluator.Config{},
+		`let f = fn() { f() }; f()`,
+		object.KindCallDepth,
+		`1:16: stack overflow`,
+	}, {
+		evaluator.Config{MaxDepth: 10},
+		`let f = fn(n) { if (n > 0) { f(n - 1) } }; f(20)`,
+		object.KindCallDepth,
+		`1:30: stack overflow`,
+	}, {
+		evaluator.Config{MaxSteps: 1000},
+		`while (true) {}`,
+		object.KindStepLimit,
+		`1:8: step limit of 1000 exceeded`,
+	}, {
+		evaluator.Config{MaxAlloc: 1 << 20},
+		`let s = "x"; while (true) { s += s }`,
+		object.KindMemoryLimit,
+		`1:29: memory limit of 1048576 bytes exceeded`,
+	}, {
+		evaluator.Config{MaxAlloc: 10000},
+		`let h = {}; for (i in range(200000)) { h[i] = true }`,
+		object.KindMemoryLimit,
+		`1:40: memory limit of 10000 bytes exceeded`,
+	}, {
+		// A try expression does not catch an error for exceeding a limit.
+		evaluator.Config{MaxSteps: 1000},
+		`try { while (true) {} } catch (e) { 1 } finally { 2 }`,
+		object.KindStepLimit,
+		`1:7: step limit of 1000 exceeded`,
+	}, {
+		evaluator.Config{},
+		`let f = fn() { f() }; try { f() } catch (e) { 1 }`,
+		object.KindCallDepth,
+		`1:16: stack overflow`,
+	}}
+
+	for _, tc := range tests {
+		res := runInput(t, tc.input, tc.config)
+		require.IsType(t, &object.Error{}, res, tc.input)
+		assert.Equal(t, tc.expKind, res.(*object.Error).Kind, tc.input)
+		assert.Equal(t, tc.expErr, res.(*object.Error).Error(), tc.input)
+	}
+}
+
+func TestRunContext(t *testing.T) {
+	program := parser.New(lexer.New(`while (true) {}`)).ParseProgram()
+	c := compiler.New()
+	require.NoError(t, c.Compile(program))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err := New(c.Bytecode(), evaluator.Config{}).RunContext(ctx)
+	require.IsType(t, &object.Error{}, err)
+	assert.Equal(t, object.KindCanceled, err.(*object.Error).Kind)
+}
+
+func TestHostPanics(t *testing.T) {
+	program := parser.New(lexer.New(`let x = 1; boom(x)`)).ParseProgram()
+	c := compiler.New()
+	require.NoError(t, c.Compile(program))
+	globals := make([]object.Object, GlobalsSize)
+	globals[1] = &object.Builtin{Name: `boom`, Fn: func(args ...object.Object) object.Object {
+		panic(`host failure`)
+	}}
+	err := NewWithGlobals(c.Bytecode(), evaluator.Config{}, globals).Run()
+	assert.EqualError(t, err, `1:12: internal error: host failure`)
+}
+
+// runInput compiles and runs input, returning its value or the runtime error.
+func runInput(t *testing.T, input string, config evaluator.Config) object.Object {
+	program := parser.New(lexer.New(input)).ParseProgram()
+	c := compiler.New()
+	require.NoError(t, c.Compile(program), input)
+
+	m := New(c.Bytecode(), config)
+	if err := m.Run(); err != nil {
+		require.IsType(t, &object.Error{}, err, input)
+		return err.(*object.Error)
+	}
+	return m.LastPoppedStackElem()
+}